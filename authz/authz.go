@@ -0,0 +1,210 @@
+package authz
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/jwt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defScopesClaim = "scopes"
+	defRolesClaim  = "roles"
+)
+
+var (
+	_conf      atomic.Value
+	_watchOnce sync.Once
+)
+
+type claimsConf struct {
+	// scopes、roles在MotorClaims.Data中的路径，多级路径以"."分隔，如：auth.roles
+	Scopes string `toml:"scopes"`
+	Roles  string `toml:"roles"`
+}
+
+// 路由与所需权限的映射
+type Policy struct {
+	// 路由模板(如：/orders/:id)或路径通配(如：/orders/*)，以"/**"结尾时匹配该前缀下的所有路径
+	Path string `toml:"path"`
+	// 为空时匹配所有方法
+	Method string `toml:"method"`
+	// 需同时具备的scope
+	Scopes []string `toml:"scopes"`
+	// 具备其中任一角色即可
+	Roles []string `toml:"roles"`
+}
+
+type AuthzConf struct {
+	Claims claimsConf `toml:"claims"`
+	Policy []*Policy  `toml:"policy"`
+}
+
+// 加载authz.toml并监听配置改动，重复调用时仅重新加载配置
+func Init() {
+	cfg, err := getConf()
+	if err != nil {
+		panic(err.Error())
+	}
+	_conf.Store(cfg)
+
+	_watchOnce.Do(func() {
+		go func() {
+			for range conf.WatchEvent("authz.toml") {
+				cfg, err := getConf()
+				if err != nil {
+					zap.L().Error("authz reload failed", zap.Error(err))
+					continue
+				}
+				_conf.Store(cfg)
+			}
+		}()
+	})
+}
+
+func getConf() (*AuthzConf, error) {
+	var cfg AuthzConf
+	if err := conf.Get("authz.toml").UnmarshalTOML(&cfg); err != nil {
+		return nil, errors.Wrap(err, "Get(authz.toml).UnmarshalTOML failed")
+	}
+	for _, p := range cfg.Policy {
+		if p.Path == "" {
+			return nil, errors.New("policy path cannot be empty")
+		}
+		p.Method = strings.ToUpper(p.Method)
+	}
+
+	return &cfg, nil
+}
+
+func loadConf() *AuthzConf {
+	cfg, _ := _conf.Load().(*AuthzConf)
+	if cfg == nil {
+		return &AuthzConf{}
+	}
+	return cfg
+}
+
+// 获取claims中的scope列表
+func Scopes(claims *jwt.MotorClaims) []string {
+	p := loadConf().Claims.Scopes
+	if p == "" {
+		p = defScopesClaim
+	}
+	return lookup(claims, p)
+}
+
+// 获取claims中的角色列表
+func Roles(claims *jwt.MotorClaims) []string {
+	p := loadConf().Claims.Roles
+	if p == "" {
+		p = defRolesClaim
+	}
+	return lookup(claims, p)
+}
+
+// 按路径读取claims.Data，支持字符串数组及空格分隔的字符串(OAuth2 scope格式)
+func lookup(claims *jwt.MotorClaims, claimPath string) []string {
+	if claims == nil || claims.Data == nil {
+		return nil
+	}
+
+	var val interface{} = claims.Data
+	for _, key := range strings.Split(claimPath, ".") {
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if val, ok = m[key]; !ok {
+			return nil
+		}
+	}
+
+	switch v := val.(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+// 查找与请求匹配的策略，route为gin路由模板，urlPath为请求路径
+func Match(method, route, urlPath string) (*Policy, bool) {
+	for _, p := range loadConf().Policy {
+		if p.Method != "" && p.Method != method {
+			continue
+		}
+		if p.match(route, urlPath) {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+func (p *Policy) match(route, urlPath string) bool {
+	if route != "" && p.Path == route {
+		return true
+	}
+	if strings.HasSuffix(p.Path, "/**") {
+		prefix := strings.TrimSuffix(p.Path, "**")
+		return strings.HasPrefix(urlPath+"/", prefix)
+	}
+	ok, _ := path.Match(p.Path, urlPath)
+	return ok
+}
+
+// 校验权限，返回nil表示通过
+func (p *Policy) Check(scopes, roles []string) error {
+	if err := HasScopes(scopes, p.Scopes...); err != nil {
+		return err
+	}
+	return HasAnyRole(roles, p.Roles...)
+}
+
+// 校验是否具备全部所需scope
+func HasScopes(scopes []string, required ...string) error {
+	for _, r := range required {
+		if !contains(scopes, r) {
+			return fmt.Errorf("missing scope: %s", r)
+		}
+	}
+	return nil
+}
+
+// 校验是否具备任一所需角色
+func HasAnyRole(roles []string, required ...string) error {
+	if len(required) == 0 {
+		return nil
+	}
+	for _, r := range required {
+		if contains(roles, r) {
+			return nil
+		}
+	}
+	return fmt.Errorf("requires one of roles: %s", strings.Join(required, ","))
+}
+
+func contains(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
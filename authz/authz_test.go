@@ -0,0 +1,39 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+	Init()
+
+	claims := &jwt.MotorClaims{
+		Data: map[string]interface{}{
+			"scopes": "orders:read orders:write",
+			"auth": map[string]interface{}{
+				"roles": []interface{}{"ops"},
+			},
+		},
+	}
+	require.Equal([]string{"orders:read", "orders:write"}, Scopes(claims))
+	require.Equal([]string{"ops"}, Roles(claims))
+
+	p, ok := Match("POST", "/orders/:id", "/orders/1")
+	require.True(ok)
+	require.Nil(p.Check(Scopes(claims), Roles(claims)))
+	require.NotNil(p.Check([]string{"orders:read"}, nil))
+
+	_, ok = Match("GET", "/orders/:id", "/orders/1")
+	require.False(ok)
+
+	p, ok = Match("GET", "", "/admin/users")
+	require.True(ok)
+	require.Nil(p.Check(nil, Roles(claims)))
+	require.NotNil(p.Check(nil, []string{"guest"}))
+}
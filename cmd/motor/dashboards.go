@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/metrics"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defSLOWindow = "30d"
+)
+
+var windowRe = regexp.MustCompile(`^[0-9]+[smhdwy]$`)
+
+// slo.toml中[SLO]的配置
+type SLOConf struct {
+	// 服务名，用于dashboard标题及规则分组名，默认使用metrics的namespace
+	Service string
+	// 选择本服务指标的标签，如：{app = "motor"}
+	Selector map[string]string
+	// SLO统计周期，PromQL时间格式，默认30d，不短于3d，燃烧率告警阈值按周期缩放
+	Window string
+	// 可用性目标(%)，即非5xx请求的占比，如：99.9
+	Availability float64
+	// 延迟目标(%)，即LatencyThreshold内完成的请求占比，为0时不生成延迟SLO
+	Latency float64
+	// 延迟阈值(ms)，须为histogram的bucket之一
+	LatencyThreshold float64
+	// mysql、redis连接池使用率告警阈值，如：0.8，为0时不告警
+	MysqlSaturation float64
+	RedisSaturation float64
+}
+
+// 从slo.toml的[SLO]中加载配置
+func loadSLOConf() (*SLOConf, error) {
+	var st conf.Storage
+	var cfg SLOConf
+	if err := conf.Get("slo.toml").Unmarshal(&st); err != nil {
+		return nil, errors.Wrap(err, "Get(slo.toml).Unmarshal failed")
+	}
+	v := st.Get("SLO")
+	if v == nil {
+		return nil, errors.New("section SLO not found in slo.toml")
+	}
+	if err := v.UnmarshalTOML(&cfg); err != nil {
+		return nil, errors.Wrap(err, "Get(SLO).UnmarshalTOML failed")
+	}
+
+	return &cfg, nil
+}
+
+func genDashboards(args []string) error {
+	fs := flag.NewFlagSet("gen-dashboards", flag.ContinueOnError)
+	confDir := fs.String("conf", "./configs", "配置目录，读取其中的slo.toml及application.toml的[Metrics]")
+	outDir := fs.String("out", ".", "输出目录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := conf.Parse(*confDir); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("conf.Parse failed, dir:%s", *confDir))
+	}
+	defer conf.Stop()
+
+	slo, err := loadSLOConf()
+	if err != nil {
+		return err
+	}
+	mcfg, err := metrics.LoadConf()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load metrics conf failed, use default, err:%v\n", err)
+		mcfg = &metrics.MetricsConf{}
+	}
+	g, err := newGenerator(slo, mcfg)
+	if err != nil {
+		return err
+	}
+
+	dashboard, err := json.MarshalIndent(g.dashboard(), "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "json.MarshalIndent failed")
+	}
+	rules, err := yaml.Marshal(g.rules())
+	if err != nil {
+		return errors.Wrap(err, "yaml.Marshal failed")
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("os.MkdirAll failed, dir:%s", *outDir))
+	}
+	files := map[string][]byte{
+		g.service + "-dashboard.json": dashboard,
+		g.service + "-rules.yml":      rules,
+	}
+	for name, content := range files {
+		path := filepath.Join(*outDir, name)
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("ioutil.WriteFile failed, path:%s", path))
+		}
+		fmt.Println(path)
+	}
+	return nil
+}
+
+// 根据metrics定义生成PromQL
+type generator struct {
+	slo       *SLOConf
+	mcfg      *metrics.MetricsConf
+	service   string
+	namespace string
+	selector  []string
+	summary   bool
+	// SLO周期
+	window time.Duration
+}
+
+func newGenerator(slo *SLOConf, mcfg *metrics.MetricsConf) (*generator, error) {
+	g := &generator{
+		slo:       slo,
+		mcfg:      mcfg,
+		service:   slo.Service,
+		namespace: mcfg.Namespace,
+		summary:   mcfg.DurationType == metrics.DurationSummary,
+	}
+	if g.service == "" {
+		g.service = g.namespace
+	}
+	if g.service == "" {
+		return nil, errors.New("service and metrics namespace cannot both be empty")
+	}
+
+	if slo.Window == "" {
+		slo.Window = defSLOWindow
+	}
+	if !windowRe.MatchString(slo.Window) {
+		return nil, fmt.Errorf("invalid slo window, window:%s", slo.Window)
+	}
+	g.window = parseWindow(slo.Window)
+	// 周期须不短于告警的最长窗口
+	if longest := parseWindow(burnRates[len(burnRates)-1].long); g.window < longest {
+		return nil, fmt.Errorf("slo window must be at least %s, window:%s", burnRates[len(burnRates)-1].long, slo.Window)
+	}
+	if slo.Availability <= 0 || slo.Availability >= 100 {
+		return nil, fmt.Errorf("slo availability must be in (0, 100), availability:%v", slo.Availability)
+	}
+	if slo.Latency != 0 {
+		if slo.Latency < 0 || slo.Latency >= 100 {
+			return nil, fmt.Errorf("slo latency must be in (0, 100), latency:%v", slo.Latency)
+		}
+		if g.summary {
+			return nil, errors.New("latency slo requires histogram durationType")
+		}
+		if !g.isBucket(slo.LatencyThreshold) {
+			return nil, fmt.Errorf("slo latencyThreshold must be one of the histogram buckets, latencyThreshold:%v", slo.LatencyThreshold)
+		}
+	}
+
+	keys := make([]string, 0, len(slo.Selector))
+	for k := range slo.Selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g.selector = append(g.selector, k+"="+strconv.Quote(slo.Selector[k]))
+	}
+
+	return g, nil
+}
+
+// 解析PromQL时间格式，需先经windowRe校验
+func parseWindow(w string) time.Duration {
+	n, _ := strconv.Atoi(w[:len(w)-1])
+	unit := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}[w[len(w)-1]]
+	return time.Duration(n) * unit
+}
+
+func (g *generator) isBucket(v float64) bool {
+	buckets := g.mcfg.Buckets
+	if len(buckets) == 0 {
+		buckets = metrics.DefaultBuckets
+	}
+	for _, b := range buckets {
+		if b == v {
+			return true
+		}
+	}
+	return false
+}
+
+// 指标全名，suffix如_bucket、_count
+func (g *generator) name(d metrics.Definition, suffix string) string {
+	return d.FQName(g.namespace) + suffix
+}
+
+// 带服务选择标签的指标，matchers为额外的标签匹配
+func (g *generator) series(d metrics.Definition, suffix string, matchers ...string) string {
+	all := append(append([]string{}, g.selector...), matchers...)
+	if len(all) == 0 {
+		return g.name(d, suffix)
+	}
+	return g.name(d, suffix) + "{" + strings.Join(all, ",") + "}"
+}
+
+// 按by聚合的每秒增量
+func (g *generator) rate(d metrics.Definition, window string, by []string, matchers ...string) string {
+	return fmt.Sprintf("sum%s (rate(%s[%s]))", byClause(by), g.series(d, "", matchers...), window)
+}
+
+// histogram、summary按_count计算的每秒次数
+func (g *generator) countRate(d metrics.Definition, window string, by []string, matchers ...string) string {
+	return fmt.Sprintf("sum%s (rate(%s[%s]))", byClause(by), g.series(d, "_count", matchers...), window)
+}
+
+// 延迟分位数，summary时取已有的分位数
+func (g *generator) quantile(d metrics.Definition, q float64, window string, by []string, matchers ...string) string {
+	if g.summary && d.Name == metrics.HTTPDuration.Name {
+		qm := "quantile=" + strconv.Quote(formatFloat(q))
+		return fmt.Sprintf("max%s (%s)", byClause(by), g.series(d, "", append(matchers, qm)...))
+	}
+	return fmt.Sprintf("histogram_quantile(%s, sum%s (rate(%s[%s])))",
+		formatFloat(q), byClause(append([]string{"le"}, by...)), g.series(d, "_bucket", matchers...), window)
+}
+
+// 可用性SLI的错误率
+func (g *generator) availabilityErrorRatio(window string) string {
+	return fmt.Sprintf("%s / %s",
+		g.rate(metrics.HTTPRequestErrors, window, nil),
+		g.rate(metrics.HTTPRequests, window, nil))
+}
+
+// 延迟SLI的错误率，即超过阈值的请求占比
+func (g *generator) latencyErrorRatio(window string) string {
+	le := "le=" + strconv.Quote(formatFloat(g.slo.LatencyThreshold))
+	return fmt.Sprintf("1 - (sum (rate(%s[%s])) / %s)",
+		g.series(metrics.HTTPDuration, "_bucket", le), window,
+		g.countRate(metrics.HTTPDuration, window, nil))
+}
+
+// 连接池使用率，最大连接数为0(不限制)时不输出
+func (g *generator) saturation(used, max metrics.Definition, by []string) string {
+	return fmt.Sprintf("sum%s (%s) / (sum%s (%s) > 0)",
+		byClause(by), g.series(used, ""), byClause(by), g.series(max, ""))
+}
+
+// 错误预算，即允许的错误率
+func errorBudget(objective float64) float64 {
+	return 1 - objective/100
+}
+
+func byClause(by []string) string {
+	if len(by) == 0 {
+		return ""
+	}
+	return " by (" + strings.Join(by, ", ") + ")"
+}
+
+// 去掉浮点运算误差，如14.4*0.001
+func formatRatio(v float64) string {
+	return formatFloat(math.Round(v*1e9) / 1e9)
+}
+
+// 与prometheus输出le、quantile标签的格式一致
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
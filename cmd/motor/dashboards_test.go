@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaimixu/motor/metrics"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGenDashboards(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "motor-dashboards")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(genDashboards([]string{"-conf", "../../test/configs", "-out", dir}))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "motor-rules.yml"))
+	require.NoError(err)
+	var rf ruleFile
+	require.NoError(yaml.Unmarshal(b, &rf))
+	require.Len(rf.Groups, 2)
+
+	records := make(map[string]string)
+	for _, r := range rf.Groups[0].Rules {
+		records[r.Record] = r.Expr
+	}
+	require.Equal(`sum by (path) (rate(motor_requests_total{app="motor"}[5m]))`, records["motor:http_requests:rate5m"])
+	require.Equal(`sum (rate(motor_requests_errcode_total{app="motor"}[1h])) / sum (rate(motor_requests_total{app="motor"}[1h]))`,
+		records["motor:slo_availability_errors:ratio_rate1h"])
+	require.Equal(`1 - (sum (rate(motor_duration_ms_bucket{app="motor",le="250"}[5m])) / sum (rate(motor_duration_ms_count{app="motor"}[5m])))`,
+		records["motor:slo_latency_errors:ratio_rate5m"])
+	require.Equal(`sum by (db, role) (motor_mysql_pool_in_use_connections{app="motor"}) / (sum by (db, role) (motor_mysql_pool_max_open_connections{app="motor"}) > 0)`,
+		records["motor:mysql_pool_saturation"])
+	require.Contains(records, "motor:redis_pool_saturation")
+
+	alerts := make(map[string][]rule)
+	for _, r := range rf.Groups[1].Rules {
+		alerts[r.Alert] = append(alerts[r.Alert], r)
+	}
+	require.Len(alerts["AvailabilityErrorBudgetBurn"], 2)
+	page := alerts["AvailabilityErrorBudgetBurn"][0]
+	require.Equal("page", page.Labels["severity"])
+	require.Equal("(motor:slo_availability_errors:ratio_rate1h > 0.0144 and motor:slo_availability_errors:ratio_rate5m > 0.0144) or "+
+		"(motor:slo_availability_errors:ratio_rate6h > 0.006 and motor:slo_availability_errors:ratio_rate30m > 0.006)", page.Expr)
+	require.Len(alerts["LatencyErrorBudgetBurn"], 2)
+	require.Equal("motor:mysql_pool_saturation > 0.8", alerts["MysqlPoolSaturation"][0].Expr)
+	require.Len(alerts["RedisPoolSaturation"], 1)
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "motor-dashboard.json"))
+	require.NoError(err)
+	var d dashboard
+	require.NoError(json.Unmarshal(b, &d))
+	require.Equal("motor-red", d.UID)
+	var rows []string
+	var exprs []string
+	for i, p := range d.Panels {
+		require.Equal(i+1, p.ID)
+		require.True(p.GridPos.X+p.GridPos.W <= 24)
+		if p.Type == "row" {
+			rows = append(rows, p.Title)
+		}
+		for _, t := range p.Targets {
+			exprs = append(exprs, t.Expr)
+		}
+	}
+	require.Equal([]string{"SLO (30d)", "HTTP", "MySQL", "Redis"}, rows)
+	all := strings.Join(exprs, "\n")
+	require.Contains(all, `histogram_quantile(0.99, sum by (le, path) (rate(motor_duration_ms_bucket{app="motor",path=~"$path"}[5m])))`)
+	require.Contains(all, `motor_requests_in_flight{app="motor"}`)
+	require.Contains(all, `motor_redis_pool_max_active_connections{app="motor"}`)
+}
+
+func TestNewGenerator(t *testing.T) {
+	require := require.New(t)
+
+	mcfg := &metrics.MetricsConf{Namespace: "svc"}
+	g, err := newGenerator(&SLOConf{Availability: 99.5}, mcfg)
+	require.NoError(err)
+	require.Equal("svc", g.service)
+	require.Equal("30d", g.slo.Window)
+	require.Equal("svc_requests_total", g.series(metrics.HTTPRequests, ""))
+
+	_, err = newGenerator(&SLOConf{Availability: 99.5}, &metrics.MetricsConf{})
+	require.Error(err)
+	_, err = newGenerator(&SLOConf{Availability: 100}, mcfg)
+	require.Error(err)
+	_, err = newGenerator(&SLOConf{Availability: 99.5, Window: "1 month"}, mcfg)
+	require.Error(err)
+	// 周期短于告警的最长窗口
+	_, err = newGenerator(&SLOConf{Availability: 99.5, Window: "1d"}, mcfg)
+	require.Error(err)
+
+	// 燃烧率阈值按周期缩放
+	g, err = newGenerator(&SLOConf{Availability: 99.5, Window: "1w"}, mcfg)
+	require.NoError(err)
+	require.Equal(7*24*time.Hour, g.window)
+	alerts := g.burnRateAlerts("Availability", "availability", 99.5)
+	require.Equal("(svc:slo_availability_errors:ratio_rate1h > 0.0168 and svc:slo_availability_errors:ratio_rate5m > 0.0168) or "+
+		"(svc:slo_availability_errors:ratio_rate6h > 0.007 and svc:slo_availability_errors:ratio_rate30m > 0.007)", alerts[0].Expr)
+	// 延迟阈值须为bucket之一
+	_, err = newGenerator(&SLOConf{Availability: 99.5, Latency: 99, LatencyThreshold: 300}, mcfg)
+	require.Error(err)
+	_, err = newGenerator(&SLOConf{Availability: 99.5, Latency: 99, LatencyThreshold: 250},
+		&metrics.MetricsConf{Namespace: "svc", DurationType: metrics.DurationSummary})
+	require.Error(err)
+
+	// summary时直接使用已有的分位数
+	g, err = newGenerator(&SLOConf{Availability: 99.5},
+		&metrics.MetricsConf{Namespace: "svc", DurationType: metrics.DurationSummary})
+	require.NoError(err)
+	require.Equal(`max by (path) (svc_duration_ms{quantile="0.99"})`,
+		g.quantile(metrics.HTTPDuration, 0.99, "5m", []string{"path"}))
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kaimixu/motor/metrics"
+	"github.com/kaimixu/motor/mysql"
+	"github.com/kaimixu/motor/redis"
+)
+
+const (
+	grafanaSchemaVersion = 27
+	datasourceVar        = "$datasource"
+	// 每行三个面板
+	panelWidth  = 8
+	panelHeight = 8
+)
+
+type dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	Timezone      string     `json:"timezone"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []*panel   `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []templateVar `json:"list"`
+}
+
+type templateVar struct {
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	Type       string `json:"type"`
+	Query      string `json:"query"`
+	Datasource string `json:"datasource,omitempty"`
+	Multi      bool   `json:"multi"`
+	IncludeAll bool   `json:"includeAll"`
+	AllValue   string `json:"allValue,omitempty"`
+	Refresh    int    `json:"refresh"`
+}
+
+type panel struct {
+	ID          int          `json:"id"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Datasource  string       `json:"datasource,omitempty"`
+	GridPos     gridPos      `json:"gridPos"`
+	Targets     []target     `json:"targets,omitempty"`
+	FieldConfig *fieldConfig `json:"fieldConfig,omitempty"`
+}
+
+type gridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+}
+
+type fieldConfig struct {
+	Defaults  fieldDefaults `json:"defaults"`
+	Overrides []struct{}    `json:"overrides"`
+}
+
+type fieldDefaults struct {
+	Unit string   `json:"unit,omitempty"`
+	Min  *float64 `json:"min,omitempty"`
+	Max  *float64 `json:"max,omitempty"`
+}
+
+// 按行依次排列面板
+type layout struct {
+	panels []*panel
+	x, y   int
+}
+
+func (l *layout) row(title string) {
+	if l.x > 0 {
+		l.x, l.y = 0, l.y+panelHeight
+	}
+	l.panels = append(l.panels, &panel{
+		ID:      len(l.panels) + 1,
+		Type:    "row",
+		Title:   title,
+		GridPos: gridPos{X: 0, Y: l.y, W: 24, H: 1},
+	})
+	l.y++
+}
+
+func (l *layout) add(typ, title, unit string, targets ...target) *panel {
+	if l.x+panelWidth > 24 {
+		l.x, l.y = 0, l.y+panelHeight
+	}
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+	}
+	p := &panel{
+		ID:          len(l.panels) + 1,
+		Type:        typ,
+		Title:       title,
+		Datasource:  datasourceVar,
+		GridPos:     gridPos{X: l.x, Y: l.y, W: panelWidth, H: panelHeight},
+		Targets:     targets,
+		FieldConfig: &fieldConfig{Defaults: fieldDefaults{Unit: unit}, Overrides: []struct{}{}},
+	}
+	l.panels = append(l.panels, p)
+	l.x += panelWidth
+	return p
+}
+
+func (g *generator) dashboard() *dashboard {
+	l := &layout{}
+	g.sloPanels(l)
+	g.httpPanels(l)
+	g.mysqlPanels(l)
+	g.redisPanels(l)
+
+	return &dashboard{
+		UID:           g.service + "-red",
+		Title:         g.service + " RED & SLO",
+		Tags:          []string{"motor", g.service},
+		Timezone:      "browser",
+		SchemaVersion: grafanaSchemaVersion,
+		Refresh:       "30s",
+		Time:          timeRange{From: "now-6h", To: "now"},
+		Templating: templating{List: []templateVar{
+			{Name: "datasource", Label: "数据源", Type: "datasource", Query: "prometheus"},
+			{
+				Name:       "path",
+				Label:      "路由",
+				Type:       "query",
+				Query:      fmt.Sprintf("label_values(%s, path)", g.series(metrics.HTTPRequests, "")),
+				Datasource: datasourceVar,
+				Multi:      true,
+				IncludeAll: true,
+				AllValue:   ".*",
+				Refresh:    2,
+			},
+		}},
+		Panels: l.panels,
+	}
+}
+
+func (g *generator) sloPanels(l *layout) {
+	w := g.slo.Window
+	budget := formatRatio(errorBudget(g.slo.Availability))
+	errRatio := fmt.Sprintf("(sum (increase(%s[%s])) / sum (increase(%s[%s])))",
+		g.series(metrics.HTTPRequestErrors, ""), w, g.series(metrics.HTTPRequests, ""), w)
+
+	l.row(fmt.Sprintf("SLO (%s)", w))
+	availability := l.add("stat", fmt.Sprintf("可用性 (目标%s%%)", formatFloat(g.slo.Availability)), "percentunit",
+		target{Expr: "1 - " + errRatio})
+	availability.FieldConfig.Defaults.Min = float64Ptr(0)
+	availability.FieldConfig.Defaults.Max = float64Ptr(1)
+	l.add("stat", "可用性错误预算剩余", "percentunit",
+		target{Expr: fmt.Sprintf("1 - %s / %s", errRatio, budget)})
+	l.add("timeseries", "可用性错误预算燃烧率", "none",
+		target{Expr: fmt.Sprintf("(%s) / %s", g.availabilityErrorRatio("1h"), budget), LegendFormat: "1h"},
+		target{Expr: fmt.Sprintf("(%s) / %s", g.availabilityErrorRatio("6h"), budget), LegendFormat: "6h"})
+
+	if g.slo.Latency > 0 {
+		budget := formatRatio(errorBudget(g.slo.Latency))
+		l.add("stat", fmt.Sprintf("%sms内完成的请求占比 (目标%s%%)", formatFloat(g.slo.LatencyThreshold), formatFloat(g.slo.Latency)), "percentunit",
+			target{Expr: fmt.Sprintf("1 - (%s)", g.latencyErrorRatio(w))})
+		l.add("stat", "延迟错误预算剩余", "percentunit",
+			target{Expr: fmt.Sprintf("1 - (%s) / %s", g.latencyErrorRatio(w), budget)})
+		l.add("timeseries", "延迟错误预算燃烧率", "none",
+			target{Expr: fmt.Sprintf("(%s) / %s", g.latencyErrorRatio("1h"), budget), LegendFormat: "1h"},
+			target{Expr: fmt.Sprintf("(%s) / %s", g.latencyErrorRatio("6h"), budget), LegendFormat: "6h"})
+	}
+}
+
+func (g *generator) httpPanels(l *layout) {
+	path := []string{"path"}
+	pathMatcher := `path=~"$path"`
+
+	l.row("HTTP")
+	l.add("timeseries", "QPS", "reqps",
+		target{Expr: g.rate(metrics.HTTPRequests, "5m", path, pathMatcher), LegendFormat: "{{path}}"})
+	l.add("timeseries", "5xx错误数", "reqps",
+		target{Expr: g.rate(metrics.HTTPRequestErrors, "5m", []string{"path", "code"}, pathMatcher), LegendFormat: "{{path}} {{code}}"})
+	var durations []target
+	if g.summary {
+		durations = append(durations, target{
+			Expr:         fmt.Sprintf("max by (path, quantile) (%s)", g.series(metrics.HTTPDuration, "", pathMatcher)),
+			LegendFormat: "{{path}} p{{quantile}}",
+		})
+	} else {
+		for _, q := range []float64{0.5, 0.9, 0.99} {
+			durations = append(durations, target{
+				Expr:         g.quantile(metrics.HTTPDuration, q, "5m", path, pathMatcher),
+				LegendFormat: fmt.Sprintf("{{path}} p%s", formatFloat(q*100)),
+			})
+		}
+	}
+	l.add("timeseries", "耗时", "ms", durations...)
+	if g.mcfg.InFlight {
+		l.add("timeseries", "处理中的请求数", "none",
+			target{Expr: fmt.Sprintf("sum (%s)", g.series(metrics.HTTPInFlight, ""))})
+	}
+}
+
+func (g *generator) mysqlPanels(l *layout) {
+	dbOp := []string{"db", "op"}
+	dbRole := []string{"db", "role"}
+
+	l.row("MySQL")
+	l.add("timeseries", "QPS", "reqps",
+		target{Expr: g.countRate(mysql.QueryDuration, "5m", dbOp), LegendFormat: "{{db}} {{op}}"})
+	l.add("timeseries", "错误数", "reqps",
+		target{Expr: g.rate(mysql.QueryErrors, "5m", dbOp), LegendFormat: "{{db}} {{op}}"})
+	l.add("timeseries", "p99耗时", "ms",
+		target{Expr: g.quantile(mysql.QueryDuration, 0.99, "5m", dbOp), LegendFormat: "{{db}} {{op}}"})
+	l.add("timeseries", "连接池使用率", "percentunit",
+		target{Expr: g.saturation(mysql.PoolInUse, mysql.PoolMaxOpen, dbRole), LegendFormat: "{{db}} {{role}}"})
+	l.add("timeseries", "等待连接次数", "ops",
+		target{Expr: g.rate(mysql.PoolWait, "5m", dbRole), LegendFormat: "{{db}} {{role}}"})
+}
+
+func (g *generator) redisPanels(l *layout) {
+	cmd := []string{"cluster", "command"}
+	clusterRole := []string{"cluster", "role"}
+
+	l.row("Redis")
+	l.add("timeseries", "QPS", "reqps",
+		target{Expr: g.countRate(redis.CommandDuration, "5m", cmd), LegendFormat: "{{cluster}} {{command}}"})
+	l.add("timeseries", "错误数", "reqps",
+		target{Expr: g.rate(redis.CommandErrors, "5m", cmd), LegendFormat: "{{cluster}} {{command}}"})
+	l.add("timeseries", "p99耗时", "ms",
+		target{Expr: g.quantile(redis.CommandDuration, 0.99, "5m", cmd), LegendFormat: "{{cluster}} {{command}}"})
+	l.add("timeseries", "连接池使用率", "percentunit",
+		target{Expr: g.saturation(redis.PoolActive, redis.PoolMaxActive, clusterRole), LegendFormat: "{{cluster}} {{role}}"})
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
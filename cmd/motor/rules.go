@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kaimixu/motor/metrics"
+	"github.com/kaimixu/motor/mysql"
+	"github.com/kaimixu/motor/redis"
+)
+
+// prometheus规则文件
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// 多窗口多燃烧率告警，参考Google SRE Workbook，factor按30天周期计算，
+// 其他周期按周期/30d缩放，使各窗口告警时消耗的预算比例不变
+type burnRate struct {
+	long, short string
+	factor      float64
+	severity    string
+}
+
+var burnRates = []burnRate{
+	{long: "1h", short: "5m", factor: 14.4, severity: "page"},
+	{long: "6h", short: "30m", factor: 6, severity: "page"},
+	{long: "1d", short: "2h", factor: 3, severity: "ticket"},
+	{long: "3d", short: "6h", factor: 1, severity: "ticket"},
+}
+
+// factor对应的SLO周期
+const burnRateWindow = 30 * 24 * time.Hour
+
+// 告警用到的SLI窗口
+var sliWindows = []string{"5m", "30m", "1h", "2h", "6h", "1d", "3d"}
+
+func (g *generator) record(name string) string {
+	return g.service + ":" + name
+}
+
+func (g *generator) rules() *ruleFile {
+	return &ruleFile{
+		Groups: []ruleGroup{
+			{Name: g.service + ".rules", Rules: g.recordingRules()},
+			{Name: g.service + ".alerts", Rules: g.alertingRules()},
+		},
+	}
+}
+
+func (g *generator) recordingRules() []rule {
+	path := []string{"path"}
+	rules := []rule{
+		{Record: g.record("http_requests:rate5m"), Expr: g.rate(metrics.HTTPRequests, "5m", path)},
+		{Record: g.record("http_errors:rate5m"), Expr: g.rate(metrics.HTTPRequestErrors, "5m", path)},
+		{Record: g.record("http_duration_ms:p99_5m"), Expr: g.quantile(metrics.HTTPDuration, 0.99, "5m", path)},
+	}
+	for _, w := range sliWindows {
+		rules = append(rules, rule{
+			Record: g.record("slo_availability_errors:ratio_rate" + w),
+			Expr:   g.availabilityErrorRatio(w),
+		})
+	}
+	if g.slo.Latency > 0 {
+		for _, w := range sliWindows {
+			rules = append(rules, rule{
+				Record: g.record("slo_latency_errors:ratio_rate" + w),
+				Expr:   g.latencyErrorRatio(w),
+			})
+		}
+	}
+
+	dbOp := []string{"db", "op"}
+	dbRole := []string{"db", "role"}
+	rules = append(rules,
+		rule{Record: g.record("mysql_queries:rate5m"), Expr: g.countRate(mysql.QueryDuration, "5m", dbOp)},
+		rule{Record: g.record("mysql_errors:rate5m"), Expr: g.rate(mysql.QueryErrors, "5m", dbOp)},
+		rule{Record: g.record("mysql_query_duration_ms:p99_5m"), Expr: g.quantile(mysql.QueryDuration, 0.99, "5m", dbOp)},
+		rule{Record: g.record("mysql_pool_saturation"), Expr: g.saturation(mysql.PoolInUse, mysql.PoolMaxOpen, dbRole)},
+	)
+
+	cmd := []string{"cluster", "command"}
+	clusterRole := []string{"cluster", "role"}
+	rules = append(rules,
+		rule{Record: g.record("redis_commands:rate5m"), Expr: g.countRate(redis.CommandDuration, "5m", cmd)},
+		rule{Record: g.record("redis_errors:rate5m"), Expr: g.rate(redis.CommandErrors, "5m", cmd)},
+		rule{Record: g.record("redis_command_duration_ms:p99_5m"), Expr: g.quantile(redis.CommandDuration, 0.99, "5m", cmd)},
+		rule{Record: g.record("redis_pool_saturation"), Expr: g.saturation(redis.PoolActive, redis.PoolMaxActive, clusterRole)},
+	)
+	return rules
+}
+
+func (g *generator) alertingRules() []rule {
+	rules := g.burnRateAlerts("Availability", "availability", g.slo.Availability)
+	if g.slo.Latency > 0 {
+		rules = append(rules, g.burnRateAlerts("Latency", "latency", g.slo.Latency)...)
+	}
+
+	if g.slo.MysqlSaturation > 0 {
+		rules = append(rules, rule{
+			Alert:  "MysqlPoolSaturation",
+			Expr:   fmt.Sprintf("%s > %s", g.record("mysql_pool_saturation"), formatRatio(g.slo.MysqlSaturation)),
+			For:    "5m",
+			Labels: map[string]string{"severity": "ticket", "service": g.service},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s mysql连接池{{ $labels.db }}/{{ $labels.role }}使用率{{ $value | humanizePercentage }}", g.service),
+			},
+		})
+	}
+	if g.slo.RedisSaturation > 0 {
+		rules = append(rules, rule{
+			Alert:  "RedisPoolSaturation",
+			Expr:   fmt.Sprintf("%s > %s", g.record("redis_pool_saturation"), formatRatio(g.slo.RedisSaturation)),
+			For:    "5m",
+			Labels: map[string]string{"severity": "ticket", "service": g.service},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s redis连接池{{ $labels.cluster }}/{{ $labels.role }}使用率{{ $value | humanizePercentage }}", g.service),
+			},
+		})
+	}
+	return rules
+}
+
+// 长短窗口的燃烧率均超过阈值时告警，同级别的多个窗口用or合并
+func (g *generator) burnRateAlerts(name, sli string, objective float64) []rule {
+	budget := errorBudget(objective)
+	scale := float64(g.window) / float64(burnRateWindow)
+	var rules []rule
+	for _, severity := range []string{"page", "ticket"} {
+		var expr string
+		for _, br := range burnRates {
+			if br.severity != severity {
+				continue
+			}
+			threshold := formatRatio(br.factor * scale * budget)
+			cond := fmt.Sprintf("(%s > %s and %s > %s)",
+				g.record(fmt.Sprintf("slo_%s_errors:ratio_rate%s", sli, br.long)), threshold,
+				g.record(fmt.Sprintf("slo_%s_errors:ratio_rate%s", sli, br.short)), threshold)
+			if expr != "" {
+				expr += " or "
+			}
+			expr += cond
+		}
+
+		rules = append(rules, rule{
+			Alert:  name + "ErrorBudgetBurn",
+			Expr:   expr,
+			Labels: map[string]string{"severity": severity, "service": g.service, "slo": sli},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s %s SLO(%s%%)的错误预算消耗过快", g.service, sli, formatFloat(objective)),
+			},
+		})
+	}
+	return rules
+}
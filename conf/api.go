@@ -1,13 +1,20 @@
 package conf
 
-import "log"
+import (
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+)
 
 var g Client
 
-func Parse(path string) (err error) {
-	if g, err = newConf(path); err != nil {
+func Parse(path string) error {
+	c, err := newConf(path)
+	if err != nil {
 		return err
 	}
+	g = c
 
 	return nil
 }
@@ -16,6 +23,22 @@ func Get(key string) *Value {
 	return g.Get(key)
 }
 
+// 将配置文件name中的section解析到v，如UnmarshalSection("application.toml", "Server", &cfg)
+func UnmarshalSection(name, section string, v interface{}) error {
+	var st Storage
+	if err := Get(name).Unmarshal(&st); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Get(%s).Unmarshal failed", name))
+	}
+	val := st.Get(section)
+	if val == nil {
+		return fmt.Errorf("section %s not found in %s", section, name)
+	}
+	if err := val.UnmarshalTOML(v); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Get(%s).UnmarshalTOML failed", section))
+	}
+	return nil
+}
+
 func WatchEvent(keys ...string) <-chan Event {
 	return g.WatchEvent(keys...)
 }
@@ -24,6 +47,19 @@ func Dump() string {
 	return g.Dump()
 }
 
+// 返回脱敏后的全部配置，keys为额外需要脱敏的配置项名(不区分大小写，包含即匹配)
+func RedactedDump(keys ...string) string {
+	return g.RedactedDump(keys...)
+}
+
+// 配置加载状态，未加载或任一文件最近一次重新加载失败时返回错误
+func Status() error {
+	if g == nil {
+		return errors.New("conf not parsed")
+	}
+	return g.Status()
+}
+
 func Stop() {
 	g.Stop()
 }
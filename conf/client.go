@@ -25,4 +25,8 @@ type Client interface {
 	Stop()
 	// return all configuration as a string
 	Dump() string
+	// return all configuration with sensitive values redacted
+	RedactedDump(keys ...string) string
+	// return the error of the last reload, nil if ok
+	Status() error
 }
@@ -10,6 +10,7 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +32,9 @@ type conf struct {
 	watchChs map[string][]chan Event
 	wg       sync.WaitGroup
 	done     chan struct{}
+
+	// 各文件最近一次重新加载的错误，由Mutex保护
+	lastErrs map[string]error
 }
 
 func init() {
@@ -59,6 +63,7 @@ func newConf(path string) (*conf, error) {
 		content:  s,
 		watchChs: make(map[string][]chan Event),
 		done:     make(chan struct{}),
+		lastErrs: make(map[string]error),
 	}
 
 	c.wg.Add(1)
@@ -135,6 +140,40 @@ func (c *conf) Dump() string {
 	return buf.String()
 }
 
+// 逐个文件脱敏后的全部配置
+func (c *conf) RedactedDump(keys ...string) string {
+	m := c.content.Load()
+	var buf bytes.Buffer
+
+	for k, v := range m {
+		buf.WriteString(k)
+		buf.WriteString(": ")
+		buf.WriteString(redact(v.Raw(), keys...))
+	}
+
+	return buf.String()
+}
+
+// 返回所有最近一次重新加载失败的文件的错误
+func (c *conf) Status() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.lastErrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(c.lastErrs))
+	for k := range c.lastErrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		msgs = append(msgs, fmt.Sprintf("reload %s failed: %v", k, c.lastErrs[k]))
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
 // monitor file change
 func (c *conf) monitor() {
 	defer c.wg.Done()
@@ -183,8 +222,14 @@ func (c *conf) reloadFile(name string) {
 	val, err := readFile(name)
 	if err != nil {
 		log.Printf("readFile(%s) failed, error: %+v", name, err)
+		c.Lock()
+		c.lastErrs[key] = err
+		c.Unlock()
 		return
 	}
+	c.Lock()
+	delete(c.lastErrs, key)
+	c.Unlock()
 	c.raw[key] = &Value{raw: val}
 	c.content.Store(c.raw)
 
@@ -46,3 +46,31 @@ ip=127.0.0.2
 	conf.Stop()
 	require.Equal(conf.Get("http.toml").Raw(), data2, "should equal")
 }
+
+func TestStatus(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "motor-conf")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.toml", "b.toml"} {
+		require.NoError(ioutil.WriteFile(path.Join(dir, name), []byte("k = 1\n"), 0644))
+	}
+	c, err := newConf(dir)
+	require.NoError(err)
+	defer c.Stop()
+	require.NoError(c.Status())
+
+	// 其他文件重新加载成功不清除a.toml的错误
+	require.NoError(os.Remove(path.Join(dir, "a.toml")))
+	c.reloadFile(path.Join(dir, "a.toml"))
+	c.reloadFile(path.Join(dir, "b.toml"))
+	err = c.Status()
+	require.Error(err)
+	require.Contains(err.Error(), "a.toml")
+	require.NotContains(err.Error(), "b.toml")
+
+	require.NoError(ioutil.WriteFile(path.Join(dir, "a.toml"), []byte("k = 2\n"), 0644))
+	c.reloadFile(path.Join(dir, "a.toml"))
+	require.NoError(c.Status())
+}
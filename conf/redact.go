@@ -0,0 +1,90 @@
+package conf
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// 默认脱敏的配置项名
+var redactKeys = []string{"password", "passwd", "secret", "token", "credential", "privatekey", "authorization"}
+
+const redactedValue = "******"
+
+var assignRegexp = regexp.MustCompile(`^(\s*"?([\w.-]+)"?\s*[=:]\s*)(.*)$`)
+
+// 将敏感配置项的值替换为******，toml按解析后的配置项递归处理(含内联表、嵌套表及数组)，
+// 解析失败时按行匹配
+func redact(content string, keys ...string) string {
+	all := make([]string, 0, len(redactKeys)+len(keys))
+	all = append(all, redactKeys...)
+	for _, k := range keys {
+		all = append(all, strings.ToLower(k))
+	}
+
+	var m map[string]interface{}
+	if _, err := toml.Decode(content, &m); err != nil {
+		return redactLines(content, all)
+	}
+	redactMap(m, all)
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(m); err != nil {
+		return redactLines(content, all)
+	}
+	return buf.String()
+}
+
+func redactMap(m map[string]interface{}, keys []string) {
+	for k, v := range m {
+		if s, ok := v.(string); ok && s == "" {
+			continue
+		}
+		if sensitive(k, keys) {
+			m[k] = redactedValue
+			continue
+		}
+		redactValue(v, keys)
+	}
+}
+
+func redactValue(v interface{}, keys []string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		redactMap(v, keys)
+	case []map[string]interface{}:
+		for _, m := range v {
+			redactMap(m, keys)
+		}
+	case []interface{}:
+		for _, e := range v {
+			redactValue(e, keys)
+		}
+	}
+}
+
+func sensitive(name string, keys []string) bool {
+	name = strings.ToLower(name)
+	for _, k := range keys {
+		if strings.Contains(name, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactLines(content string, keys []string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		m := assignRegexp.FindStringSubmatch(line)
+		if m == nil || m[3] == "" || m[3] == `""` {
+			continue
+		}
+		if sensitive(m[2], keys) {
+			lines[i] = m[1] + `"` + redactedValue + `"`
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,55 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedact(t *testing.T) {
+	require := require.New(t)
+
+	content := `
+[etcd]
+username = "myuser"
+password = "123456"
+emptyPassword = ""
+auth = {user = "u", password = "p"}
+[Jwt]
+  signSecret = 'abc'
+apiKey = "k"
+[Exporter]
+headers = {Authorization = "Bearer token", "X-Env" = "test"}
+[[Outputs]]
+path = "stdout"
+[[Outputs]]
+path = "kafka"
+opts = [{name = "a", token = "t"}]
+`
+	var got map[string]interface{}
+	_, err := toml.Decode(redact(content, "ApiKey"), &got)
+	require.NoError(err)
+	require.Equal(map[string]interface{}{
+		"etcd": map[string]interface{}{
+			"username":      "myuser",
+			"password":      "******",
+			"emptyPassword": "",
+			"auth":          map[string]interface{}{"user": "u", "password": "******"},
+		},
+		"Jwt": map[string]interface{}{
+			"signSecret": "******",
+			"apiKey":     "******",
+		},
+		"Exporter": map[string]interface{}{
+			"headers": map[string]interface{}{"Authorization": "******", "X-Env": "test"},
+		},
+		"Outputs": []map[string]interface{}{
+			{"path": "stdout"},
+			{"path": "kafka", "opts": []map[string]interface{}{{"name": "a", "token": "******"}}},
+		},
+	}, got)
+
+	// 非toml内容按行匹配
+	require.Equal("password: \"******\"\nuser: u", redact("password: 123\nuser: u"))
+}
@@ -0,0 +1,98 @@
+package ecode
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	BadRequest         = New(http.StatusBadRequest, http.StatusBadRequest, "请求参数错误")
+	Unauthorized       = New(http.StatusUnauthorized, http.StatusUnauthorized, "未授权")
+	Forbidden          = New(http.StatusForbidden, http.StatusForbidden, "无访问权限")
+	NotFound           = New(http.StatusNotFound, http.StatusNotFound, "资源不存在")
+	MethodNotAllowed   = New(http.StatusMethodNotAllowed, http.StatusMethodNotAllowed, "请求方法不支持")
+	EntityTooLarge     = New(http.StatusRequestEntityTooLarge, http.StatusRequestEntityTooLarge, "请求体过大")
+	TooManyRequests    = New(http.StatusTooManyRequests, http.StatusTooManyRequests, "请求过于频繁")
+	ServerErr          = New(http.StatusInternalServerError, http.StatusInternalServerError, "服务内部错误")
+	ServiceUnavailable = New(http.StatusServiceUnavailable, http.StatusServiceUnavailable, "服务暂不可用")
+)
+
+// 统一的接口错误
+type Error struct {
+	// 业务错误码
+	Code int
+	// http状态码
+	Status int
+	// 返回给用户的错误信息
+	Message string
+	// 内部错误原因，仅记录日志，不返回给用户
+	Cause error
+	// 错误详情，如：参数校验失败的字段
+	Details map[string]interface{}
+}
+
+func New(code, status int, message string) *Error {
+	return &Error{
+		Code:    code,
+		Status:  status,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("code=%d, message=%s, cause=%v", e.Code, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("code=%d, message=%s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// 错误码相同即视为同一错误
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// 以下With*方法均返回副本，不会修改预定义的错误
+func (e *Error) WithCause(err error) *Error {
+	ne := e.clone()
+	ne.Cause = err
+	return ne
+}
+
+func (e *Error) WithMessage(message string) *Error {
+	ne := e.clone()
+	ne.Message = message
+	return ne
+}
+
+func (e *Error) WithDetail(key string, val interface{}) *Error {
+	ne := e.clone()
+	ne.Details[key] = val
+	return ne
+}
+
+func (e *Error) clone() *Error {
+	ne := *e
+	ne.Details = make(map[string]interface{}, len(e.Details))
+	for k, v := range e.Details {
+		ne.Details[k] = v
+	}
+	return &ne
+}
+
+// 将任意error转换为*Error，无法识别的错误视为服务内部错误
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ServerErr.WithCause(err)
+}
@@ -0,0 +1,31 @@
+package ecode
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestError(t *testing.T) {
+	require := require.New(t)
+
+	cause := errors.New("db timeout")
+	e := ServerErr.WithCause(cause).WithDetail("db", "test")
+	require.Nil(ServerErr.Cause)
+	require.Empty(ServerErr.Details)
+	require.True(errors.Is(e, ServerErr))
+	require.True(errors.Is(e, cause))
+	require.Equal("test", e.Details["db"])
+
+	wrapped := pkgerrors.WithMessage(NotFound.WithMessage("用户不存在"), "GetUser failed")
+	fe := FromError(wrapped)
+	require.Equal(http.StatusNotFound, fe.Status)
+	require.Equal("用户不存在", fe.Message)
+
+	fe = FromError(cause)
+	require.Equal(http.StatusInternalServerError, fe.Status)
+	require.Equal(cause, fe.Cause)
+}
@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	defTimeout = time.Second
+)
+
+// 依赖检查
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c *checkerFunc) Name() string {
+	return c.name
+}
+
+func (c *checkerFunc) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// 将函数包装为Checker
+func NewChecker(name string, fn func(ctx context.Context) error) Checker {
+	return &checkerFunc{name: name, fn: fn}
+}
+
+// 单项检查结果
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type entry struct {
+	checker Checker
+	timeout time.Duration
+}
+
+type Registry struct {
+	mutex    sync.RWMutex
+	checkers []entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// 注册检查项，timeout<=0时使用默认超时1s
+func (r *Registry) Register(c Checker, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defTimeout
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.checkers = append(r.checkers, entry{checker: c, timeout: timeout})
+}
+
+// 并发执行所有检查项，全部通过时ok=true
+func (r *Registry) Check(ctx context.Context) (ok bool, results []Result) {
+	r.mutex.RLock()
+	checkers := make([]entry, len(r.checkers))
+	copy(checkers, r.checkers)
+	r.mutex.RUnlock()
+
+	results = make([]Result, len(checkers))
+	var wg sync.WaitGroup
+	for i, e := range checkers {
+		wg.Add(1)
+		go func(i int, e entry) {
+			defer wg.Done()
+			results[i] = run(ctx, e)
+		}(i, e)
+	}
+	wg.Wait()
+
+	ok = true
+	for _, res := range results {
+		if res.Status != StatusUp {
+			ok = false
+		}
+	}
+	return
+}
+
+// 超时后不再等待检查项返回
+func run(ctx context.Context, e entry) Result {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- e.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{
+		Name:     e.checker.Name(),
+		Status:   StatusUp,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
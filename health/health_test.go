@@ -0,0 +1,37 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	require := require.New(t)
+
+	r := NewRegistry()
+	r.Register(NewChecker("ok", func(ctx context.Context) error {
+		return nil
+	}), 0)
+	ok, results := r.Check(context.Background())
+	require.True(ok)
+	require.Equal(StatusUp, results[0].Status)
+
+	r.Register(NewChecker("fail", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}), 0)
+	r.Register(NewChecker("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}), 50*time.Millisecond)
+
+	start := time.Now()
+	ok, results = r.Check(context.Background())
+	require.False(ok)
+	require.True(time.Since(start) < 500*time.Millisecond)
+	require.Equal("connection refused", results[1].Error)
+	require.Equal(context.DeadlineExceeded.Error(), results[2].Error)
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/ecode"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/metrics"
+	"github.com/kaimixu/motor/tolerant"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type AdminConf struct {
+	Addr string
+	// 均不为空时开启basic auth
+	Username string
+	Password string
+	// 允许访问的ip或网段，为空时不限制
+	AllowIPs []string
+	// conf.RedactedDump额外需要脱敏的配置项
+	RedactKeys []string
+}
+
+// 运维管理服务，与业务服务使用不同的监听地址
+type AdminServer struct {
+	*gin.Engine
+	srv  *http.Server
+	conf *AdminConf
+}
+
+// 从application.toml的[Admin]中加载配置
+func LoadAdminConf() (*AdminConf, error) {
+	var cfg AdminConf
+	if err := conf.UnmarshalSection("application.toml", "Admin", &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// 创建管理服务，挂载pprof、metrics、配置查看、日志级别及限流熔断规则，
+// app不为nil时同时挂载其健康检查
+func NewAdminServer(cfg *AdminConf, app *Server) (*AdminServer, error) {
+	allow, err := parseAllowIPs(cfg.AllowIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AdminServer{
+		Engine: gin.New(),
+		conf:   cfg,
+	}
+	a.Engine.Use(Recovery(), ipAllow(allow))
+	if cfg.Username != "" && cfg.Password != "" {
+		a.Engine.Use(basicAuth(cfg.Username, cfg.Password))
+	}
+
+	registerPprof(a.Engine.Group("/debug/pprof"))
+	a.Engine.GET(metrics.DefaultPath, metrics.MetricsHandler())
+	if app != nil {
+		a.Engine.GET(LivenessPath, app.Liveness())
+		a.Engine.GET(ReadinessPath, app.Readiness())
+	}
+	a.Engine.GET("/conf", func(c *gin.Context) {
+		c.String(http.StatusOK, conf.RedactedDump(cfg.RedactKeys...))
+	})
+	level := gin.WrapH(log.LevelHandler())
+	a.Engine.GET("/log/level", level)
+	a.Engine.PUT("/log/level", level)
+	a.Engine.GET("/sentinel/rules", func(c *gin.Context) {
+		c.JSON(http.StatusOK, tolerant.Rules())
+	})
+
+	return a, nil
+}
+
+// 开始监听，不阻塞
+func (a *AdminServer) Start() error {
+	ln, err := net.Listen("tcp", a.conf.Addr)
+	if err != nil {
+		return err
+	}
+	a.srv = &http.Server{Addr: a.conf.Addr, Handler: a.Engine}
+	go func() {
+		if err := a.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			zap.L().Error("admin server exited", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (a *AdminServer) Close(ctx context.Context) error {
+	if a.srv == nil {
+		return nil
+	}
+	return a.srv.Shutdown(ctx)
+}
+
+// 启动管理服务，并在业务服务退出时关闭
+func (s *Server) OpenAdmin(cfg *AdminConf) (*AdminServer, error) {
+	a, err := NewAdminServer(cfg, s)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Start(); err != nil {
+		return nil, err
+	}
+
+	// 等待请求处理完成后再关闭，便于排查退出过程中的问题
+	s.OnShutdown(StageStorage, "admin", a.Close)
+	return a, nil
+}
+
+func parseAllowIPs(ips []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(ips))
+	for _, ip := range ips {
+		if !strings.Contains(ip, "/") {
+			if strings.Contains(ip, ":") {
+				ip += "/128"
+			} else {
+				ip += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(ip)
+		if err != nil {
+			return nil, errors.Wrap(err, "net.ParseCIDR")
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// 仅根据连接的对端地址判断，不信任X-Forwarded-For等header
+func ipAllow(nets []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(nets) == 0 {
+			return
+		}
+		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if err != nil {
+			host = c.Request.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			for _, n := range nets {
+				if n.Contains(ip) {
+					return
+				}
+			}
+		}
+		AbortWithError(c, ecode.Forbidden)
+	}
+}
+
+func basicAuth(username, password string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, pass, ok := c.Request.BasicAuth()
+		if ok &&
+			subtle.ConstantTimeCompare([]byte(user), []byte(username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1 {
+			return
+		}
+		c.Header("WWW-Authenticate", `Basic realm="motor admin"`)
+		AbortWithError(c, ecode.Unauthorized)
+	}
+}
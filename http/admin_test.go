@@ -0,0 +1,75 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/tolerant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+
+	cfg, err := LoadAdminConf()
+	require.NoError(err)
+	require.Equal("127.0.0.1:18090", cfg.Addr)
+
+	admin, err := NewAdminServer(cfg, DefaultServer(&ServerConf{}))
+	require.NoError(err)
+
+	do := func(method, path, remote string, auth bool, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = remote
+		if auth {
+			req.SetBasicAuth("admin", "admin")
+		}
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, req)
+		return w
+	}
+
+	// ip不在白名单
+	w := do(http.MethodGet, "/conf", "192.168.1.1:1234", true, "")
+	require.Equal(http.StatusForbidden, w.Code)
+
+	// 未认证
+	w = do(http.MethodGet, "/conf", "10.1.2.3:1234", false, "")
+	require.Equal(http.StatusUnauthorized, w.Code)
+	require.NotEmpty(w.Header().Get("WWW-Authenticate"))
+
+	w = do(http.MethodGet, "/conf", "127.0.0.1:1234", true, "")
+	require.Equal(http.StatusOK, w.Code)
+	require.Contains(w.Body.String(), "[Admin]")
+	require.NotContains(w.Body.String(), `password = "admin"`)
+	// 内联表中的敏感配置项
+	require.NotContains(w.Body.String(), "Bearer token")
+
+	w = do(http.MethodPut, "/log/level", "127.0.0.1:1234", true, `{"level":"warn"}`)
+	require.Equal(http.StatusOK, w.Code)
+	w = do(http.MethodGet, "/log/level", "127.0.0.1:1234", true, "")
+	require.Contains(w.Body.String(), "warn")
+	do(http.MethodPut, "/log/level", "127.0.0.1:1234", true, `{"level":"info"}`)
+
+	w = do(http.MethodGet, "/debug/pprof/", "127.0.0.1:1234", true, "")
+	require.Equal(http.StatusOK, w.Code)
+	// 包含http client按host添加的熔断规则
+	require.NoError(tolerant.AddBreakerRules(&circuitbreaker.Rule{
+		Resource:         "httpclient:admin-test",
+		Strategy:         circuitbreaker.ErrorCount,
+		RetryTimeoutMs:   1000,
+		MinRequestAmount: 10,
+		StatIntervalMs:   1000,
+		Threshold:        5,
+	}))
+	w = do(http.MethodGet, "/sentinel/rules", "127.0.0.1:1234", true, "")
+	require.Equal(http.StatusOK, w.Code)
+	require.Contains(w.Body.String(), `"httpclient:admin-test"`)
+	w = do(http.MethodGet, LivenessPath, "127.0.0.1:1234", true, "")
+	require.Equal(http.StatusOK, w.Code)
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/authz"
+	"github.com/kaimixu/motor/ecode"
+	"go.uber.org/zap"
+)
+
+// 要求token具备全部指定的scope，需置于Jwt中间件之后
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			authzDeny(c, ecode.Unauthorized, "missing jwt claims")
+			return
+		}
+		if err := authz.HasScopes(authz.Scopes(claims), scopes...); err != nil {
+			authzDeny(c, ecode.Forbidden, err.Error())
+			return
+		}
+	}
+}
+
+// 要求token具备任一指定的角色，需置于Jwt中间件之后
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			authzDeny(c, ecode.Unauthorized, "missing jwt claims")
+			return
+		}
+		if err := authz.HasAnyRole(authz.Roles(claims), roles...); err != nil {
+			authzDeny(c, ecode.Forbidden, err.Error())
+			return
+		}
+	}
+}
+
+// 按authz.toml中的策略鉴权，未匹配到策略的请求直接放行，需先调用authz.Init
+func Authz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy, ok := authz.Match(c.Request.Method, c.FullPath(), c.Request.URL.Path)
+		if !ok {
+			return
+		}
+
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			authzDeny(c, ecode.Unauthorized, "missing jwt claims")
+			return
+		}
+		if err := policy.Check(authz.Scopes(claims), authz.Roles(claims)); err != nil {
+			authzDeny(c, ecode.Forbidden, err.Error())
+			return
+		}
+	}
+}
+
+// 日志中的subject由FromGin从jwt claims中获取
+func authzDeny(c *gin.Context, e *ecode.Error, reason string) {
+	FromGin(c).Warn("authz denied",
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("clientIp", c.ClientIP()),
+		zap.String("reason", reason),
+	)
+
+	AbortWithError(c, e.WithCause(errors.New(reason)))
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/authz"
+	"github.com/kaimixu/motor/conf"
+	motorjwt "github.com/kaimixu/motor/jwt"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAuthz(t *testing.T) {
+	require := require.New(t)
+	gin.SetMode(gin.TestMode)
+	require.Nil(conf.Parse("../test/configs"))
+	authz.Init()
+
+	core, logs := observer.New(zap.DebugLevel)
+	defer zap.ReplaceGlobals(zap.L())
+	zap.ReplaceGlobals(zap.New(core))
+
+	users := map[string]*motorjwt.MotorClaims{
+		"writer": {
+			StandardClaims: jwt.StandardClaims{Subject: "writer"},
+			Data:           map[string]interface{}{"scopes": "orders:read orders:write"},
+		},
+		"reader": {
+			StandardClaims: jwt.StandardClaims{Subject: "reader"},
+			Data:           map[string]interface{}{"scopes": []interface{}{"orders:read"}},
+		},
+		"admin": {
+			StandardClaims: jwt.StandardClaims{Subject: "admin"},
+			Data:           map[string]interface{}{"auth": map[string]interface{}{"roles": []interface{}{"ops"}}},
+		},
+	}
+
+	engine := gin.New()
+	// 模拟Jwt中间件按X-User设置claims
+	engine.Use(func(c *gin.Context) {
+		if claims, ok := users[c.GetHeader("X-User")]; ok {
+			c.Set(motorjwt.ClaimsKey, claims)
+		}
+	})
+	ok := func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	}
+	engine.GET("/scoped", RequireScopes("orders:read", "orders:write"), ok)
+	engine.GET("/role", RequireRole("admin", "ops"), ok)
+	policy := engine.Group("/", Authz())
+	policy.POST("/orders/:id", ok)
+	policy.GET("/admin/users", ok)
+	policy.GET("/public", ok)
+
+	cases := []struct {
+		method, path, user string
+		status             int
+	}{
+		// 缺少claims
+		{http.MethodGet, "/scoped", "", http.StatusUnauthorized},
+		{http.MethodGet, "/role", "", http.StatusUnauthorized},
+		{http.MethodPost, "/orders/1", "", http.StatusUnauthorized},
+		// scope或角色不满足
+		{http.MethodGet, "/scoped", "reader", http.StatusForbidden},
+		{http.MethodGet, "/role", "writer", http.StatusForbidden},
+		{http.MethodPost, "/orders/1", "reader", http.StatusForbidden},
+		{http.MethodGet, "/admin/users", "writer", http.StatusForbidden},
+		// 满足要求
+		{http.MethodGet, "/scoped", "writer", http.StatusOK},
+		{http.MethodGet, "/role", "admin", http.StatusOK},
+		{http.MethodPost, "/orders/1", "writer", http.StatusOK},
+		{http.MethodGet, "/admin/users", "admin", http.StatusOK},
+		// 未匹配到策略时放行
+		{http.MethodGet, "/public", "", http.StatusOK},
+	}
+	for _, cs := range cases {
+		req := httptest.NewRequest(cs.method, cs.path, nil)
+		if cs.user != "" {
+			req.Header.Set("X-User", cs.user)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		require.Equal(cs.status, w.Code, "%s %s %s", cs.method, cs.path, cs.user)
+	}
+
+	// 拒绝时记录subject及原因
+	denied := logs.FilterMessage("authz denied").All()
+	require.Len(denied, 7)
+	fields := denied[3].ContextMap()
+	require.Equal("reader", fields["subject"])
+	require.Equal("/scoped", fields["path"])
+	require.NotEmpty(fields["reason"])
+	require.NotContains(denied[0].ContextMap(), "subject")
+}
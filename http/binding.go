@@ -0,0 +1,244 @@
+package http
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/kaimixu/motor/ecode"
+)
+
+// 参数校验失败的字段
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// 将校验错误翻译为返回给用户的信息，field为按json/form等tag解析后的字段名
+type Translator func(c *gin.Context, fe validator.FieldError, field string) string
+
+var (
+	translator Translator = ZhTranslator
+)
+
+// 设置全局的校验信息翻译函数
+func SetTranslator(t Translator) {
+	translator = t
+}
+
+// 依次绑定uri、query、header及body参数到obj，全部绑定完成后统一校验
+// 返回的错误为*ecode.Error，校验失败的字段位于Details["fields"]
+func Bind(c *gin.Context, obj interface{}) error {
+	var binds []func() error
+	if hasTag(obj, "uri") {
+		binds = append(binds, func() error {
+			params := make(map[string][]string, len(c.Params))
+			for _, p := range c.Params {
+				params[p.Key] = []string{p.Value}
+			}
+			return binding.Uri.BindUri(params, obj)
+		})
+	}
+	if hasTag(obj, "form") {
+		binds = append(binds, func() error {
+			return binding.Query.Bind(c.Request, obj)
+		})
+	}
+	if hasTag(obj, "header") {
+		binds = append(binds, func() error {
+			return binding.Header.Bind(c.Request, obj)
+		})
+	}
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		binds = append(binds, func() error {
+			return binding.Default(c.Request.Method, c.ContentType()).Bind(c.Request, obj)
+		})
+	}
+
+	// gin的各binding在绑定后都会校验，此时部分字段尚未绑定，故忽略中间的校验错误
+	for _, bind := range binds {
+		if err := bind(); err != nil {
+			if _, ok := err.(validator.ValidationErrors); !ok {
+				return ecode.BadRequest.WithCause(err)
+			}
+		}
+	}
+
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return validationError(c, obj, err)
+	}
+	return nil
+}
+
+func validationError(c *gin.Context, obj interface{}, err error) error {
+	verrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return ecode.BadRequest.WithCause(err)
+	}
+
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		name := fieldName(reflect.TypeOf(obj), fe.StructNamespace())
+		fields = append(fields, FieldError{
+			Field:   name,
+			Tag:     fe.Tag(),
+			Param:   fe.Param(),
+			Message: translator(c, fe, name),
+		})
+	}
+
+	return ecode.BadRequest.
+		WithMessage(fields[0].Message).
+		WithCause(err).
+		WithDetail("fields", fields)
+}
+
+// 将Struct.Field.Sub形式的命名空间转换为按tag命名的字段路径
+func fieldName(t reflect.Type, namespace string) string {
+	parts := strings.Split(namespace, ".")
+	if len(parts) > 1 {
+		// 去掉顶层结构体名
+		parts = parts[1:]
+	}
+
+	names := make([]string, 0, len(parts))
+	for _, part := range parts {
+		fname, index := part, ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			fname, index = part[:i], part[i:]
+		}
+
+		t = indirectType(t)
+		if t.Kind() != reflect.Struct {
+			names = append(names, part)
+			continue
+		}
+		sf, ok := t.FieldByName(fname)
+		if !ok {
+			names = append(names, part)
+			continue
+		}
+		names = append(names, tagName(sf)+index)
+
+		t = sf.Type
+		if index != "" {
+			t = indirectType(t).Elem()
+		}
+	}
+
+	return strings.Join(names, ".")
+}
+
+func tagName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri", "header"} {
+		name := strings.Split(sf.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+func hasTag(obj interface{}, tag string) bool {
+	t := indirectType(reflect.TypeOf(obj))
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if _, ok := sf.Tag.Lookup(tag); ok {
+			return true
+		}
+		if sf.Anonymous && hasTag(reflect.New(indirectType(sf.Type)).Interface(), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+var zhMessages = map[string]string{
+	"required": "%s为必填字段",
+	"email":    "%s必须是有效的邮箱地址",
+	"url":      "%s必须是有效的URL",
+	"numeric":  "%s必须是数字",
+	"alphanum": "%s只能包含字母和数字",
+	"oneof":    "%s必须是[%s]中的一个",
+	"eq":       "%s必须等于%s",
+	"ne":       "%s不能等于%s",
+	"gt":       "%s必须大于%s",
+	"gte":      "%s必须大于或等于%s",
+	"lt":       "%s必须小于%s",
+	"lte":      "%s必须小于或等于%s",
+	"min":      "%s最小为%s",
+	"max":      "%s最大为%s",
+	"len":      "%s必须等于%s",
+	"min_len":  "%s长度不能小于%s",
+	"max_len":  "%s长度不能超过%s",
+	"len_len":  "%s长度必须为%s",
+	"default":  "%s格式错误",
+}
+
+var enMessages = map[string]string{
+	"required": "%s is a required field",
+	"email":    "%s must be a valid email address",
+	"url":      "%s must be a valid URL",
+	"numeric":  "%s must be a valid numeric value",
+	"alphanum": "%s can only contain alphanumeric characters",
+	"oneof":    "%s must be one of [%s]",
+	"eq":       "%s must be equal to %s",
+	"ne":       "%s must not be equal to %s",
+	"gt":       "%s must be greater than %s",
+	"gte":      "%s must be greater than or equal to %s",
+	"lt":       "%s must be less than %s",
+	"lte":      "%s must be less than or equal to %s",
+	"min":      "%s must be %s or greater",
+	"max":      "%s must be %s or less",
+	"len":      "%s must be equal to %s",
+	"min_len":  "%s must be at least %s characters in length",
+	"max_len":  "%s must be a maximum of %s characters in length",
+	"len_len":  "%s must be %s characters in length",
+	"default":  "%s is invalid",
+}
+
+// 中文校验信息
+func ZhTranslator(c *gin.Context, fe validator.FieldError, field string) string {
+	return translate(zhMessages, fe, field)
+}
+
+// 英文校验信息
+func EnTranslator(c *gin.Context, fe validator.FieldError, field string) string {
+	return translate(enMessages, fe, field)
+}
+
+func translate(messages map[string]string, fe validator.FieldError, field string) string {
+	tag := fe.Tag()
+	switch tag {
+	case "min", "max", "len":
+		// 字符串、数组等按长度描述
+		switch fe.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			tag = tag + "_len"
+		}
+	}
+
+	msg, ok := messages[tag]
+	if !ok {
+		return fmt.Sprintf(messages["default"], field)
+	}
+	if strings.Count(msg, "%s") == 1 {
+		return fmt.Sprintf(msg, field)
+	}
+	return fmt.Sprintf(msg, field, fe.Param())
+}
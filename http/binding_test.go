@@ -0,0 +1,83 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type bindAddress struct {
+	City string `json:"city" binding:"required"`
+}
+
+type bindReq struct {
+	ID      int64         `uri:"id" binding:"required"`
+	Page    int           `form:"page" binding:"gte=1"`
+	Token   string        `header:"X-Token" binding:"required"`
+	Name    string        `json:"name" binding:"required,max=4"`
+	Address []bindAddress `json:"address" binding:"dive"`
+}
+
+func TestBind(t *testing.T) {
+	require := require.New(t)
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(ErrorRender())
+	engine.POST("/user/:id", Handle(func(c *gin.Context) error {
+		var req bindReq
+		if err := Bind(c, &req); err != nil {
+			return err
+		}
+		c.JSON(http.StatusOK, req)
+		return nil
+	}))
+
+	// 全部参数合法
+	req := httptest.NewRequest(http.MethodPost, "/user/10?page=2", strings.NewReader(`{"name":"tom","address":[{"city":"bj"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Token", "abc")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	require.Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var got bindReq
+	require.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	require.Equal(bindReq{ID: 10, Page: 2, Token: "abc", Name: "tom", Address: []bindAddress{{City: "bj"}}}, got)
+
+	// 校验失败
+	req = httptest.NewRequest(http.MethodPost, "/user/10?page=0", strings.NewReader(`{"name":"jerry","address":[{}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	require.Equal(http.StatusBadRequest, w.Code)
+
+	var body struct {
+		Message string
+		Details struct {
+			Fields []FieldError
+		}
+	}
+	require.NoError(json.Unmarshal(w.Body.Bytes(), &body))
+	fields := map[string]string{}
+	for _, f := range body.Details.Fields {
+		fields[f.Field] = f.Message
+	}
+	require.Equal(map[string]string{
+		"page":            "page必须大于或等于1",
+		"X-Token":         "X-Token为必填字段",
+		"name":            "name长度不能超过4",
+		"address[0].city": "address[0].city为必填字段",
+	}, fields)
+
+	// 参数类型错误
+	req = httptest.NewRequest(http.MethodPost, "/user/abc", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	require.Equal(http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,210 @@
+package http
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"io"
+	"math/rand"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// 携带该header时记录body
+	DefaultDumpHeader = "X-Debug-Dump"
+
+	defaultDumpBodySize = 4 << 10
+)
+
+// 默认脱敏的json字段
+var defaultDumpRedactKeys = []string{
+	"password", "passwd", "token", "access_token", "refresh_token", "secret", "authorization", "sign", "signature",
+}
+
+type BodyDumpConf struct {
+	// 需要记录的路由模板或请求路径，为空时全部记录
+	Routes []string
+	// 请求及响应body各自最多记录的长度，默认4K
+	MaxBodySize conf.ByteSize
+	// 采样率，取值(0,1]，为0时仅在携带DumpHeader时记录
+	SampleRate float64
+	// 携带该header时记录，默认X-Debug-Dump
+	DumpHeader string
+	// 不为空时要求DumpHeader的值与之相等
+	DumpToken string
+	// 需要脱敏的json字段及form参数(不区分大小写)，为空时使用默认列表
+	RedactKeys []string
+}
+
+// 记录请求及响应body，用于排查问题，日志通过log.Named("bodydump")输出，
+// 可配置[[Log.outputs]]的modules = ["bodydump"]将其输出到单独的文件
+func BodyDump(cfg *BodyDumpConf) gin.HandlerFunc {
+	routes := make(map[string]struct{}, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes[r] = struct{}{}
+	}
+	maxSize := int(cfg.MaxBodySize)
+	if maxSize <= 0 {
+		maxSize = defaultDumpBodySize
+	}
+	header := cfg.DumpHeader
+	if header == "" {
+		header = DefaultDumpHeader
+	}
+	keys := cfg.RedactKeys
+	if len(keys) == 0 {
+		keys = defaultDumpRedactKeys
+	}
+	redact := newBodyRedactor(keys)
+
+	return func(c *gin.Context) {
+		if !shouldDump(c, cfg, routes, header) {
+			c.Next()
+			return
+		}
+
+		var reqBody []byte
+		var reqTruncated bool
+		if c.Request.Body != nil {
+			reqBody, reqTruncated = peekBody(c, maxSize)
+		}
+		w := &dumpWriter{ResponseWriter: c.Writer, max: maxSize}
+		c.Writer = w
+
+		c.Next()
+
+		contentType := c.ContentType()
+		log.Named("bodydump").With(GinFields(c)...).Info("body dump",
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", w.Status()),
+			zap.String("req_body", redact.redact(contentType, reqBody)),
+			zap.Bool("req_truncated", reqTruncated),
+			zap.String("resp_body", redact.redact(w.Header().Get("Content-Type"), w.buf.Bytes())),
+			zap.Bool("resp_truncated", w.truncated),
+		)
+	}
+}
+
+func shouldDump(c *gin.Context, cfg *BodyDumpConf, routes map[string]struct{}, header string) bool {
+	if len(routes) > 0 {
+		_, ok := routes[c.FullPath()]
+		if !ok {
+			_, ok = routes[c.Request.URL.Path]
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	// 常量时间比较，避免通过响应时间猜测token
+	if v := c.GetHeader(header); v != "" &&
+		(cfg.DumpToken == "" || subtle.ConstantTimeCompare([]byte(v), []byte(cfg.DumpToken)) == 1) {
+		return true
+	}
+	return cfg.SampleRate > 0 && rand.Float64() < cfg.SampleRate
+}
+
+// 读取body的前max字节，并将其放回请求中，不影响后续读取
+func peekBody(c *gin.Context, max int) ([]byte, bool) {
+	body := c.Request.Body
+	buf := make([]byte, max+1)
+	n, err := io.ReadFull(body, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		// 读取失败时交由后续处理
+		c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(buf[:n]), errReader{err}), body}
+		return buf[:n], false
+	}
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(buf[:n]), body), body}
+
+	if n > max {
+		return buf[:max], true
+	}
+	return buf[:n], false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// 记录响应body的前max字节
+type dumpWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (w *dumpWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *dumpWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *dumpWriter) capture(b []byte) {
+	left := w.max - w.buf.Len()
+	if len(b) > left {
+		b = b[:left]
+		w.truncated = true
+	}
+	w.buf.Write(b)
+}
+
+type bodyRedactor struct {
+	keys map[string]struct{}
+	// 按正则替换，body被截断时仍能脱敏
+	jsonRe *regexp.Regexp
+}
+
+func newBodyRedactor(keys []string) *bodyRedactor {
+	set := make(map[string]struct{}, len(keys))
+	quoted := make([]string, 0, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = struct{}{}
+		quoted = append(quoted, regexp.QuoteMeta(k))
+	}
+
+	return &bodyRedactor{
+		keys:   set,
+		jsonRe: regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`),
+	}
+}
+
+func (r *bodyRedactor) redact(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	switch strings.TrimSpace(contentType) {
+	case binding.MIMEPOSTForm:
+		return redactQuery(string(body), r.keys)
+	case binding.MIMEMultipartPOSTForm:
+		// 可能包含文件内容，不记录
+		return "<multipart>"
+	default:
+		return r.jsonRe.ReplaceAllString(string(body), `${1}"`+redactedValue+`"`)
+	}
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBodyDump(t *testing.T) {
+	require := require.New(t)
+
+	core, logs := observer.New(zap.DebugLevel)
+	defer zap.ReplaceGlobals(zap.L())
+	zap.ReplaceGlobals(zap.New(core))
+
+	engine := gin.New()
+	engine.Use(BodyDump(&BodyDumpConf{
+		Routes:      []string{"/users/:id"},
+		MaxBodySize: 80,
+		DumpToken:   "motor",
+	}))
+	engine.POST("/users/:id", func(c *gin.Context) {
+		body, err := ioutil.ReadAll(c.Request.Body)
+		require.NoError(err)
+		c.Data(http.StatusOK, "application/json", body)
+	})
+	engine.POST("/other", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	do := func(target, contentType, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if token != "" {
+			req.Header.Set(DefaultDumpHeader, token)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// 未携带header、token错误或路由不匹配时不记录
+	do("/users/1", "application/json", `{}`, "")
+	do("/users/1", "application/json", `{}`, "bad")
+	do("/other", "application/json", `{}`, "motor")
+	require.Equal(0, logs.Len())
+
+	body := `{"name":"motor","password":"123456","nested":{"Token":"abc"},"age":1}`
+	w := do("/users/1", "application/json", body, "motor")
+	require.Equal(body, w.Body.String())
+	entries := logs.TakeAll()
+	require.Len(entries, 1)
+	require.Equal("bodydump", entries[0].LoggerName)
+	fields := entries[0].ContextMap()
+	require.Equal(`{"name":"motor","password":"******","nested":{"Token":"******"},"age":1}`, fields["req_body"])
+	require.Equal(false, fields["req_truncated"])
+	require.Equal(fields["req_body"], fields["resp_body"])
+
+	// 超过长度时截断，handler仍能读取完整body
+	long := `{"token":"` + strings.Repeat("x", 100) + `"}`
+	w = do("/users/1", "application/json", long, "motor")
+	require.Equal(long, w.Body.String())
+	fields = logs.TakeAll()[0].ContextMap()
+	require.Equal(`{"token":"******"`, fields["req_body"])
+	require.Equal(true, fields["req_truncated"])
+	require.Equal(true, fields["resp_truncated"])
+
+	do("/users/1", "application/x-www-form-urlencoded", "name=motor&password=123", "motor")
+	fields = logs.TakeAll()[0].ContextMap()
+	require.Equal("name=motor&password=%2A%2A%2A%2A%2A%2A", fields["req_body"])
+}
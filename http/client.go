@@ -0,0 +1,422 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/tolerant"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// 未匹配到路由模板时使用的route标签
+	OtherRoute = "other"
+	// 熔断资源名的前缀，完整的资源名为前缀加host
+	BreakerResourcePrefix = "httpclient:"
+
+	defaultRetryBackoff = 100 * time.Millisecond
+)
+
+var defaultRetryStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// 调用其他服务的http client配置，位于application.toml的[Client]
+type ClientConf struct {
+	// 单次请求(含读取响应body)的超时时间，0表示不限制
+	Timeout conf.Duration
+	Retry   RetryConf
+	// 不配置时不熔断
+	Breaker *BreakerConf
+	// 按host(host或host:port)覆盖以上配置
+	Hosts map[string]*HostConf
+}
+
+type HostConf struct {
+	// 为0时使用[Client]中的配置
+	Timeout conf.Duration
+	Retry   *RetryConf
+	Breaker *BreakerConf
+	// 路由模板，如/users/:id、/static/*path，用于metrics的route标签
+	Routes []string
+}
+
+// 仅重试幂等请求(GET、HEAD、OPTIONS、TRACE、PUT、DELETE及携带Idempotency-Key的请求)
+type RetryConf struct {
+	// 最大重试次数，0表示不重试
+	Max int
+	// 首次重试前的等待时间，之后每次翻倍并加随机抖动，默认100ms
+	Backoff    conf.Duration
+	MaxBackoff conf.Duration
+	// 需要重试的响应状态码，为空时使用502、503、504；网络错误及超时总会重试
+	StatusCodes []int
+}
+
+// 按host熔断，网络错误及5xx响应计为错误
+type BreakerConf struct {
+	// slowRequestRatio、errorRatio、errorCount
+	Strategy         tolerant.Strategy
+	Threshold        float64
+	MinRequestAmount uint64
+	StatInterval     conf.Duration
+	// 慢请求的耗时阈值，仅slowRequestRatio使用
+	MaxAllowedRt conf.Duration
+	// 熔断后多久进入半开状态
+	RetryTimeout conf.Duration
+}
+
+// 熔断器打开时请求返回的错误
+type BreakerError struct {
+	Host  string
+	cause error
+}
+
+func (e *BreakerError) Error() string {
+	return fmt.Sprintf("circuit breaker is open, host:%s, %v", e.Host, e.cause)
+}
+
+// 判断err是否因熔断而失败，err可以是http.Client返回的*url.Error
+func IsBreakerOpen(err error) bool {
+	var be *BreakerError
+	return errors.As(err, &be)
+}
+
+type clientRouteKey struct{}
+
+// 指定请求在metrics及trace中使用的route，优先于HostConf.Routes
+func WithClientRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, clientRouteKey{}, route)
+}
+
+// 从application.toml的[Client]中加载配置
+func LoadClientConf() (*ClientConf, error) {
+	var cfg ClientConf
+	if err := conf.UnmarshalSection("application.toml", "Client", &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// 创建调用其他服务的http client，请求需携带c.Request.Context()以便串联trace及request id
+func NewClient(cfg *ClientConf, base http.RoundTripper) *http.Client {
+	return &http.Client{Transport: NewClientTransport(cfg, base)}
+}
+
+// 为每个请求创建client span并注入trace header，转发request id，按host、route统计，
+// 并按host配置超时、重试及熔断
+type ClientTransport struct {
+	base http.RoundTripper
+	cfg  *ClientConf
+
+	// host -> *hostPolicy
+	policies sync.Map
+}
+
+type hostPolicy struct {
+	timeout time.Duration
+	retry   RetryConf
+	breaker bool
+	routes  [][]string
+}
+
+// base为nil时使用http.DefaultTransport
+func NewClientTransport(cfg *ClientConf, base http.RoundTripper) *ClientTransport {
+	if cfg == nil {
+		cfg = &ClientConf{}
+	}
+	return &ClientTransport{
+		base: &RequestIDTransport{Base: base},
+		cfg:  cfg,
+	}
+}
+
+func (t *ClientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	host := req.URL.Host
+	p := t.policy(host)
+	route := clientRoute(req, p)
+
+	span, ctx := opentracing.StartSpanFromContext(req.Context(), "HTTP "+req.Method+" "+route)
+	defer span.Finish()
+	ext.SpanKindRPCClient.Set(span)
+	ext.HTTPMethod.Set(span, req.Method)
+	ext.HTTPUrl.Set(span, req.URL.Scheme+"://"+host+req.URL.EscapedPath())
+	ext.PeerHostname.Set(span, req.URL.Hostname())
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	for attempt := 0; ; attempt++ {
+		var body io.ReadCloser
+		if attempt > 0 {
+			// 重试时重新获取请求body
+			if req.GetBody != nil {
+				if body, err = req.GetBody(); err != nil {
+					err = errors.Wrap(err, "req.GetBody failed")
+					break
+				}
+			}
+
+			wait := p.backoff(attempt)
+			span.LogKV("event", "retry", "attempt", attempt, "wait", wait.String())
+			log.Named("httpclient").With(log.Fields(ctx)...).Warn("retry request",
+				zap.String("host", host),
+				zap.String("route", route),
+				zap.Int("attempt", attempt),
+				zap.Duration("wait", wait))
+			observeClientRetry(host, route)
+			if err = sleepContext(ctx, wait); err != nil {
+				break
+			}
+		}
+
+		resp, err = t.attempt(ctx, req, body, host, p, span)
+		if attempt >= p.retry.Max || ctx.Err() != nil || !p.retryable(req, resp, err) {
+			break
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+
+	code := "error"
+	switch {
+	case IsBreakerOpen(err):
+		code = "blocked"
+	case err == nil:
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	observeClientRequest(host, route, req.Method, code, time.Since(start))
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("event", "error", "message", err.Error())
+		return nil, err
+	}
+	ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		ext.Error.Set(span, true)
+	}
+	return resp, nil
+}
+
+// 发起一次请求，body不为nil时替换请求的body
+func (t *ClientTransport) attempt(ctx context.Context, req *http.Request, body io.ReadCloser,
+	host string, p *hostPolicy, span opentracing.Span) (*http.Response, error) {
+	var entry *base.SentinelEntry
+	if p.breaker {
+		e, b := sentinel.Entry(BreakerResourcePrefix+host, sentinel.WithTrafficType(base.Outbound))
+		if b != nil {
+			if body != nil {
+				body.Close()
+			}
+			return nil, &BreakerError{Host: host, cause: b}
+		}
+		entry = e
+	}
+
+	cancel := func() {}
+	if p.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+	}
+	// RoundTripper不应修改原请求
+	r := req.Clone(ctx)
+	if body != nil {
+		r.Body = body
+	}
+	err := span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	if err != nil {
+		log.Named("httpclient").With(log.Fields(ctx)...).Error("tracer.Inject failed",
+			zap.String("host", host),
+			zap.Error(err))
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if entry != nil {
+		if err != nil {
+			sentinel.TraceError(entry, err)
+		} else if resp.StatusCode >= http.StatusInternalServerError {
+			sentinel.TraceError(entry, fmt.Errorf("unexpected status code, code:%d", resp.StatusCode))
+		}
+		entry.Exit()
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// 读取完响应body并关闭后才释放超时的ctx
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// 获取host的配置，首次使用时加载熔断规则
+func (t *ClientTransport) policy(host string) *hostPolicy {
+	if p, ok := t.policies.Load(host); ok {
+		return p.(*hostPolicy)
+	}
+
+	p := &hostPolicy{
+		timeout: time.Duration(t.cfg.Timeout),
+		retry:   t.cfg.Retry,
+	}
+	breaker := t.cfg.Breaker
+	hc, ok := t.cfg.Hosts[host]
+	if !ok {
+		if i := strings.LastIndexByte(host, ':'); i >= 0 {
+			hc, ok = t.cfg.Hosts[host[:i]]
+		}
+	}
+	if ok && hc != nil {
+		if hc.Timeout > 0 {
+			p.timeout = time.Duration(hc.Timeout)
+		}
+		if hc.Retry != nil {
+			p.retry = *hc.Retry
+		}
+		if hc.Breaker != nil {
+			breaker = hc.Breaker
+		}
+		for _, route := range hc.Routes {
+			p.routes = append(p.routes, strings.Split(strings.Trim(route, "/"), "/"))
+		}
+	}
+	if p.retry.Backoff <= 0 {
+		p.retry.Backoff = conf.Duration(defaultRetryBackoff)
+	}
+	if len(p.retry.StatusCodes) == 0 {
+		p.retry.StatusCodes = defaultRetryStatusCodes
+	}
+
+	if breaker != nil {
+		err := tolerant.AddBreakerRules(&circuitbreaker.Rule{
+			Resource:         BreakerResourcePrefix + host,
+			Strategy:         circuitbreaker.Strategy(breaker.Strategy),
+			RetryTimeoutMs:   uint32(time.Duration(breaker.RetryTimeout).Milliseconds()),
+			MinRequestAmount: breaker.MinRequestAmount,
+			StatIntervalMs:   uint32(time.Duration(breaker.StatInterval).Milliseconds()),
+			Threshold:        breaker.Threshold,
+			MaxAllowedRtMs:   uint64(time.Duration(breaker.MaxAllowedRt).Milliseconds()),
+		})
+		if err != nil {
+			log.Named("httpclient").Error("tolerant.AddBreakerRules failed",
+				zap.String("host", host),
+				zap.Error(err))
+		} else {
+			p.breaker = true
+		}
+	}
+
+	actual, _ := t.policies.LoadOrStore(host, p)
+	return actual.(*hostPolicy)
+}
+
+func (p *hostPolicy) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if !idempotent(req) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return !IsBreakerOpen(err)
+	}
+	for _, code := range p.retry.StatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// 第attempt次重试前的等待时间，在[d/2, d]间随机
+func (p *hostPolicy) backoff(attempt int) time.Duration {
+	d := time.Duration(p.retry.Backoff)
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	if max := time.Duration(p.retry.MaxBackoff); max > 0 && d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// 依次使用WithClientRoute指定的route、匹配到的路由模板，都没有时为OtherRoute
+func clientRoute(req *http.Request, p *hostPolicy) string {
+	if route, ok := req.Context().Value(clientRouteKey{}).(string); ok && route != "" {
+		return route
+	}
+
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	for _, route := range p.routes {
+		if matchRoute(route, segments) {
+			return "/" + strings.Join(route, "/")
+		}
+	}
+	return OtherRoute
+}
+
+func matchRoute(route, segments []string) bool {
+	for i, part := range route {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != segments[i] {
+			return false
+		}
+	}
+	return len(route) == len(segments)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
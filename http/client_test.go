@@ -0,0 +1,220 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/metrics"
+	"github.com/kaimixu/motor/tolerant"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	require := require.New(t)
+
+	defer opentracing.SetGlobalTracer(opentracing.GlobalTracer())
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+
+	r, err := metrics.NewRegistry(&metrics.MetricsConf{Namespace: "client"})
+	require.NoError(err)
+	require.NoError(RegisterClientMetrics(r))
+	defer func() {
+		_clientMetricsMutex.Lock()
+		_clientMetrics = nil
+		_clientMetricsMutex.Unlock()
+	}()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(req.Header.Get(log.RequestIDHeader) + "|" + req.Header.Get("mockpfx-ids-traceid")))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	client := NewClient(&ClientConf{
+		Retry: RetryConf{Max: 2, Backoff: conf.Duration(time.Millisecond)},
+		Hosts: map[string]*HostConf{
+			"127.0.0.1": {Routes: []string{"/users/:id", "/static/*path"}},
+		},
+	}, nil)
+
+	parent := tracer.StartSpan("handler")
+	ctx := opentracing.ContextWithSpan(log.WithRequestID(context.Background(), "abc-123"), parent)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/users/1", nil)
+	resp, err := client.Do(req)
+	require.NoError(err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	parent.Finish()
+
+	// 503后重试成功，请求携带request id及trace header
+	require.Equal(int32(2), atomic.LoadInt32(&calls))
+	spans := tracer.FinishedSpans()
+	require.Len(spans, 2)
+	span := spans[0]
+	require.Equal("HTTP GET /users/:id", span.OperationName)
+	require.Equal(parent.Context().(mocktracer.MockSpanContext).SpanID, span.ParentID)
+	require.Equal(uint16(http.StatusOK), span.Tag("http.status_code"))
+	require.Equal("abc-123|"+strconv.Itoa(span.SpanContext.TraceID), string(b))
+	require.Len(span.Logs(), 1)
+
+	// 非幂等请求不重试
+	atomic.StoreInt32(&calls, 0)
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/static/a/b.js", strings.NewReader("x"))
+	resp, err = client.Do(req)
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(int32(1), atomic.LoadInt32(&calls))
+
+	// 携带Idempotency-Key时重试并重放body
+	atomic.StoreInt32(&calls, 0)
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/orders", strings.NewReader("x"))
+	req.Header.Set("Idempotency-Key", "k1")
+	resp, err = client.Do(req)
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusOK, resp.StatusCode)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	require.Contains(body, `client_http_client_requests_total{code="200",host="`+host+`",method="GET",route="/users/:id"} 1`)
+	require.Contains(body, `client_http_client_requests_total{code="503",host="`+host+`",method="POST",route="/static/*path"} 1`)
+	require.Contains(body, `client_http_client_requests_total{code="200",host="`+host+`",method="POST",route="other"} 1`)
+	require.Contains(body, `client_http_client_retries_total{host="`+host+`",route="/users/:id"} 1`)
+}
+
+func TestClientTimeout(t *testing.T) {
+	require := require.New(t)
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	client := NewClient(&ClientConf{
+		Timeout: conf.Duration(time.Second),
+		Retry:   RetryConf{Max: 1, Backoff: conf.Duration(time.Millisecond)},
+		Hosts: map[string]*HostConf{
+			host: {Timeout: conf.Duration(50 * time.Millisecond)},
+		},
+	}, nil)
+
+	start := time.Now()
+	_, err := client.Get(srv.URL)
+	require.Error(err)
+	require.True(err.(*url.Error).Timeout())
+	require.True(time.Since(start) < 500*time.Millisecond)
+	// 超时后重试一次
+	require.Equal(int32(2), atomic.LoadInt32(&calls))
+
+	// 调用方取消时不重试
+	atomic.StoreInt32(&calls, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	_, err = client.Do(req)
+	require.Error(err)
+	require.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestClientBreaker(t *testing.T) {
+	require := require.New(t)
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	var strategy tolerant.Strategy
+	require.NoError(strategy.UnmarshalText([]byte("errorCount")))
+	client := NewClient(&ClientConf{
+		Breaker: &BreakerConf{
+			Strategy:         strategy,
+			Threshold:        2,
+			MinRequestAmount: 1,
+			StatInterval:     conf.Duration(10 * time.Second),
+			RetryTimeout:     conf.Duration(10 * time.Second),
+		},
+	}, nil)
+
+	var err error
+	for i := 0; i < 5 && err == nil; i++ {
+		var resp *http.Response
+		if resp, err = client.Get(srv.URL); err == nil {
+			resp.Body.Close()
+		}
+	}
+	require.True(IsBreakerOpen(err))
+	require.Contains(err.Error(), host)
+	require.True(atomic.LoadInt32(&calls) < 5)
+}
+
+func TestClientRoute(t *testing.T) {
+	require := require.New(t)
+
+	p := &hostPolicy{}
+	for _, route := range []string{"/users/:id", "/users/:id/orders", "/static/*path"} {
+		p.routes = append(p.routes, strings.Split(strings.Trim(route, "/"), "/"))
+	}
+	for path, want := range map[string]string{
+		"/users/1":          "/users/:id",
+		"/users/1/orders":   "/users/:id/orders",
+		"/users/1/orders/2": OtherRoute,
+		"/users":            OtherRoute,
+		"/static/js/a.js":   "/static/*path",
+		"/":                 OtherRoute,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		require.Equal(want, clientRoute(req, p), path)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req = req.WithContext(WithClientRoute(req.Context(), "getUser"))
+	require.Equal("getUser", clientRoute(req, p))
+}
+
+func TestLoadClientConf(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+
+	cfg, err := LoadClientConf()
+	require.NoError(err)
+	require.Equal(3*time.Second, time.Duration(cfg.Timeout))
+	require.Equal(2, cfg.Retry.Max)
+	require.Equal(0.5, cfg.Breaker.Threshold)
+
+	p := NewClientTransport(cfg, nil).policy("user.svc:8080")
+	require.Equal(500*time.Millisecond, p.timeout)
+	require.Equal(0, p.retry.Max)
+	require.Len(p.routes, 2)
+	require.True(p.breaker)
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/trace"
+	"go.uber.org/zap"
+)
+
+// gin请求对应的trace_id、span_id、request_id及jwt的subject
+func GinFields(c *gin.Context) []zap.Field {
+	if c == nil {
+		return nil
+	}
+
+	ctx := context.Background()
+	if tctx, ok := trace.GetTraceCtx(c); ok {
+		ctx = tctx
+	} else if c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	if id := ginRequestID(c); id != "" {
+		ctx = log.WithRequestID(ctx, id)
+	}
+	if claims, ok := ClaimsFrom(c); ok && claims.Subject != "" {
+		ctx = log.WithSubject(ctx, claims.Subject)
+	}
+	return log.Fields(ctx)
+}
+
+// 获取携带gin请求trace_id、request_id等字段的logger
+func FromGin(c *gin.Context) *zap.Logger {
+	if fields := GinFields(c); len(fields) > 0 {
+		return zap.L().With(fields...)
+	}
+	return zap.L()
+}
+
+func ginRequestID(c *gin.Context) string {
+	if id := c.GetString(log.RequestIDKey); id != "" {
+		return id
+	}
+	if c.Request == nil {
+		return ""
+	}
+	if id, ok := log.RequestIDFrom(c.Request.Context()); ok {
+		return id
+	}
+	return c.Request.Header.Get(log.RequestIDHeader)
+}
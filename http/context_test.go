@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/jwt"
+	"github.com/kaimixu/motor/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestGinFields(t *testing.T) {
+	require := require.New(t)
+
+	core, logs := observer.New(zap.DebugLevel)
+	defer zap.ReplaceGlobals(zap.L())
+	zap.ReplaceGlobals(zap.New(core))
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	FromGin(c).Info("empty")
+
+	c.Request.Header.Set(log.RequestIDHeader, "req-2")
+	c.Set(jwt.ClaimsKey, &jwt.MotorClaims{StandardClaims: jwtgo.StandardClaims{Subject: "user-2"}})
+	FromGin(c).Info("from gin")
+
+	// gin.Context中的request id优先于header
+	c.Set(log.RequestIDKey, "req-3")
+	FromGin(c).Info("from key")
+
+	entries := logs.All()
+	require.Len(entries, 3)
+	require.Empty(entries[0].ContextMap())
+	require.Equal(map[string]interface{}{"request_id": "req-2", "subject": "user-2"}, entries[1].ContextMap())
+	require.Equal(map[string]interface{}{"request_id": "req-3", "subject": "user-2"}, entries[2].ContextMap())
+	require.Nil(GinFields(nil))
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/health"
+)
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// 注册就绪检查项，timeout<=0时使用默认超时
+func (s *Server) AddChecker(c health.Checker, timeout time.Duration) {
+	s.health.Register(c, timeout)
+}
+
+// 配置加载状态检查，未加载或重新加载失败时视为未就绪
+func ConfChecker() health.Checker {
+	return health.NewChecker("conf", func(ctx context.Context) error {
+		return conf.Status()
+	})
+}
+
+// 存活检查，进程可响应即视为存活
+func (s *Server) Liveness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+	}
+}
+
+// 就绪检查，退出过程中或任一检查项失败时返回503，携带verbose参数时返回各检查项详情
+func (s *Server) Readiness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.Ready() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status": health.StatusDown,
+				"error":  "server not ready",
+			})
+			return
+		}
+
+		ok, results := s.health.Check(c.Request.Context())
+		status, code := health.StatusUp, http.StatusOK
+		if !ok {
+			status, code = health.StatusDown, http.StatusServiceUnavailable
+		}
+		body := gin.H{"status": status}
+		if _, verbose := c.GetQuery("verbose"); verbose || !ok {
+			body["checks"] = results
+		}
+		c.JSON(code, body)
+	}
+}
+
+// 挂载/healthz及/readyz
+func (s *Server) OpenHealth() {
+	s.healthOnce.Do(func() {
+		s.Engine.GET(LivenessPath, s.Liveness())
+		s.Engine.GET(ReadinessPath, s.Readiness())
+	})
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/health"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+
+	srv := DefaultServer(&ServerConf{})
+	srv.OpenHealth()
+	srv.AddChecker(ConfChecker(), 0)
+
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]interface{}
+		require.NoError(json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	code, _ := get(LivenessPath)
+	require.Equal(http.StatusOK, code)
+
+	// 服务未启动
+	code, _ = get(ReadinessPath)
+	require.Equal(http.StatusServiceUnavailable, code)
+
+	atomic.StoreInt32(&srv.ready, 1)
+	code, body := get(ReadinessPath + "?verbose")
+	require.Equal(http.StatusOK, code)
+	require.Len(body["checks"], 1)
+
+	srv.AddChecker(health.NewChecker("mysql", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}), 0)
+	code, body = get(ReadinessPath)
+	require.Equal(http.StatusServiceUnavailable, code)
+	require.Equal(health.StatusDown, body["status"])
+	require.Len(body["checks"], 2)
+}
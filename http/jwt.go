@@ -1,48 +1,159 @@
 package http
 
 import (
-	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/ecode"
 	"github.com/kaimixu/motor/jwt"
+	"github.com/kaimixu/motor/log"
 )
 
-func Jwt(secret string) gin.HandlerFunc {
-	j := jwt.NewJWT(secret)
+// 从请求中提取token，未找到时返回空串
+type TokenExtractor func(c *gin.Context) string
+
+// 鉴权失败时的响应处理
+type JwtErrorHandler func(c *gin.Context, err error)
+
+type JwtOption func(*jwtOptions)
+
+type jwtOptions struct {
+	extractors   []TokenExtractor
+	jwtOpts      []jwt.Option
+	errorHandler JwtErrorHandler
+}
+
+// 从指定header中获取token
+func TokenFromHeader(name string) TokenExtractor {
+	return func(c *gin.Context) string {
+		return c.GetHeader(name)
+	}
+}
+
+// 从Authorization: Bearer <token>中获取token
+func TokenFromBearer() TokenExtractor {
+	return func(c *gin.Context) string {
+		auth := c.GetHeader("Authorization")
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			return strings.TrimSpace(auth[7:])
+		}
+		return ""
+	}
+}
+
+// 从指定cookie中获取token
+func TokenFromCookie(name string) TokenExtractor {
+	return func(c *gin.Context) string {
+		token, err := c.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return token
+	}
+}
+
+// 从指定query参数中获取token
+func TokenFromQuery(name string) TokenExtractor {
+	return func(c *gin.Context) string {
+		return c.Query(name)
+	}
+}
+
+// 按顺序尝试各token来源，默认依次为JWT-TOKEN header和Authorization: Bearer
+func JwtTokenLookup(extractors ...TokenExtractor) JwtOption {
+	return func(o *jwtOptions) {
+		o.extractors = extractors
+	}
+}
+
+// 要求token的iss与issuer一致
+func JwtIssuer(issuer string) JwtOption {
+	return func(o *jwtOptions) {
+		o.jwtOpts = append(o.jwtOpts, jwt.WithIssuer(issuer))
+	}
+}
+
+// 要求token的aud与audience一致
+func JwtAudience(audience string) JwtOption {
+	return func(o *jwtOptions) {
+		o.jwtOpts = append(o.jwtOpts, jwt.WithAudience(audience))
+	}
+}
+
+// 校验exp、nbf、iat时允许的时钟偏差
+func JwtLeeway(leeway time.Duration) JwtOption {
+	return func(o *jwtOptions) {
+		o.jwtOpts = append(o.jwtOpts, jwt.WithLeeway(leeway))
+	}
+}
+
+// 自定义鉴权失败的响应
+func JwtErrorResponder(h JwtErrorHandler) JwtOption {
+	return func(o *jwtOptions) {
+		o.errorHandler = h
+	}
+}
+
+func Jwt(secret string, opts ...JwtOption) gin.HandlerFunc {
+	o := &jwtOptions{
+		extractors: []TokenExtractor{
+			TokenFromHeader("JWT-TOKEN"),
+			TokenFromBearer(),
+		},
+		errorHandler: defaultJwtErrorHandler,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	j := jwt.NewJWT(secret, o.jwtOpts...)
+
 	return func(c *gin.Context) {
-		tokenStr := c.Request.Header.Get("JWT-TOKEN")
+		var tokenStr string
+		for _, extract := range o.extractors {
+			if tokenStr = extract(c); tokenStr != "" {
+				break
+			}
+		}
 		if tokenStr == "" {
-			c.JSON(http.StatusOK, gin.H{
-				"status": -1,
-				"errmsg": "请求未携带jwt-token",
-				"data":   nil,
-			})
-
+			o.errorHandler(c, jwt.ErrTokenMissing)
 			c.Abort()
 			return
 		}
 
 		claims, err := j.ParseToken(tokenStr)
 		if err != nil {
-			if j.IsExpires(err) {
-				c.JSON(http.StatusOK, gin.H{
-					"status": 1,
-					"errmsg": "token已过期",
-					"data":   nil,
-				})
-				c.Abort()
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{
-				"status": 1,
-				"errmsg": "token无效",
-				"data":   nil,
-			})
+			o.errorHandler(c, err)
 			c.Abort()
 			return
 		}
 
-		c.Set("jwtClaims", claims)
+		c.Set(jwt.ClaimsKey, claims)
+		// 同时存入c.Request.Context()，便于log.Ctx输出subject
+		if claims.Subject != "" {
+			c.Request = c.Request.WithContext(log.WithSubject(c.Request.Context(), claims.Subject))
+		}
 	}
 }
+
+// 获取Jwt中间件解析出的claims
+func ClaimsFrom(c *gin.Context) (*jwt.MotorClaims, bool) {
+	val, exists := c.Get(jwt.ClaimsKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := val.(*jwt.MotorClaims)
+
+	return claims, ok
+}
+
+func defaultJwtErrorHandler(c *gin.Context, err error) {
+	e := ecode.Unauthorized.WithMessage("token无效").WithCause(err)
+	if err == jwt.ErrTokenMissing {
+		e = e.WithMessage(err.Error())
+	} else if jwt.IsExpired(err) {
+		e = e.WithMessage("token已过期")
+	}
+
+	AbortWithError(c, e)
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	motorjwt "github.com/kaimixu/motor/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJwt(t *testing.T) {
+	require := require.New(t)
+	gin.SetMode(gin.TestMode)
+
+	token, err := motorjwt.NewJWT("secret").GenToken(&motorjwt.MotorClaims{
+		StandardClaims: jwt.StandardClaims{Subject: "user1", Issuer: "motor"},
+	})
+	require.NoError(err)
+
+	engine := gin.New()
+	engine.Use(Jwt("secret",
+		JwtTokenLookup(TokenFromBearer(), TokenFromCookie("token"), TokenFromQuery("token")),
+		JwtIssuer("motor"),
+	))
+	engine.GET("/user", func(c *gin.Context) {
+		claims, ok := ClaimsFrom(c)
+		require.True(ok)
+		c.String(http.StatusOK, claims.Subject)
+	})
+
+	cases := []struct {
+		setup  func(r *http.Request)
+		status int
+	}{
+		{func(r *http.Request) {}, http.StatusUnauthorized},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer invalid") }, http.StatusUnauthorized},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }, http.StatusOK},
+		{func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "token", Value: token}) }, http.StatusOK},
+		{func(r *http.Request) { r.URL.RawQuery = "token=" + token }, http.StatusOK},
+	}
+	for _, cs := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		cs.setup(req)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		require.Equal(cs.status, w.Code)
+		if cs.status == http.StatusOK {
+			require.Equal("user1", w.Body.String())
+		}
+	}
+}
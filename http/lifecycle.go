@@ -0,0 +1,84 @@
+package http
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 退出阶段，按定义顺序依次执行
+type ShutdownStage int
+
+const (
+	// 摘除流量，如：名字服务注销，在等待处理中请求完成之前执行
+	StageDeregister ShutdownStage = iota
+	// 上报剩余的trace及metrics数据
+	StageTrace
+	// 释放mysql、redis等存储连接
+	StageStorage
+	// 刷新日志
+	StageLog
+)
+
+const (
+	defShutdownTimeout = 10 * time.Second
+)
+
+type shutdownHook struct {
+	stage ShutdownStage
+	name  string
+	fn    func(ctx context.Context) error
+}
+
+// 将无参的关闭函数(如：mysql.Close)转换为退出回调
+func HookFunc(f func()) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		f()
+		return nil
+	}
+}
+
+// 注册退出回调，同一阶段内按注册顺序执行
+func (s *Server) OnShutdown(stage ShutdownStage, name string, fn func(ctx context.Context) error) {
+	s.hookMutex.Lock()
+	defer s.hookMutex.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{stage: stage, name: name, fn: fn})
+}
+
+// 执行[from, to]阶段内的回调
+func (s *Server) runHooks(from, to ShutdownStage) {
+	for _, h := range s.stageHooks(from, to) {
+		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+		if err := h.fn(ctx); err != nil {
+			zap.L().Error("shutdown hook failed",
+				zap.String("hook", h.name),
+				zap.Error(err))
+		}
+		cancel()
+	}
+}
+
+// [from, to]阶段内的回调，按阶段排序，同一阶段内按注册顺序
+func (s *Server) stageHooks(from, to ShutdownStage) []shutdownHook {
+	s.hookMutex.Lock()
+	hooks := make([]shutdownHook, 0, len(s.hooks))
+	for _, h := range s.hooks {
+		if h.stage >= from && h.stage <= to {
+			hooks = append(hooks, h)
+		}
+	}
+	s.hookMutex.Unlock()
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].stage < hooks[j].stage
+	})
+	return hooks
+}
+
+func (s *Server) shutdownTimeout() time.Duration {
+	if s.conf.ShutdownTimeout > 0 {
+		return time.Duration(s.conf.ShutdownTimeout)
+	}
+	return defShutdownTimeout
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/conf"
+	"github.com/stretchr/testify/require"
+)
+
+// 异步发起GET请求，出错或非200时返回错误
+func asyncGet(url string) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			ch <- err
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			ch <- fmt.Errorf("unexpected status %d", resp.StatusCode)
+			return
+		}
+		ch <- nil
+	}()
+	return ch
+}
+
+func TestShutdown(t *testing.T) {
+	require := require.New(t)
+
+	srv := DefaultServer(&ServerConf{
+		Addr:            "127.0.0.1:0",
+		ShutdownTimeout: conf.Duration(3 * time.Second),
+	})
+	started := make(chan struct{})
+	srv.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+
+	// 回调在Run所在的goroutine中执行，Run返回后再检查
+	var stages []string
+	var readyOnDeregister bool
+	srv.OnShutdown(StageStorage, "storage", func(ctx context.Context) error {
+		stages = append(stages, "storage")
+		return nil
+	})
+	srv.OnShutdown(StageDeregister, "deregister", func(ctx context.Context) error {
+		readyOnDeregister = srv.Ready()
+		stages = append(stages, "deregister")
+		return nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Run()
+	}()
+	require.Eventually(func() bool {
+		return srv.Ready() && srv.Addr() != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 退出时处理中的请求需正常完成
+	respCh := asyncGet(fmt.Sprintf("http://%s/slow", srv.Addr()))
+	<-started
+	srv.Shutdown()
+
+	require.NoError(<-errCh)
+	require.NoError(<-respCh)
+	require.Equal([]string{"deregister", "storage"}, stages)
+	require.False(readyOnDeregister)
+	require.False(srv.Ready())
+}
+
+func TestDefaultHooks(t *testing.T) {
+	require := require.New(t)
+
+	srv := DefaultServer(&ServerConf{})
+	srv.OnShutdown(StageStorage, "cache", HookFunc(func() {}))
+	srv.OnShutdown(StageDeregister, "deregister", HookFunc(func() {}))
+
+	// 按阶段排序，同一阶段内按注册顺序
+	var names []string
+	for _, h := range srv.stageHooks(StageDeregister, StageLog) {
+		names = append(names, h.name)
+	}
+	require.Equal([]string{"deregister", "trace", "mysql", "redis", "cache", "log"}, names)
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	NetworkTCP     = "tcp"
+	NetworkUnix    = "unix"
+	NetworkSystemd = "systemd"
+
+	// systemd传递的第一个fd
+	sdListenFdsStart = 3
+)
+
+// 按配置创建监听
+func listen(network, addr string) (net.Listener, error) {
+	switch strings.ToLower(network) {
+	case "", NetworkTCP:
+		return net.Listen("tcp", addr)
+	case NetworkUnix:
+		// 清理上次退出残留的socket文件
+		if fi, err := os.Stat(addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(addr); err != nil {
+				return nil, errors.Wrap(err, "os.Remove")
+			}
+		}
+		return net.Listen("unix", addr)
+	case NetworkSystemd:
+		return systemdListener(addr)
+	default:
+		return nil, fmt.Errorf("unsupported network: %s", network)
+	}
+}
+
+// 获取systemd socket activation传入的监听，name为空时取第一个，否则按LISTEN_FDNAMES匹配
+func systemdListener(name string) (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("no systemd socket passed to this process")
+	}
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, errors.New("no systemd socket passed to this process")
+	}
+
+	idx := 0
+	if name != "" {
+		idx = -1
+		for i, n := range strings.Split(os.Getenv("LISTEN_FDNAMES"), ":") {
+			if n == name && i < nfds {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("systemd socket %s not found", name)
+		}
+	}
+
+	f := os.NewFile(uintptr(sdListenFdsStart+idx), name)
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "net.FileListener")
+	}
+	return ln, nil
+}
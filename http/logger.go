@@ -1,48 +1,151 @@
 package http
 
 import (
-	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/log"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
+const (
+	redactedValue = "******"
+)
+
+// 默认脱敏的query参数
+var defaultRedactQuery = []string{
+	"token", "access_token", "refresh_token", "jwt-token", "password", "passwd", "secret", "sign", "signature",
+}
+
+type AccessLogConf struct {
+	// 需要记录的请求header
+	Headers []string
+	// 需要脱敏的query参数(不区分大小写)，为空时使用默认列表
+	RedactQuery []string
+	// 不记录的路径，匹配路由模板或请求路径
+	SkipPaths []string
+	// 状态码小于400的请求的采样率，取值(0,1)时按比例记录，其他值全部记录，4xx、5xx始终记录
+	SampleRate float64
+}
+
+// 结构化的accesslog，使用默认配置
 func Logger() gin.HandlerFunc {
+	return LoggerWithConf(&AccessLogConf{})
+}
+
+// 结构化的accesslog，日志携带trace_id、request_id及jwt的subject，通过log.Named("access")输出
+func LoggerWithConf(cfg *AccessLogConf) gin.HandlerFunc {
+	skip := make(map[string]struct{}, len(cfg.SkipPaths))
+	for _, p := range cfg.SkipPaths {
+		skip[p] = struct{}{}
+	}
+	redactKeys := cfg.RedactQuery
+	if len(redactKeys) == 0 {
+		redactKeys = defaultRedactQuery
+	}
+	redact := make(map[string]struct{}, len(redactKeys))
+	for _, k := range redactKeys {
+		redact[strings.ToLower(k)] = struct{}{}
+	}
+	headers := make([]string, 0, len(cfg.Headers))
+	for _, h := range cfg.Headers {
+		headers = append(headers, http.CanonicalHeaderKey(h))
+	}
+
 	return func(c *gin.Context) {
 		start := time.Now()
-		path := c.Request.URL.Path
-		raw := c.Request.URL.RawQuery
 
 		// Process request
 		c.Next()
 
-		if raw != "" {
-			path = path + "?" + raw
-		}
-
-		end := time.Now()
-		local, _ := time.LoadLocation("Local")
-		referer := c.Request.Header.Get("Referer")
-		if referer == "" {
-			referer = "-"
-		}
-		accessLog := fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %d \"%s\" \"%s\"",
-			c.ClientIP(),
-			end.In(local).Format("02/Jan/2006:15:04:05"),
-			c.Request.Method,
-			c.Request.URL.Path,
-			c.Request.Proto,
-			c.Writer.Status(),
-			c.Writer.Size(),
-			referer,
-			c.Request.Header.Get("User-Agent"),
-		)
-
-		zap.L().Info(accessLog,
-			zap.Duration("latency", end.Sub(start)),
-			zap.String("path", path),
-			zap.String("errmsg", c.Errors.ByType(gin.ErrorTypePrivate).String()),
-		)
+		route := c.FullPath()
+		if _, ok := skip[route]; ok {
+			return
+		}
+		if _, ok := skip[c.Request.URL.Path]; ok {
+			return
+		}
+		status := c.Writer.Status()
+		if status < http.StatusBadRequest && cfg.SampleRate > 0 && cfg.SampleRate < 1 &&
+			rand.Float64() >= cfg.SampleRate {
+			return
+		}
+
+		bytesOut := c.Writer.Size()
+		if bytesOut < 0 {
+			bytesOut = 0
+		}
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", route),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("query", redactQuery(c.Request.URL.RawQuery, redact)),
+			zap.Int("status", status),
+			zap.Int64("bytesIn", c.Request.ContentLength),
+			zap.Int("bytesOut", bytesOut),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("clientIp", c.ClientIP()),
+			zap.String("userAgent", c.Request.UserAgent()),
+			zap.String("referer", c.Request.Referer()),
+		}
+		if len(headers) > 0 {
+			fields = append(fields, zap.Object("headers", headerFields{header: c.Request.Header, keys: headers}))
+		}
+		if errmsg := c.Errors.ByType(gin.ErrorTypePrivate).String(); errmsg != "" {
+			fields = append(fields, zap.String("errmsg", errmsg))
+		}
+
+		logger := log.Named("access").With(GinFields(c)...)
+		if status >= http.StatusInternalServerError {
+			logger.Warn("access", fields...)
+		} else {
+			logger.Info("access", fields...)
+		}
+	}
+}
+
+// 将指定的query参数值替换为******
+func redactQuery(rawQuery string, keys map[string]struct{}) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// 无法解析时不记录，避免泄露敏感信息
+		return redactedValue
+	}
+
+	var redacted bool
+	for k, vs := range values {
+		if _, ok := keys[strings.ToLower(k)]; !ok {
+			continue
+		}
+		for i := range vs {
+			vs[i] = redactedValue
+		}
+		redacted = true
+	}
+	if !redacted {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+type headerFields struct {
+	header http.Header
+	keys   []string
+}
+
+func (h headerFields) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, k := range h.keys {
+		if v := h.header.Get(k); v != "" {
+			enc.AddString(k, v)
+		}
 	}
+	return nil
 }
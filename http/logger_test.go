@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	require := require.New(t)
+
+	core, logs := observer.New(zap.DebugLevel)
+	defer zap.ReplaceGlobals(zap.L())
+	zap.ReplaceGlobals(zap.New(core))
+
+	engine := gin.New()
+	engine.Use(LoggerWithConf(&AccessLogConf{
+		Headers:    []string{"x-forwarded-for"},
+		SkipPaths:  []string{"/healthz"},
+		SampleRate: 0.000001,
+	}))
+	engine.GET("/users/:id", func(c *gin.Context) {
+		if c.Param("id") == "0" {
+			c.String(http.StatusNotFound, "not found")
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	engine.GET("/healthz", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	do := func(target string) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		req.Header.Set("User-Agent", "motor-test")
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// 2xx被采样丢弃，健康检查不记录
+	do("/users/1")
+	do("/healthz")
+	require.Equal(0, logs.Len())
+
+	do("/users/0?token=abc&name=motor")
+	entries := logs.All()
+	require.Len(entries, 1)
+	require.Equal("access", entries[0].LoggerName)
+	fields := entries[0].ContextMap()
+	require.Equal("GET", fields["method"])
+	require.Equal("/users/:id", fields["route"])
+	require.Equal("/users/0", fields["path"])
+	require.Equal("name=motor&token=%2A%2A%2A%2A%2A%2A", fields["query"])
+	require.Equal(int64(404), fields["status"])
+	require.Equal(int64(9), fields["bytesOut"])
+	require.Equal("motor-test", fields["userAgent"])
+	require.Equal(map[string]interface{}{"X-Forwarded-For": "10.0.0.1"}, fields["headers"])
+}
+
+func TestRedactQuery(t *testing.T) {
+	keys := map[string]struct{}{"token": {}}
+	require.Equal(t, "", redactQuery("", keys))
+	require.Equal(t, "a=1&b=2", redactQuery("a=1&b=2", keys))
+	require.Equal(t, "Token=%2A%2A%2A%2A%2A%2A", redactQuery("Token=secret", keys))
+	require.Equal(t, redactedValue, redactQuery("token=%zz", keys))
+}
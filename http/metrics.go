@@ -2,32 +2,132 @@ package http
 
 import (
 	"net/http"
-	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kaimixu/motor/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+var (
+	_clientMetricsMutex sync.RWMutex
+	_clientMetrics      *clientMetrics
+)
+
+// http client的指标定义，code为响应状态码，网络错误为error，被熔断为blocked
+var (
+	ClientRequests = metrics.Definition{
+		Name:   "http_client_requests_total",
+		Help:   "http client outbound requests count",
+		Type:   metrics.TypeCounter,
+		Labels: []string{"host", "route", "method", "code"},
+	}
+	ClientDuration = metrics.Definition{
+		Name:   "http_client_request_duration_ms",
+		Help:   "http client outbound requests duration(ms), including retries",
+		Type:   metrics.TypeHistogram,
+		Labels: []string{"host", "route", "method"},
+	}
+	ClientRetries = metrics.Definition{
+		Name:   "http_client_retries_total",
+		Help:   "http client outbound requests retries count",
+		Type:   metrics.TypeCounter,
+		Labels: []string{"host", "route"},
+	}
+)
+
+func ClientMetricDefinitions() []metrics.Definition {
+	return []metrics.Definition{ClientRequests, ClientDuration, ClientRetries}
+}
+
+type clientMetrics struct {
+	reqCnt   *prometheus.CounterVec
+	dur      *prometheus.HistogramVec
+	retryCnt *prometheus.CounterVec
+}
+
+// 注册http client的请求数、耗时及重试次数指标
+func RegisterClientMetrics(r *metrics.Registry) error {
+	m := &clientMetrics{
+		reqCnt:   r.Counter(ClientRequests.Name, ClientRequests.Help, ClientRequests.Labels...),
+		dur:      r.Histogram(ClientDuration.Name, ClientDuration.Help, nil, ClientDuration.Labels...),
+		retryCnt: r.Counter(ClientRetries.Name, ClientRetries.Help, ClientRetries.Labels...),
+	}
+
+	_clientMetricsMutex.Lock()
+	_clientMetrics = m
+	_clientMetricsMutex.Unlock()
+	return nil
+}
+
+func loadClientMetrics() *clientMetrics {
+	_clientMetricsMutex.RLock()
+	defer _clientMetricsMutex.RUnlock()
+	return _clientMetrics
+}
+
+func observeClientRequest(host, route, method, code string, dur time.Duration) {
+	m := loadClientMetrics()
+	if m == nil {
+		return
+	}
+
+	m.reqCnt.WithLabelValues(host, route, method, code).Inc()
+	m.dur.WithLabelValues(host, route, method).Observe(float64(dur) / float64(time.Millisecond))
+}
+
+func observeClientRetry(host, route string) {
+	if m := loadClientMetrics(); m != nil {
+		m.retryCnt.WithLabelValues(host, route).Inc()
+	}
+}
+
+// 使用默认Registry进行请求统计，path标签使用路由模板，避免带id的路径导致标签数量膨胀
 func Metrics() gin.HandlerFunc {
+	return metricsHandler(metrics.Default)
+}
+
+// 使用指定的Registry进行请求统计
+func MetricsWithRegistry(r *metrics.Registry) gin.HandlerFunc {
+	return metricsHandler(func() *metrics.Registry {
+		return r
+	})
+}
+
+func metricsHandler(registry func() *metrics.Registry) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.Request.URL.Path == metrics.DefaultPath {
+		r := registry()
+		if r == nil || c.Request.URL.Path == metrics.DefaultPath {
 			c.Next()
 			return
 		}
 		start := time.Now()
+		if g := r.InFlight(); g != nil {
+			g.Inc()
+			defer g.Dec()
+		}
 
 		c.Next()
 
-		status := c.Writer.Status()
-		sstatus := strconv.Itoa(status)
-		path := c.Request.URL.Path
-
-		metrics.ReqCnt.WithLabelValues(path, sstatus).Inc()
-		metrics.ReqDur.WithLabelValues(path).
-			Observe(float64(time.Since(start).Milliseconds()))
-		if status >= http.StatusInternalServerError {
-			metrics.ReqErr.WithLabelValues(path, sstatus).Inc()
+		path := c.FullPath()
+		if path == "" {
+			path = metrics.UnmatchedPath
 		}
+		status := c.Writer.Status()
+		r.ObserveRequest(path, c.Request.Method, status, time.Since(start),
+			status >= http.StatusInternalServerError)
 	}
 }
+
+// 定时推送默认Registry中的指标，退出时推送剩余的指标
+func (s *Server) OpenMetricsPush(cfg *metrics.PushConf) (*metrics.Pusher, error) {
+	p, err := metrics.NewPusher(metrics.Default(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.Start()
+
+	s.OnShutdown(StageTrace, "metrics", p.Close)
+	return p, nil
+}
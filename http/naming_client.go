@@ -0,0 +1,355 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/naming"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultNamingRetries   = 2
+	defaultMaxFails        = 5
+	defaultEjectTime       = 30 * time.Second
+	defaultMaxEjectPercent = 50
+)
+
+// 服务没有可用实例
+var ErrNoEndpoint = errors.New("no available endpoint")
+
+// 通过名字服务调用其他服务的http client配置
+type NamingClientConf struct {
+	// 服务名，请求的host为服务名时按名字服务选取实例，如http://user-service/users/1
+	Service string
+	// 不为空时仅使用指定机房、部署环境的实例
+	Idc    string
+	PubEnv string
+
+	// 实例出错时换下一个实例重试的次数，连接失败时任何请求都会重试，其他错误仅重试幂等请求
+	// 为0时使用默认值2，小于0时不重试
+	Retries int
+	// 实例连续失败(网络错误或5xx)多少次后摘除，默认5
+	MaxFails int
+	// 摘除时长，默认30s
+	EjectTime conf.Duration
+	// 最多摘除的实例比例(%)，默认50
+	MaxEjectPercent int
+
+	// trace、超时、熔断等配置，host为服务名
+	// 重试仅由Retries换实例进行，Client及Hosts中的Retry不生效，避免两层重试使请求数成倍增加
+	Client ClientConf
+}
+
+// 按名字服务的实例地址负载均衡的http client，可直接当作*http.Client使用
+type NamingClient struct {
+	*http.Client
+
+	transport *namingTransport
+	resolver  naming.Resolver
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+// 发现cfg.Service的实例并监听变化，base为nil时使用http.DefaultTransport
+func NewNamingClient(builder naming.Builder, cfg *NamingClientConf, base http.RoundTripper) (*NamingClient, error) {
+	if cfg.Service == "" {
+		return nil, errors.New("service cannot be empty")
+	}
+	resolver, err := builder.Discovery(cfg.Service)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("builder.Discovery failed, sn:%s", cfg.Service))
+	}
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	nt := newNamingTransport(cfg, base)
+	c := &NamingClient{
+		Client:    &http.Client{Transport: NewClientTransport(withoutRetry(&cfg.Client), nt)},
+		transport: nt,
+		resolver:  resolver,
+		quit:      make(chan struct{}),
+	}
+	if ins, ok := resolver.Fetch(); ok {
+		nt.update(ins)
+	}
+	go c.watch()
+
+	return c, nil
+}
+
+// 复制配置并关闭ClientTransport的重试，重试由namingTransport换实例进行
+func withoutRetry(cfg *ClientConf) *ClientConf {
+	c := *cfg
+	c.Retry.Max = 0
+	if len(cfg.Hosts) > 0 {
+		c.Hosts = make(map[string]*HostConf, len(cfg.Hosts))
+		for host, hc := range cfg.Hosts {
+			if hc != nil {
+				h := *hc
+				h.Retry = nil
+				hc = &h
+			}
+			c.Hosts[host] = hc
+		}
+	}
+	return &c
+}
+
+// 监听实例变化
+func (c *NamingClient) watch() {
+	for {
+		select {
+		case <-c.quit:
+			return
+		case <-c.resolver.Watch():
+			ins, ok := c.resolver.Fetch()
+			if !ok {
+				continue
+			}
+			c.transport.update(ins)
+		}
+	}
+}
+
+// 当前未被摘除的实例地址
+func (c *NamingClient) Endpoints() []string {
+	return c.transport.healthy()
+}
+
+// 停止监听实例变化
+func (c *NamingClient) Close() {
+	c.closeOnce.Do(func() {
+		close(c.quit)
+		c.resolver.Close()
+	})
+}
+
+type endpoint struct {
+	addr string
+	// 连续失败次数
+	fails        int
+	ejectedUntil time.Time
+}
+
+type namingTransport struct {
+	base            http.RoundTripper
+	service         string
+	idc             string
+	pubenv          string
+	retries         int
+	maxFails        int
+	ejectTime       time.Duration
+	maxEjectPercent int
+
+	mutex     sync.Mutex
+	endpoints []*endpoint
+	next      uint32
+}
+
+func newNamingTransport(cfg *NamingClientConf, base http.RoundTripper) *namingTransport {
+	t := &namingTransport{
+		base:            base,
+		service:         cfg.Service,
+		idc:             cfg.Idc,
+		pubenv:          cfg.PubEnv,
+		retries:         cfg.Retries,
+		maxFails:        cfg.MaxFails,
+		ejectTime:       time.Duration(cfg.EjectTime),
+		maxEjectPercent: cfg.MaxEjectPercent,
+	}
+	if t.retries == 0 {
+		t.retries = defaultNamingRetries
+	}
+	if t.maxFails <= 0 {
+		t.maxFails = defaultMaxFails
+	}
+	if t.ejectTime <= 0 {
+		t.ejectTime = defaultEjectTime
+	}
+	if t.maxEjectPercent <= 0 {
+		t.maxEjectPercent = defaultMaxEjectPercent
+	}
+	return t
+}
+
+func (t *namingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.service {
+		return t.base.RoundTrip(req)
+	}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	tried := make(map[*endpoint]bool)
+	ep := t.pick(tried)
+	if ep == nil {
+		return nil, errors.Wrap(ErrNoEndpoint, fmt.Sprintf("service:%s", t.service))
+	}
+	for attempt := 0; ; attempt++ {
+		tried[ep] = true
+
+		// RoundTripper不应修改原请求
+		r := req.Clone(req.Context())
+		r.URL.Host = ep.addr
+		if attempt > 0 && req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, errors.Wrap(err, "req.GetBody failed")
+			}
+		}
+
+		resp, err = t.base.RoundTrip(r)
+		t.report(ep, err != nil || resp.StatusCode >= http.StatusInternalServerError)
+		if attempt >= t.retries || req.Context().Err() != nil || !t.retryable(req, resp, err) {
+			return resp, err
+		}
+		// 所有实例均已尝试过时返回最后一次的结果
+		next := t.pick(tried)
+		if next == nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		log.Named("httpclient").With(log.Fields(req.Context())...).Warn("retry on next endpoint",
+			zap.String("service", t.service),
+			zap.String("addr", ep.addr),
+			zap.Error(err))
+		ep = next
+	}
+}
+
+// 连接失败时请求未发出，任何请求都可重试
+func (t *namingTransport) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		var oe *net.OpError
+		if errors.As(err, &oe) && oe.Op == "dial" {
+			return true
+		}
+		return idempotent(req)
+	}
+	if !idempotent(req) {
+		return false
+	}
+	for _, code := range defaultRetryStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// 轮询选取未尝试过的实例，优先使用未被摘除的实例
+func (t *namingTransport) pick(tried map[*endpoint]bool) *endpoint {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	n := len(t.endpoints)
+	if n == 0 {
+		return nil
+	}
+	now := time.Now()
+	t.next++
+	// 先取模再转换，避免32位平台上int溢出为负数导致越界
+	start := int(t.next % uint32(n))
+	var fallback *endpoint
+	for i := 0; i < n; i++ {
+		ep := t.endpoints[(start+i)%n]
+		if tried[ep] {
+			continue
+		}
+		if now.Before(ep.ejectedUntil) {
+			if fallback == nil {
+				fallback = ep
+			}
+			continue
+		}
+		return ep
+	}
+	return fallback
+}
+
+// 记录请求结果，连续失败达到maxFails时摘除实例，被摘除的实例不超过maxEjectPercent
+func (t *namingTransport) report(ep *endpoint, failed bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !failed {
+		ep.fails = 0
+		return
+	}
+	ep.fails++
+	now := time.Now()
+	if ep.fails < t.maxFails || now.Before(ep.ejectedUntil) {
+		return
+	}
+
+	ejected := 1
+	for _, e := range t.endpoints {
+		if now.Before(e.ejectedUntil) {
+			ejected++
+		}
+	}
+	if ejected*100 > len(t.endpoints)*t.maxEjectPercent {
+		return
+	}
+	ep.fails = 0
+	ep.ejectedUntil = now.Add(t.ejectTime)
+	log.Named("httpclient").Warn("eject endpoint",
+		zap.String("service", t.service),
+		zap.String("addr", ep.addr),
+		zap.Duration("ejectTime", t.ejectTime))
+}
+
+func (t *namingTransport) healthy() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	addrs := make([]string, 0, len(t.endpoints))
+	for _, ep := range t.endpoints {
+		if !now.Before(ep.ejectedUntil) {
+			addrs = append(addrs, ep.addr)
+		}
+	}
+	return addrs
+}
+
+// 按机房、部署环境过滤实例，已有实例保留其摘除状态
+func (t *namingTransport) update(ins []*naming.Instance) {
+	addrs := naming.InstanceAddrs(ins, t.idc, t.pubenv)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	old := make(map[string]*endpoint, len(t.endpoints))
+	for _, ep := range t.endpoints {
+		old[ep.addr] = ep
+	}
+	endpoints := make([]*endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		ep, ok := old[addr]
+		if !ok {
+			ep = &endpoint{addr: addr}
+		}
+		endpoints = append(endpoints, ep)
+	}
+	t.endpoints = endpoints
+	log.Named("httpclient").Info("endpoints updated",
+		zap.String("service", t.service),
+		zap.Strings("addrs", addrs))
+}
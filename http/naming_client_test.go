@@ -0,0 +1,268 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/naming"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	mutex  sync.Mutex
+	ins    []*naming.Instance
+	loaded bool
+	event  chan struct{}
+	closed bool
+}
+
+func (r *fakeResolver) Watch() <-chan struct{} {
+	return r.event
+}
+
+func (r *fakeResolver) Fetch() ([]*naming.Instance, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.ins, r.loaded
+}
+
+func (r *fakeResolver) Close() {
+	r.mutex.Lock()
+	r.closed = true
+	r.mutex.Unlock()
+}
+
+func (r *fakeResolver) set(ins ...*naming.Instance) {
+	r.mutex.Lock()
+	r.ins = ins
+	r.loaded = true
+	r.mutex.Unlock()
+	r.event <- struct{}{}
+}
+
+type fakeBuilder struct {
+	resolver *fakeResolver
+}
+
+func (b *fakeBuilder) Discovery(sn string) (naming.Resolver, error) {
+	return b.resolver, nil
+}
+
+func (b *fakeBuilder) Register(ins *naming.Instance) (context.CancelFunc, error) {
+	return func() {}, nil
+}
+
+func (b *fakeBuilder) Close() {}
+
+// 按名字服务中存储的格式构造实例
+func newTestInstance(t *testing.T, idc, pubenv string, addrs ...string) *naming.Instance {
+	attr, _ := json.Marshal(map[string]interface{}{
+		"data": naming.DefaultInstanceAttr{Addrs: addrs},
+	})
+	b := fmt.Sprintf(`{"name":"user","idc":%q,"pubenv":%q,"attr":%s}`, idc, pubenv, attr)
+	var in naming.Instance
+	require.NoError(t, json.Unmarshal([]byte(b), &in))
+	return &in
+}
+
+func newTestBackend(name string, status int) (*httptest.Server, string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(name))
+	}))
+	return srv, strings.TrimPrefix(srv.URL, "http://")
+}
+
+// 获取一个未监听的地址，连接时失败
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestNamingClient(t *testing.T) {
+	require := require.New(t)
+
+	a, addrA := newTestBackend("a", http.StatusOK)
+	defer a.Close()
+	b, addrB := newTestBackend("b", http.StatusOK)
+	defer b.Close()
+
+	resolver := &fakeResolver{event: make(chan struct{})}
+	client, err := NewNamingClient(&fakeBuilder{resolver: resolver}, &NamingClientConf{
+		Service: "user",
+		Idc:     "bj",
+		PubEnv:  "online",
+	}, nil)
+	require.NoError(err)
+	defer client.Close()
+
+	_, err = client.Get("http://user/ping")
+	require.Error(err)
+	require.Contains(err.Error(), ErrNoEndpoint.Error())
+
+	resolver.set(
+		newTestInstance(t, "bj", "online", addrA, addrB),
+		newTestInstance(t, "sh", "online", closedAddr(t)),
+		newTestInstance(t, "bj", "sandbox", closedAddr(t)),
+	)
+	require.Eventually(func() bool {
+		return len(client.Endpoints()) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// 轮询各实例
+	got := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get("http://user/ping")
+		require.NoError(err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		got[string(body)]++
+	}
+	require.Equal(map[string]int{"a": 2, "b": 2}, got)
+
+	// 服务下线
+	resolver.set()
+	require.Eventually(func() bool {
+		return len(client.Endpoints()) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	client.Close()
+	require.True(resolver.closed)
+}
+
+func TestNamingTransportFailover(t *testing.T) {
+	require := require.New(t)
+
+	ok, addrOK := newTestBackend("ok", http.StatusOK)
+	defer ok.Close()
+	down := closedAddr(t)
+
+	nt := newNamingTransport(&NamingClientConf{
+		Service:   "user",
+		MaxFails:  2,
+		EjectTime: conf.Duration(time.Minute),
+	}, http.DefaultTransport)
+	nt.update([]*naming.Instance{newTestInstance(t, "bj", "online", down, addrOK)})
+	client := &http.Client{Transport: nt}
+
+	// 连接失败时非幂等请求也在下一个实例上重试
+	for i := 0; i < 4; i++ {
+		resp, err := client.Post("http://user/orders", "text/plain", strings.NewReader("x"))
+		require.NoError(err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Equal("ok", string(body))
+	}
+
+	// 连续失败后被摘除，不再被选取
+	require.Equal([]string{addrOK}, nt.healthy())
+	for i := 0; i < 3; i++ {
+		require.Equal(addrOK, nt.pick(map[*endpoint]bool{}).addr)
+	}
+
+	// 不重试时直接返回错误
+	nt.retries = -1
+	nt.endpoints[0].ejectedUntil = time.Time{}
+	nt.next = 1
+	_, err := client.Get("http://user/ping")
+	require.Error(err)
+
+	// 非服务名的host直接请求
+	resp, err := client.Get(ok.URL)
+	require.NoError(err)
+	resp.Body.Close()
+}
+
+func TestNamingTransportEjection(t *testing.T) {
+	require := require.New(t)
+
+	bad, addrBad := newTestBackend("bad", http.StatusServiceUnavailable)
+	defer bad.Close()
+	bad2, addrBad2 := newTestBackend("bad2", http.StatusServiceUnavailable)
+	defer bad2.Close()
+
+	nt := newNamingTransport(&NamingClientConf{
+		Service:  "user",
+		Retries:  -1,
+		MaxFails: 1,
+	}, http.DefaultTransport)
+	nt.update([]*naming.Instance{newTestInstance(t, "bj", "online", addrBad, addrBad2)})
+	client := &http.Client{Transport: nt}
+
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get("http://user/ping")
+		require.NoError(err)
+		resp.Body.Close()
+		require.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	// 最多摘除50%的实例
+	require.Len(nt.healthy(), 1)
+
+	// 实例更新后保留摘除状态
+	nt.update([]*naming.Instance{newTestInstance(t, "bj", "online", addrBad, addrBad2)})
+	require.Len(nt.healthy(), 1)
+
+	// 全部实例均已尝试过时返回最后的错误
+	nt.retries = 5
+	resp, err := client.Get("http://user/ping")
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestNamingClientRetry(t *testing.T) {
+	require := require.New(t)
+
+	var mutex sync.Mutex
+	got := make(map[string]int)
+	backend := func(name string) (*httptest.Server, string) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mutex.Lock()
+			got[name]++
+			mutex.Unlock()
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		return srv, strings.TrimPrefix(srv.URL, "http://")
+	}
+	a, addrA := backend("a")
+	defer a.Close()
+	b, addrB := backend("b")
+	defer b.Close()
+
+	resolver := &fakeResolver{event: make(chan struct{})}
+	retry := &RetryConf{Max: 2, Backoff: conf.Duration(time.Millisecond)}
+	client, err := NewNamingClient(&fakeBuilder{resolver: resolver}, &NamingClientConf{
+		Service: "user",
+		Retries: 1,
+		Client: ClientConf{
+			Retry: *retry,
+			Hosts: map[string]*HostConf{"user": {Retry: retry}},
+		},
+	}, nil)
+	require.NoError(err)
+	defer client.Close()
+	resolver.set(newTestInstance(t, "bj", "online", addrA, addrB))
+	require.Eventually(func() bool {
+		return len(client.Endpoints()) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// 仅换实例重试，每个实例请求一次
+	resp, err := client.Get("http://user/ping")
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(map[string]int{"a": 1, "b": 1}, got)
+}
@@ -19,22 +19,23 @@ func pprofHandler(h http.HandlerFunc) gin.HandlerFunc {
 	}
 }
 
+// 在业务服务上挂载pprof，建议改用独立监听的管理服务(OpenAdmin)，避免暴露到公网
 func OpenPerf(engine *gin.Engine) {
 	perfOnce.Do(func() {
-		prefixRouter := engine.Group("/debug/pprof")
-		{
-			prefixRouter.GET("/", pprofHandler(pprof.Index))
-			prefixRouter.GET("/cmdline", pprofHandler(pprof.Cmdline))
-			prefixRouter.GET("/profile", pprofHandler(pprof.Profile))
-			prefixRouter.GET("/symbol", pprofHandler(pprof.Symbol))
-			prefixRouter.GET("/trace", pprofHandler(pprof.Trace))
-			prefixRouter.GET("/allocs", pprofHandler(pprof.Handler("allocs").ServeHTTP))
-			prefixRouter.GET("/block", pprofHandler(pprof.Handler("block").ServeHTTP))
-			prefixRouter.GET("/goroutine", pprofHandler(pprof.Handler("goroutine").ServeHTTP))
-			prefixRouter.GET("/heap", pprofHandler(pprof.Handler("heap").ServeHTTP))
-			prefixRouter.GET("/mutex", pprofHandler(pprof.Handler("mutex").ServeHTTP))
-			prefixRouter.GET("/threadcreate", pprofHandler(pprof.Handler("threadcreate").ServeHTTP))
-		}
-		return
+		registerPprof(engine.Group("/debug/pprof"))
 	})
 }
+
+func registerPprof(prefixRouter gin.IRoutes) {
+	prefixRouter.GET("/", pprofHandler(pprof.Index))
+	prefixRouter.GET("/cmdline", pprofHandler(pprof.Cmdline))
+	prefixRouter.GET("/profile", pprofHandler(pprof.Profile))
+	prefixRouter.GET("/symbol", pprofHandler(pprof.Symbol))
+	prefixRouter.GET("/trace", pprofHandler(pprof.Trace))
+	prefixRouter.GET("/allocs", pprofHandler(pprof.Handler("allocs").ServeHTTP))
+	prefixRouter.GET("/block", pprofHandler(pprof.Handler("block").ServeHTTP))
+	prefixRouter.GET("/goroutine", pprofHandler(pprof.Handler("goroutine").ServeHTTP))
+	prefixRouter.GET("/heap", pprofHandler(pprof.Handler("heap").ServeHTTP))
+	prefixRouter.GET("/mutex", pprofHandler(pprof.Handler("mutex").ServeHTTP))
+	prefixRouter.GET("/threadcreate", pprofHandler(pprof.Handler("threadcreate").ServeHTTP))
+}
@@ -1,11 +1,10 @@
 package http
 
 import (
-	"net/http"
-
 	sentinel "github.com/alibaba/sentinel-golang/api"
 	"github.com/alibaba/sentinel-golang/core/base"
 	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/ecode"
 	"github.com/kaimixu/motor/tolerant"
 )
 
@@ -14,7 +13,7 @@ func Ratelimit() gin.HandlerFunc {
 		if tolerant.Svc.FlowRule.Enabled {
 			e, err := sentinel.Entry(tolerant.Svc.FlowRule.Resource, sentinel.WithTrafficType(base.Inbound))
 			if err != nil {
-				c.AbortWithStatus(http.StatusTooManyRequests)
+				AbortWithError(c, ecode.TooManyRequests.WithCause(err))
 				return
 			}
 			defer e.Exit()
@@ -3,14 +3,13 @@ package http
 import (
 	"fmt"
 	"net"
-	"net/http"
 	"net/http/httputil"
 	"os"
 	"runtime/debug"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
+	"github.com/kaimixu/motor/ecode"
 )
 
 func Recovery() gin.HandlerFunc {
@@ -34,14 +33,14 @@ func Recovery() gin.HandlerFunc {
 				}
 				stack := string(debug.Stack())
 				emsg := fmt.Sprintf("catch panic: %s\n%v\n%s\n", string(req), err, stack)
-				zap.L().Error(emsg)
+				FromGin(c).Error(emsg)
 
 				// If the connection is dead, we can't write a status to it.
 				if brokenPipe {
 					c.Error(err.(error)) // nolint: errcheck
 					c.Abort()
 				} else {
-					c.AbortWithStatus(http.StatusInternalServerError)
+					AbortWithError(c, ecode.ServerErr.WithCause(fmt.Errorf("panic: %v", err)))
 				}
 			}
 		}()
@@ -0,0 +1,212 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/naming"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const defaultRegisterInterval = 5 * time.Second
+
+// 启动后自动注册到名字服务的配置
+type RegisterConf struct {
+	// 服务名，为空时使用application.toml中[App]的Name
+	Name   string
+	Idc    string
+	PubEnv string
+	// 注册的地址，为空时使用监听地址，监听所有网卡时取第一个非回环的ipv4地址
+	Addr string
+	// 开启后仅在就绪检查通过时注册，检查失败时注销，恢复后重新注册
+	HealthyOnly bool
+	// 注册失败时的重试间隔及HealthyOnly时的检查间隔，默认5s
+	CheckInterval conf.Duration
+}
+
+// 指定自动注册使用的名字服务，默认使用naming.Build()
+func (s *Server) SetNamingBuilder(b naming.Builder) {
+	s.namingBuilder = b
+}
+
+type registrar struct {
+	builder  naming.Builder
+	ins      *naming.Instance
+	interval time.Duration
+	// 为nil时不检查
+	healthy func(ctx context.Context) bool
+
+	cancel context.CancelFunc
+	quit   chan struct{}
+	// run退出后关闭
+	done chan struct{}
+	// 注销完成后关闭
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// 监听成功后开始注册，并在StageDeregister阶段注销
+func (s *Server) startRegister(addr net.Addr) error {
+	cfg := s.conf.Register
+	if cfg == nil {
+		return nil
+	}
+	ins, err := s.registerInstance(cfg, addr)
+	if err != nil {
+		return err
+	}
+	builder := s.namingBuilder
+	if builder == nil {
+		if builder = naming.Build(); builder == nil {
+			return errors.New("naming.Build failed")
+		}
+	}
+
+	r := &registrar{
+		builder:  builder,
+		ins:      ins,
+		interval: time.Duration(cfg.CheckInterval),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if r.interval <= 0 {
+		r.interval = defaultRegisterInterval
+	}
+	if cfg.HealthyOnly {
+		r.healthy = func(ctx context.Context) bool {
+			if !s.Ready() {
+				return false
+			}
+			ok, _ := s.health.Check(ctx)
+			return ok
+		}
+	}
+	go r.run()
+
+	s.OnShutdown(StageDeregister, "naming", r.stop)
+	return nil
+}
+
+// 按配置构造注册的实例
+func (s *Server) registerInstance(cfg *RegisterConf, addr net.Addr) (*naming.Instance, error) {
+	name := cfg.Name
+	if name == "" {
+		var app struct{ Name string }
+		if err := conf.UnmarshalSection("application.toml", "App", &app); err != nil {
+			return nil, err
+		}
+		name = app.Name
+	}
+	if name == "" || cfg.Idc == "" || cfg.PubEnv == "" {
+		return nil, fmt.Errorf("register name, idc and pubEnv cannot be empty, name:%s, idc:%s, pubEnv:%s",
+			name, cfg.Idc, cfg.PubEnv)
+	}
+
+	advertise := cfg.Addr
+	if advertise == "" {
+		var err error
+		if advertise, err = advertiseAddr(addr); err != nil {
+			return nil, err
+		}
+	}
+	return &naming.Instance{
+		Name:   name,
+		Idc:    cfg.Idc,
+		PubEnv: cfg.PubEnv,
+		Attr:   naming.InstanceAttr{Data: &naming.DefaultInstanceAttr{Addrs: []string{advertise}}},
+	}, nil
+}
+
+// 监听地址为空或unspecified(如:8080)时替换为本机第一个非回环的ipv4地址
+func advertiseAddr(addr net.Addr) (string, error) {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return "", fmt.Errorf("register addr is required for %s listener", addr.Network())
+	}
+	if len(tcpAddr.IP) > 0 && !tcpAddr.IP.IsUnspecified() {
+		return tcpAddr.String(), nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", errors.Wrap(err, "net.InterfaceAddrs failed")
+	}
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		return net.JoinHostPort(ipNet.IP.String(), fmt.Sprint(tcpAddr.Port)), nil
+	}
+	return "", errors.New("no non-loopback ipv4 address found")
+}
+
+// 注册失败时按interval重试，HealthyOnly时按检查结果注册或注销
+func (r *registrar) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.sync()
+		select {
+		case <-r.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *registrar) sync() {
+	want := true
+	if r.healthy != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+		want = r.healthy(ctx)
+		cancel()
+	}
+
+	switch {
+	case want && r.cancel == nil:
+		cancel, err := r.builder.Register(r.ins)
+		if err != nil {
+			log.Named("naming").Error("register failed",
+				zap.String("name", r.ins.Name),
+				zap.Error(err))
+			return
+		}
+		r.cancel = cancel
+		log.Named("naming").Info("register success", zap.Any("ins", r.ins))
+	case !want && r.cancel != nil:
+		log.Named("naming").Warn("readiness check failed, deregister", zap.String("name", r.ins.Name))
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+// 停止注册并注销，超过ctx的期限时不再等待并返回ctx.Err()，此时注销仍在后台进行
+func (r *registrar) stop(ctx context.Context) error {
+	r.once.Do(func() {
+		close(r.quit)
+		go func() {
+			defer close(r.stopped)
+			<-r.done
+			if r.cancel != nil {
+				r.cancel()
+				r.cancel = nil
+			}
+		}()
+	})
+	select {
+	case <-r.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/health"
+	"github.com/kaimixu/motor/naming"
+	"github.com/stretchr/testify/require"
+)
+
+// 记录注册及注销事件
+type recordBuilder struct {
+	fakeBuilder
+	mutex      sync.Mutex
+	events     []string
+	registered *naming.Instance
+}
+
+func (b *recordBuilder) Register(ins *naming.Instance) (context.CancelFunc, error) {
+	b.record("register")
+	b.mutex.Lock()
+	b.registered = ins
+	b.mutex.Unlock()
+	return func() {
+		b.mutex.Lock()
+		b.registered = nil
+		b.mutex.Unlock()
+		b.record("deregister")
+	}, nil
+}
+
+func (b *recordBuilder) record(event string) {
+	b.mutex.Lock()
+	b.events = append(b.events, event)
+	b.mutex.Unlock()
+}
+
+func (b *recordBuilder) instance() *naming.Instance {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.registered
+}
+
+func (b *recordBuilder) history() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]string(nil), b.events...)
+}
+
+func TestRegister(t *testing.T) {
+	require := require.New(t)
+
+	srv := DefaultServer(&ServerConf{
+		Addr:            "127.0.0.1:0",
+		ShutdownTimeout: conf.Duration(3 * time.Second),
+		Register: &RegisterConf{
+			Name:          "user",
+			Idc:           "bj",
+			PubEnv:        "online",
+			HealthyOnly:   true,
+			CheckInterval: conf.Duration(20 * time.Millisecond),
+		},
+	})
+	builder := &recordBuilder{}
+	srv.SetNamingBuilder(builder)
+
+	var healthy, checks int32
+	srv.AddChecker(health.NewChecker("mysql", func(ctx context.Context) error {
+		atomic.AddInt32(&checks, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}), 0)
+	started := make(chan struct{})
+	srv.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		builder.record("request done")
+		c.String(http.StatusOK, "done")
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Run()
+	}()
+
+	// 就绪检查通过后才注册
+	require.Eventually(func() bool {
+		return atomic.LoadInt32(&checks) >= 2
+	}, time.Second, 10*time.Millisecond)
+	require.Nil(builder.instance())
+	atomic.StoreInt32(&healthy, 1)
+	require.Eventually(func() bool {
+		return builder.instance() != nil
+	}, time.Second, 10*time.Millisecond)
+	in := builder.instance()
+	require.Equal("user", in.Name)
+	require.Equal("bj", in.Idc)
+	require.Equal("online", in.PubEnv)
+	require.Equal(&naming.DefaultInstanceAttr{Addrs: []string{srv.Addr().String()}}, in.Attr.Data)
+
+	// 检查失败时注销，恢复后重新注册
+	atomic.StoreInt32(&healthy, 0)
+	require.Eventually(func() bool {
+		return builder.instance() == nil
+	}, time.Second, 10*time.Millisecond)
+	atomic.StoreInt32(&healthy, 1)
+	require.Eventually(func() bool {
+		return builder.instance() != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 退出时先注销再等待处理中的请求完成
+	respCh := asyncGet(fmt.Sprintf("http://%s/slow", srv.Addr()))
+	<-started
+	srv.Shutdown()
+
+	require.NoError(<-errCh)
+	require.NoError(<-respCh)
+	require.Equal([]string{"register", "deregister", "register", "deregister", "request done"}, builder.history())
+}
+
+func TestRegisterInstance(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+
+	srv := DefaultServer(&ServerConf{})
+	tcpAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8080}
+
+	// 服务名默认取[App]的Name
+	in, err := srv.registerInstance(&RegisterConf{Idc: "bj", PubEnv: "online"}, tcpAddr)
+	require.NoError(err)
+	require.Equal("motor", in.Name)
+	require.Equal(&naming.DefaultInstanceAttr{Addrs: []string{"10.0.0.1:8080"}}, in.Attr.Data)
+
+	in, err = srv.registerInstance(&RegisterConf{Name: "user", Idc: "bj", PubEnv: "online", Addr: "user.svc:80"}, tcpAddr)
+	require.NoError(err)
+	require.Equal(&naming.DefaultInstanceAttr{Addrs: []string{"user.svc:80"}}, in.Attr.Data)
+
+	_, err = srv.registerInstance(&RegisterConf{Name: "user", Idc: "bj"}, tcpAddr)
+	require.Error(err)
+
+	// unix socket需指定注册地址
+	_, err = srv.registerInstance(&RegisterConf{Name: "user", Idc: "bj", PubEnv: "online"},
+		&net.UnixAddr{Name: "/tmp/motor.sock", Net: "unix"})
+	require.Error(err)
+
+	// 未指定ip(如:8080)时同样视为监听所有网卡
+	a, err := advertiseAddr(&net.TCPAddr{Port: 8080})
+	require.NoError(err)
+	host, port, err := net.SplitHostPort(a)
+	require.NoError(err)
+	require.Equal("8080", port)
+	require.NotEmpty(host)
+
+	// 监听所有网卡时不使用回环地址
+	a, err = advertiseAddr(&net.TCPAddr{IP: net.IPv4zero, Port: 8080})
+	require.NoError(err)
+	host, port, err = net.SplitHostPort(a)
+	require.NoError(err)
+	require.Equal("8080", port)
+	require.False(net.ParseIP(host).IsLoopback())
+}
+
+func TestRegisterEndlessFailed(t *testing.T) {
+	require := require.New(t)
+
+	srv := DefaultServer(&ServerConf{
+		Addr:            "127.0.0.1:0",
+		GracefulRestart: true,
+		Register:        &RegisterConf{Name: "user"},
+	})
+	srv.SetNamingBuilder(&recordBuilder{})
+	var flushed bool
+	srv.OnShutdown(StageLog, "flush", func(ctx context.Context) error {
+		flushed = true
+		return nil
+	})
+
+	// 监听成功后注册失败时关闭服务并执行退出回调
+	err := srv.Run()
+	require.Error(err)
+	require.Contains(err.Error(), "cannot be empty")
+	require.True(flushed)
+	require.False(srv.Ready())
+}
+
+func TestRegistrarStopTimeout(t *testing.T) {
+	require := require.New(t)
+
+	var deregistered int32
+	release := make(chan struct{})
+	r := &registrar{
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		cancel: func() {
+			<-release
+			atomic.AddInt32(&deregistered, 1)
+		},
+	}
+	close(r.done)
+
+	// 注销阻塞时超时返回，注销在后台继续
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Equal(context.DeadlineExceeded, r.stop(ctx))
+
+	close(release)
+	require.NoError(r.stop(context.Background()))
+	require.Equal(int32(1), atomic.LoadInt32(&deregistered))
+	require.Nil(r.cancel)
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/ecode"
+	"github.com/kaimixu/motor/trace"
+)
+
+const (
+	ErrorFormatJSON    = "json"
+	ErrorFormatProblem = "problem"
+)
+
+// 自定义错误响应体，仅Format=json时生效
+type ErrorEnvelope func(c *gin.Context, e *ecode.Error) interface{}
+
+type Renderer struct {
+	// json: 统一的json响应体，problem: RFC 7807 application/problem+json
+	Format   string
+	Envelope ErrorEnvelope
+}
+
+var (
+	renderer = &Renderer{Format: ErrorFormatJSON}
+)
+
+// 设置全局的错误响应格式
+func SetRenderer(r *Renderer) {
+	renderer = r
+}
+
+// 处理函数通过返回error输出错误响应
+func Handle(h func(c *gin.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h(c); err != nil {
+			AbortWithError(c, err)
+		}
+	}
+}
+
+// 记录错误、输出错误响应并中止后续处理
+func AbortWithError(c *gin.Context, err error) {
+	c.Error(err) // nolint: errcheck
+	renderer.Render(c, err)
+	c.Abort()
+}
+
+// 处理函数仅调用了c.Error而未写响应时，统一输出最后一个错误
+func ErrorRender() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Written() || len(c.Errors) == 0 {
+			return
+		}
+		renderer.Render(c, c.Errors.Last().Err)
+	}
+}
+
+func (r *Renderer) Render(c *gin.Context, err error) {
+	e := ecode.FromError(err)
+	if c.Writer.Written() || e == nil {
+		return
+	}
+	traceID, _ := trace.TraceID(c)
+
+	if r.Format == ErrorFormatProblem {
+		problem := gin.H{
+			"type":     "about:blank",
+			"title":    http.StatusText(e.Status),
+			"status":   e.Status,
+			"detail":   e.Message,
+			"instance": c.Request.URL.Path,
+			"code":     e.Code,
+		}
+		if len(e.Details) != 0 {
+			problem["details"] = e.Details
+		}
+		if traceID != "" {
+			problem["trace_id"] = traceID
+		}
+		c.Render(e.Status, problemRender{data: problem})
+		return
+	}
+
+	if r.Envelope != nil {
+		c.JSON(e.Status, r.Envelope(c, e))
+		return
+	}
+	body := gin.H{
+		"code":    e.Code,
+		"message": e.Message,
+	}
+	if len(e.Details) != 0 {
+		body["details"] = e.Details
+	}
+	if traceID != "" {
+		body["trace_id"] = traceID
+	}
+	c.JSON(e.Status, body)
+}
+
+type problemRender struct {
+	data interface{}
+}
+
+func (p problemRender) Render(w http.ResponseWriter) error {
+	p.WriteContentType(w)
+	return json.NewEncoder(w).Encode(p.data)
+}
+
+func (p problemRender) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	if val := header["Content-Type"]; len(val) == 0 {
+		header["Content-Type"] = []string{"application/problem+json; charset=utf-8"}
+	}
+}
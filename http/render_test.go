@@ -0,0 +1,62 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/ecode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorRender(t *testing.T) {
+	require := require.New(t)
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(ErrorRender(), Recovery())
+	engine.GET("/handle", Handle(func(c *gin.Context) error {
+		return ecode.BadRequest.WithDetail("name", "required")
+	}))
+	engine.GET("/error", func(c *gin.Context) {
+		c.Error(errors.New("internal")) // nolint: errcheck
+	})
+	engine.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	cases := []struct {
+		path   string
+		status int
+	}{
+		{"/handle", http.StatusBadRequest},
+		{"/error", http.StatusInternalServerError},
+		{"/panic", http.StatusInternalServerError},
+	}
+	for _, cs := range cases {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, cs.path, nil))
+		require.Equal(cs.status, w.Code, cs.path)
+
+		var body map[string]interface{}
+		require.NoError(json.Unmarshal(w.Body.Bytes(), &body))
+		require.Equal(float64(cs.status), body["code"], cs.path)
+		require.NotEmpty(body["message"], cs.path)
+	}
+
+	SetRenderer(&Renderer{Format: ErrorFormatProblem})
+	defer SetRenderer(&Renderer{Format: ErrorFormatJSON})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/handle", nil))
+	require.Equal(http.StatusBadRequest, w.Code)
+	require.Contains(w.Header().Get("Content-Type"), "application/problem+json")
+
+	var problem map[string]interface{}
+	require.NoError(json.Unmarshal(w.Body.Bytes(), &problem))
+	require.Equal(float64(http.StatusBadRequest), problem["status"])
+	require.Equal("/handle", problem["instance"])
+	require.Equal(map[string]interface{}{"name": "required"}, problem["details"])
+}
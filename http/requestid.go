@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/log"
+)
+
+// 使用请求携带的X-Request-ID，没有时生成，并回写到响应header中
+// request id存放在gin.Context及c.Request.Context()中，FromGin、log.Ctx会输出到日志
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(log.RequestIDHeader)
+		if !log.ValidRequestID(id) {
+			id = log.NewRequestID()
+		}
+
+		c.Set(log.RequestIDKey, id)
+		c.Request = c.Request.WithContext(log.WithRequestID(c.Request.Context(), id))
+		c.Header(log.RequestIDHeader, id)
+
+		c.Next()
+	}
+}
+
+// 获取RequestID中间件设置的request id
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(log.RequestIDKey)
+}
+
+// 将请求ctx中的request id通过X-Request-ID传递给下游
+// 使用方式：&http.Client{Transport: &RequestIDTransport{}}，请求需携带c.Request.Context()
+type RequestIDTransport struct {
+	// 为nil时使用http.DefaultTransport
+	Base http.RoundTripper
+}
+
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	id, ok := log.RequestIDFrom(req.Context())
+	if !ok || req.Header.Get(log.RequestIDHeader) != "" {
+		return base.RoundTrip(req)
+	}
+
+	// RoundTripper不应修改原请求
+	req = req.Clone(req.Context())
+	req.Header.Set(log.RequestIDHeader, id)
+	return base.RoundTrip(req)
+}
+
+// 创建转发request id的http client
+func NewRequestIDClient(base http.RoundTripper) *http.Client {
+	return &http.Client{Transport: &RequestIDTransport{Base: base}}
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID(t *testing.T) {
+	require := require.New(t)
+
+	core, logs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.L())
+	zap.ReplaceGlobals(zap.New(core))
+
+	// 下游服务，返回收到的request id
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(log.RequestIDHeader)))
+	}))
+	defer downstream.Close()
+	client := NewRequestIDClient(nil)
+
+	engine := gin.New()
+	engine.Use(Logger(), RequestID())
+	engine.GET("/ping", func(c *gin.Context) {
+		req, _ := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, downstream.URL, nil)
+		resp, err := client.Do(req)
+		require.NoError(err)
+		defer resp.Body.Close()
+
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(err)
+		c.String(http.StatusOK, string(b)+"|"+GetRequestID(c))
+	})
+
+	do := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		if id != "" {
+			req.Header.Set(log.RequestIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("abc-123")
+	require.Equal("abc-123", w.Header().Get(log.RequestIDHeader))
+	require.Equal("abc-123|abc-123", w.Body.String())
+
+	// 生成新的request id
+	w = do("")
+	id := w.Header().Get(log.RequestIDHeader)
+	require.Len(id, 32)
+	require.Equal(id+"|"+id, w.Body.String())
+
+	w = do("bad id\n")
+	require.NotEqual("bad id\n", w.Header().Get(log.RequestIDHeader))
+	require.Len(w.Header().Get(log.RequestIDHeader), 32)
+
+	// accesslog携带request id
+	entries := logs.All()
+	require.Len(entries, 3)
+	require.Equal("abc-123", entries[0].ContextMap()["request_id"])
+	require.Equal(id, entries[1].ContextMap()["request_id"])
+}
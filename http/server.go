@@ -1,43 +1,132 @@
 package http
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/fvbock/endless"
 	"github.com/gin-gonic/gin"
 	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/ecode"
+	"github.com/kaimixu/motor/health"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/mysql"
+	"github.com/kaimixu/motor/naming"
+	"github.com/kaimixu/motor/redis"
+	"github.com/kaimixu/motor/trace"
+	"go.uber.org/zap"
 )
 
 type ServerConf struct {
-	Addr         string
-	ReadTimeout  conf.Duration
-	WriteTimeout conf.Duration
-	CertFile     string
-	KeyFile      string
+	// 监听类型：tcp(默认)、unix、systemd；unix时Addr为socket文件路径，
+	// systemd时Addr为LISTEN_FDNAMES中的名字，为空取第一个
+	Network           string
+	Addr              string
+	ReadTimeout       conf.Duration
+	ReadHeaderTimeout conf.Duration
+	WriteTimeout      conf.Duration
+	IdleTimeout       conf.Duration
+	MaxHeaderBytes    conf.ByteSize
+	// 请求body的最大长度，0表示不限制
+	MaxBodySize conf.ByteSize
+
+	// 证书文件变化后自动重新加载
+	CertFile string
+	KeyFile  string
+	// 最低tls版本：1.0、1.1、1.2(默认)、1.3
+	MinTLSVersion string
+	// 为空时使用go默认的加密套件
+	CipherSuites []string
+	// 配置后开启双向认证(mTLS)
+	ClientCAFile string
+	// 为true时客户端可不提供证书，提供时仍需校验
+	ClientCertOptional bool
+
+	// 收到退出信号后等待处理中请求完成的最长时间，默认10s
+	ShutdownTimeout conf.Duration
+	// 置为未就绪后延迟多久开始关闭监听，以便负载均衡摘除流量
+	ShutdownDelay conf.Duration
+	// 开启后基于endless支持SIGHUP平滑重启，容器环境下无需开启
+	GracefulRestart bool
+
+	// 配置后监听成功时自动注册到名字服务，退出时在等待处理中请求完成之前注销
+	Register *RegisterConf
+
+	// accesslog配置
+	AccessLog AccessLogConf
+	// 配置后记录请求及响应body，用于排查问题
+	BodyDump *BodyDumpConf
 }
 
 type Server struct {
 	*gin.Engine
-	//srv http.Server
+	srv  *http.Server
 	conf *ServerConf
+
+	ready int32
+	// 实际监听的地址，监听成功后设置
+	addr     atomic.Value
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	hookMutex sync.Mutex
+	hooks     []shutdownHook
+
+	health     *health.Registry
+	healthOnce sync.Once
+
+	namingBuilder naming.Builder
+}
+
+// 从application.toml的[Server]中加载配置
+func LoadServerConf() (*ServerConf, error) {
+	var cfg ServerConf
+	if err := conf.UnmarshalSection("application.toml", "Server", &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
 }
 
 func DefaultServer(svc *ServerConf) *Server {
 	server := &Server{
 		conf:   svc,
 		Engine: gin.New(),
+		quit:   make(chan struct{}),
+		health: health.NewRegistry(),
 	}
 
 	server.Engine.NoRoute(func(c *gin.Context) {
-		c.Data(404, "text/plain", []byte("404 page not found"))
-		c.Abort()
+		AbortWithError(c, ecode.NotFound)
 	})
 	server.Engine.NoMethod(func(c *gin.Context) {
-		c.Data(405, "text/plain", []byte("Method Not Allowed"))
-		c.Abort()
+		AbortWithError(c, ecode.MethodNotAllowed)
 	})
 
-	server.Engine.Use(Logger(), Recovery(), Trace())
+	server.Engine.Use(LoggerWithConf(&svc.AccessLog), RequestID(), ErrorRender(), Recovery(), Trace())
+	if svc.MaxBodySize > 0 {
+		server.Engine.Use(BodyLimit(int64(svc.MaxBodySize)))
+	}
+	if svc.BodyDump != nil {
+		server.Engine.Use(BodyDump(svc.BodyDump))
+	}
+
+	server.OnShutdown(StageTrace, "trace", func(ctx context.Context) error {
+		return trace.Close()
+	})
+	// 未初始化时Close不做处理
+	server.OnShutdown(StageStorage, "mysql", HookFunc(mysql.Close))
+	server.OnShutdown(StageStorage, "redis", HookFunc(redis.Close))
+	server.OnShutdown(StageLog, "log", func(ctx context.Context) error {
+		return log.Sync()
+	})
 	return server
 }
 
@@ -48,28 +137,181 @@ func (s *Server) Use(middleware ...gin.HandlerFunc) *Server {
 }
 
 // starts listening and serving HTTP requests
+// 阻塞至收到SIGTERM/SIGINT或调用Shutdown，之后平滑退出
 func (s *Server) Run() (err error) {
-	/*s.srv = http.Server{
-		Addr:         s.conf.Addr,
-		Handler:      s.Engine,
-		ReadTimeout:  time.Duration(s.conf.ReadTimeout),
-		WriteTimeout: time.Duration(s.conf.WriteTimeout),
-	}
-	err = s.srv.ListenAndServe()
-	*/
-	endless.DefaultReadTimeOut = time.Duration(s.conf.ReadTimeout)
-	endless.DefaultWriteTimeOut = time.Duration(s.conf.WriteTimeout)
-
-	err = endless.ListenAndServe(s.conf.Addr, s.Engine)
-	return
+	return s.serve(false)
 }
 
 // starts listening and serving HTTPS requests
 func (s *Server) RunTLS() (err error) {
-	endless.DefaultReadTimeOut = time.Duration(s.conf.ReadTimeout)
-	endless.DefaultWriteTimeOut = time.Duration(s.conf.WriteTimeout)
+	return s.serve(true)
+}
+
+// 服务是否就绪，退出过程中返回false
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// 实际监听的地址，如Addr配置为:0时可获取分配的端口，监听成功之前返回nil
+func (s *Server) Addr() net.Addr {
+	addr, _ := s.addr.Load().(net.Addr)
+	return addr
+}
+
+// 触发平滑退出，Run/RunTLS将在退出完成后返回
+func (s *Server) Shutdown() {
+	s.quitOnce.Do(func() {
+		close(s.quit)
+	})
+}
+
+func (s *Server) serve(useTLS bool) error {
+	if s.conf.GracefulRestart {
+		return s.serveEndless(useTLS)
+	}
+
+	s.srv = s.newHTTPServer()
+	if useTLS {
+		tlsCfg, err := s.conf.tlsConfig()
+		if err != nil {
+			return err
+		}
+		s.srv.TLSConfig = tlsCfg
+	}
+	ln, err := listen(s.conf.Network, s.conf.Addr)
+	if err != nil {
+		return err
+	}
+	s.addr.Store(ln.Addr())
+
+	errCh := make(chan error, 1)
+	go func() {
+		if useTLS {
+			// 证书由TLSConfig.GetCertificate提供
+			errCh <- s.srv.ServeTLS(ln, "", "")
+		} else {
+			errCh <- s.srv.Serve(ln)
+		}
+	}()
+	atomic.StoreInt32(&s.ready, 1)
+	if err := s.startRegister(ln.Addr()); err != nil {
+		atomic.StoreInt32(&s.ready, 0)
+		s.srv.Close()
+		s.runHooks(StageDeregister, StageLog)
+		return err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-errCh:
+		// 服务异常退出，仍需释放资源
+		atomic.StoreInt32(&s.ready, 0)
+		zap.L().Error("http server exited", zap.Error(err))
+		s.runHooks(StageDeregister, StageLog)
+		return err
+	case sig := <-sigCh:
+		zap.L().Info("receive signal, shutting down", zap.String("signal", sig.String()))
+	case <-s.quit:
+		zap.L().Info("shutting down")
+	}
+
+	return s.shutdown()
+}
+
+func (s *Server) newHTTPServer() *http.Server {
+	return &http.Server{
+		Addr:              s.conf.Addr,
+		Handler:           s.Engine,
+		ReadTimeout:       time.Duration(s.conf.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(s.conf.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(s.conf.WriteTimeout),
+		IdleTimeout:       time.Duration(s.conf.IdleTimeout),
+		MaxHeaderBytes:    int(s.conf.MaxHeaderBytes),
+	}
+}
+
+// 依次执行：置为未就绪、摘除流量、等待处理中请求完成、释放资源
+func (s *Server) shutdown() error {
+	atomic.StoreInt32(&s.ready, 0)
+	s.runHooks(StageDeregister, StageDeregister)
+	if d := time.Duration(s.conf.ShutdownDelay); d > 0 {
+		time.Sleep(d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+	err := s.srv.Shutdown(ctx)
+	cancel()
+	if err != nil {
+		zap.L().Error("http server shutdown failed", zap.Error(err))
+	}
 
-	err = endless.ListenAndServeTLS(s.conf.Addr, s.conf.CertFile, s.conf.KeyFile, s.Engine)
+	s.runHooks(StageTrace, StageLog)
+	return err
+}
+
+// 基于endless的平滑重启模式，由endless处理信号
+func (s *Server) serveEndless(useTLS bool) (err error) {
+	if s.conf.Network != "" && s.conf.Network != NetworkTCP {
+		return fmt.Errorf("gracefulRestart only supports tcp network")
+	}
+	srv := endless.NewServer(s.conf.Addr, s.Engine)
+	hs := s.newHTTPServer()
+	srv.ReadTimeout = hs.ReadTimeout
+	srv.ReadHeaderTimeout = hs.ReadHeaderTimeout
+	srv.WriteTimeout = hs.WriteTimeout
+	srv.IdleTimeout = hs.IdleTimeout
+	srv.MaxHeaderBytes = hs.MaxHeaderBytes
+	if useTLS {
+		// endless自行加载证书，仅沿用版本、套件及客户端认证配置
+		tlsCfg, err := s.conf.tlsConfig()
+		if err != nil {
+			return err
+		}
+		tlsCfg.GetCertificate = nil
+		srv.TLSConfig = tlsCfg
+	}
+
+	// SIGHUP重启时由新进程接管流量，旧进程退出时不能摘除流量；
+	// SIGTERM时先摘除流量再由endless关闭监听
+	var deregistered int32
+	_ = srv.RegisterSignalHook(endless.PRE_SIGNAL, syscall.SIGHUP, func() {
+		atomic.StoreInt32(&deregistered, 1)
+	})
+	_ = srv.RegisterSignalHook(endless.PRE_SIGNAL, syscall.SIGTERM, func() {
+		atomic.StoreInt32(&s.ready, 0)
+		s.runHooks(StageDeregister, StageDeregister)
+		atomic.StoreInt32(&deregistered, 1)
+	})
+
+	// endless自行监听，Serve开始accept之前按实际监听的地址注册，失败时关闭监听使Serve返回
+	var registerErr error
+	srv.BaseContext = func(l net.Listener) context.Context {
+		s.addr.Store(l.Addr())
+		if registerErr = s.startRegister(l.Addr()); registerErr != nil {
+			l.Close()
+		}
+		return context.Background()
+	}
+
+	atomic.StoreInt32(&s.ready, 1)
+	if useTLS {
+		err = srv.ListenAndServeTLS(s.conf.CertFile, s.conf.KeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	atomic.StoreInt32(&s.ready, 0)
+	if registerErr != nil {
+		err = registerErr
+	}
+
+	if atomic.LoadInt32(&deregistered) == 1 {
+		s.runHooks(StageTrace, StageLog)
+	} else {
+		s.runHooks(StageDeregister, StageLog)
+	}
 	return
 }
 
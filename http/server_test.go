@@ -23,7 +23,8 @@ import (
 
 type HttpTestSuite struct {
 	suite.Suite
-	addr string
+	addr  string
+	runCh chan error
 }
 
 func (suite *HttpTestSuite) SetupSuite() {
@@ -65,7 +66,10 @@ func (suite *HttpTestSuite) SetupSuite() {
 		c.String(200, "%s", "done")
 	})
 
-	go srv.Run()
+	suite.runCh = make(chan error, 1)
+	go func() {
+		suite.runCh <- srv.Run()
+	}()
 	// 等待服务启动完成
 	time.Sleep(time.Second)
 }
@@ -116,6 +120,9 @@ func (suite *HttpTestSuite) TestMetrics() {
 	bytes, err := ioutil.ReadAll(resp.Body)
 	require.NoError(suite.T(), err)
 	suite.T().Logf("metrics response:\n%s", string(bytes))
+	// 使用路由模板作为标签
+	require.Contains(suite.T(), string(bytes), `testMetrics_requests_total{code="500",path="/metrics/*action"} 1`)
+	require.Contains(suite.T(), string(bytes), `testMetrics_requests_total{code="200",path="/metrics/*action"} 3`)
 }
 
 // test ratelimit middleware
@@ -162,6 +169,8 @@ func (suite *HttpTestSuite) TestRatelimit() {
 func (suite *HttpTestSuite) TearDownSuite() {
 	err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
 	require.NoError(suite.T(), err)
+	// 等待服务平滑退出
+	require.NoError(suite.T(), <-suite.runCh)
 }
 
 func TestHttp(t *testing.T) {
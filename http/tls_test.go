@@ -0,0 +1,150 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadServerConf(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+
+	svc, err := LoadServerConf()
+	require.NoError(err)
+	require.Equal("127.0.0.1:8080", svc.Addr)
+	require.Equal(conf.Duration(2*time.Second), svc.ReadHeaderTimeout)
+	require.Equal(conf.ByteSize(10<<20), svc.MaxBodySize)
+	require.Equal([]string{"/healthz", "/readyz", "/metrics"}, svc.AccessLog.SkipPaths)
+}
+
+func TestUnixListener(t *testing.T) {
+	require := require.New(t)
+
+	sock := filepath.Join(os.TempDir(), "motor_test.sock")
+	srv := DefaultServer(&ServerConf{Network: NetworkUnix, Addr: sock, MaxBodySize: 4})
+	srv.POST("/echo", func(c *gin.Context) {
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	go srv.Run()
+	defer srv.Shutdown()
+	time.Sleep(200 * time.Millisecond)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", sock)
+		},
+	}}
+	resp, err := client.Post("http://unix/echo", "text/plain", strings.NewReader("ok"))
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusOK, resp.StatusCode)
+
+	resp, err = client.Post("http://unix/echo", "text/plain", strings.NewReader("too large"))
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestMutualTLS(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "motor_tls")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	ca, caKey := genCert(t, nil, nil, "ca", dir)
+	genCert(t, ca, caKey, "server", dir)
+	genCert(t, ca, caKey, "client", dir)
+
+	addr := "127.0.0.1:18084"
+	srv := DefaultServer(&ServerConf{
+		Addr:          addr,
+		CertFile:      filepath.Join(dir, "server.pem"),
+		KeyFile:       filepath.Join(dir, "server-key.pem"),
+		ClientCAFile:  filepath.Join(dir, "ca.pem"),
+		MinTLSVersion: "1.2",
+	})
+	srv.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	go srv.RunTLS()
+	defer srv.Shutdown()
+	time.Sleep(200 * time.Millisecond)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem"))
+	require.NoError(err)
+
+	// 未提供客户端证书
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	_, err = client.Get("https://" + addr + "/ping")
+	require.Error(err)
+
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+	resp, err := client.Get("https://" + addr + "/ping")
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusOK, resp.StatusCode)
+}
+
+// 生成证书，parent为nil时生成自签名的ca
+func genCert(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, name, dir string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".pem"),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return cert, key
+}
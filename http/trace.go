@@ -1,13 +1,12 @@
 package http
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/metadata"
 	"github.com/kaimixu/motor/trace"
-	"github.com/micro/go-micro/metadata"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	"go.uber.org/zap"
@@ -26,11 +25,11 @@ func Trace() gin.HandlerFunc {
 			}
 			defer span.Finish()
 
-			carrier := opentracing.TextMapCarrier{}
-			// 将请求的Baggage数据inject到carrier中
-			err = tracer.Inject(span.Context(), opentracing.TextMap, carrier)
+			md := metadata.Metadata{}
+			// 将请求的trace上下文及Baggage数据inject到metadata中
+			err = tracer.Inject(span.Context(), opentracing.TextMap, md)
 			if err != nil {
-				zap.L().Error("tracer.Inject failed",
+				FromGin(c).Error("tracer.Inject failed",
 					zap.String("clientIp", c.ClientIP()),
 					zap.String("url", c.Request.URL.Path),
 					zap.String("method", c.Request.Method),
@@ -38,10 +37,12 @@ func Trace() gin.HandlerFunc {
 				)
 			}
 
-			ctx := opentracing.ContextWithSpan(context.Background(), span)
-			ctx = metadata.NewContext(ctx, metadata.Metadata(carrier))
-			// 存放到gin框架的context中，方便后面的中间件及路由handler获取
+			// 基于请求的ctx，以便携带RequestID等中间件设置的值
+			ctx := opentracing.ContextWithSpan(c.Request.Context(), span)
+			ctx = metadata.NewContext(ctx, md)
+			// 存放到gin框架的context及c.Request中，方便后面的中间件及路由handler获取
 			c.Set(trace.Tracer_Ctx_Key, ctx)
+			c.Request = c.Request.WithContext(ctx)
 
 			c.Next()
 
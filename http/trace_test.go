@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/metadata"
+	"github.com/kaimixu/motor/trace"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+)
+
+type ctxTestKey struct{}
+
+func TestTrace(t *testing.T) {
+	require := require.New(t)
+
+	defer opentracing.SetGlobalTracer(opentracing.GlobalTracer())
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), ctxTestKey{}, "v"))
+	engine := gin.New()
+	engine.Use(RequestID(), Trace())
+	engine.GET("/users/:id", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		traceCtx, _ := c.Get(trace.Tracer_Ctx_Key)
+		require.Equal(ctx, traceCtx)
+
+		// 保留请求ctx中的值及取消信号
+		require.Equal("v", ctx.Value(ctxTestKey{}))
+		_, ok := log.RequestIDFrom(ctx)
+		require.True(ok)
+		require.NotNil(opentracing.SpanFromContext(ctx))
+		cancel()
+		require.Equal(context.Canceled, ctx.Err())
+
+		md, ok := metadata.FromContext(ctx)
+		require.True(ok)
+		sc := opentracing.SpanFromContext(ctx).Context().(mocktracer.MockSpanContext)
+		traceID, ok := md.Get("mockpfx-ids-traceid")
+		require.True(ok)
+		require.Equal("43", traceID)
+		require.Equal(43, sc.TraceID)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil).WithContext(parent)
+	req.Header.Set("mockpfx-ids-traceid", "43")
+	req.Header.Set("mockpfx-ids-spanid", "7")
+	req.Header.Set("mockpfx-ids-sampled", "true")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	require.Equal(http.StatusOK, w.Code)
+
+	spans := tracer.FinishedSpans()
+	require.Len(spans, 1)
+	require.Equal(7, spans[0].ParentID)
+	require.Equal(uint16(http.StatusOK), spans[0].Tag("http.status_code"))
+}
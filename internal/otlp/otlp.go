@@ -0,0 +1,43 @@
+// OTLP/HTTP JSON编码的公共结构，供trace及metrics的exporter使用
+package otlp
+
+import "sort"
+
+type Resource struct {
+	Attributes []Attr `json:"attributes"`
+}
+
+// 按key排序生成resource属性
+func NewResource(attrs map[string]string) Resource {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	r := Resource{Attributes: make([]Attr, 0, len(keys))}
+	for _, k := range keys {
+		r.Attributes = append(r.Attributes, StringAttr(k, attrs[k]))
+	}
+	return r
+}
+
+type Scope struct {
+	Name string `json:"name"`
+}
+
+type Attr struct {
+	Key   string `json:"key"`
+	Value Value  `json:"value"`
+}
+
+// 仅设置其中一项，OTLP JSON编码中的64位整数使用字符串表示
+type Value struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func StringAttr(key, value string) Attr {
+	return Attr{Key: key, Value: Value{StringValue: &value}}
+}
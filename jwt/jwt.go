@@ -3,21 +3,66 @@ package jwt
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
+const (
+	// http.Jwt中间件在gin.Context中存放claims的key
+	ClaimsKey = "jwtClaims"
+)
+
+var (
+	ErrTokenMissing = errors.New("请求未携带jwt-token")
+	ErrTokenInvalid = errors.New("无效的token")
+)
+
 type JWT struct {
 	secret string
+
+	// 非空时要求token的iss/aud与之匹配
+	issuer   string
+	audience string
+	// 校验exp、nbf、iat时允许的时钟偏差
+	leeway time.Duration
 }
 
+type Option func(*JWT)
+
 type MotorClaims struct {
 	jwt.StandardClaims
 	Data map[string]interface{}
 }
 
-func NewJWT(secret string) *JWT {
-	return &JWT{secret}
+// 要求token携带指定的iss
+func WithIssuer(issuer string) Option {
+	return func(j *JWT) {
+		j.issuer = issuer
+	}
+}
+
+// 要求token携带指定的aud
+func WithAudience(audience string) Option {
+	return func(j *JWT) {
+		j.audience = audience
+	}
+}
+
+// 设置时钟偏差容忍度
+func WithLeeway(leeway time.Duration) Option {
+	return func(j *JWT) {
+		j.leeway = leeway
+	}
+}
+
+func NewJWT(secret string, opts ...Option) *JWT {
+	j := &JWT{secret: secret}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	return j
 }
 
 // 生成token
@@ -28,7 +73,9 @@ func (j *JWT) GenToken(claims *MotorClaims) (string, error) {
 
 // 验证token
 func (j *JWT) ParseToken(tokenStr string) (*MotorClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &MotorClaims{}, func(token *jwt.Token) (interface{}, error) {
+	// 时间相关的claims由validate统一校验，以便支持leeway
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(tokenStr, &MotorClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 		}
@@ -39,15 +86,54 @@ func (j *JWT) ParseToken(tokenStr string) (*MotorClaims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*MotorClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*MotorClaims)
+	if !ok || !token.Valid {
+		return nil, ErrTokenInvalid
+	}
+	if err := j.validate(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// 校验exp、nbf、iat、iss、aud，遇到第一个失败项即返回
+func (j *JWT) validate(claims *MotorClaims) error {
+	now := jwt.TimeFunc()
+	leeway := int64(j.leeway / time.Second)
+
+	if !claims.VerifyExpiresAt(now.Unix()-leeway, false) {
+		return validationError(fmt.Errorf("token is expired by %v", now.Sub(time.Unix(claims.ExpiresAt, 0))),
+			jwt.ValidationErrorExpired)
+	}
+	if !claims.VerifyIssuedAt(now.Unix()+leeway, false) {
+		return validationError(errors.New("token used before issued"), jwt.ValidationErrorIssuedAt)
+	}
+	if !claims.VerifyNotBefore(now.Unix()+leeway, false) {
+		return validationError(errors.New("token is not valid yet"), jwt.ValidationErrorNotValidYet)
+	}
+	if j.issuer != "" && !claims.VerifyIssuer(j.issuer, true) {
+		return validationError(fmt.Errorf("token issuer mismatch, iss:%s", claims.Issuer), jwt.ValidationErrorIssuer)
+	}
+	if j.audience != "" && !claims.VerifyAudience(j.audience, true) {
+		return validationError(fmt.Errorf("token audience mismatch, aud:%s", claims.Audience),
+			jwt.ValidationErrorAudience)
 	}
 
-	return nil, errors.New("无效的token")
+	return nil
+}
+
+func validationError(inner error, flag uint32) error {
+	return &jwt.ValidationError{Inner: inner, Errors: flag}
 }
 
 // 判断token是否过期
 func (j *JWT) IsExpires(err error) bool {
+	return IsExpired(err)
+}
+
+// 判断err是否为token过期错误
+func IsExpired(err error) bool {
 	if ve, ok := err.(*jwt.ValidationError); ok {
 		if ve.Errors&jwt.ValidationErrorExpired != 0 {
 			return true
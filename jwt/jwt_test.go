@@ -0,0 +1,75 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToken(t *testing.T) {
+	require := require.New(t)
+	now := time.Now()
+
+	j := NewJWT("secret", WithIssuer("motor"), WithAudience("api"))
+	token, err := j.GenToken(&MotorClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "motor",
+			Audience:  "api",
+			Subject:   "user1",
+			ExpiresAt: now.Add(time.Minute).Unix(),
+		},
+		Data: map[string]interface{}{"role": "admin"},
+	})
+	require.NoError(err)
+
+	claims, err := j.ParseToken(token)
+	require.NoError(err)
+	require.Equal("user1", claims.Subject)
+	require.Equal("admin", claims.Data["role"])
+
+	// issuer不匹配
+	_, err = NewJWT("secret", WithIssuer("other")).ParseToken(token)
+	require.Error(err)
+
+	// audience不匹配
+	_, err = NewJWT("secret", WithAudience("other")).ParseToken(token)
+	require.Error(err)
+
+	// 签名错误
+	_, err = NewJWT("wrong").ParseToken(token)
+	require.Error(err)
+
+	// 多项校验失败时返回第一个失败项
+	token, err = j.GenToken(&MotorClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "other",
+			ExpiresAt: now.Add(-time.Minute).Unix(),
+		},
+	})
+	require.NoError(err)
+	_, err = j.ParseToken(token)
+	require.True(IsExpired(err))
+	require.Contains(err.Error(), "token is expired")
+	require.Equal(jwt.ValidationErrorExpired, err.(*jwt.ValidationError).Errors)
+}
+
+func TestLeeway(t *testing.T) {
+	require := require.New(t)
+
+	j := NewJWT("secret")
+	token, err := j.GenToken(&MotorClaims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(-5 * time.Second).Unix(),
+		},
+	})
+	require.NoError(err)
+
+	_, err = j.ParseToken(token)
+	require.Error(err)
+	require.True(j.IsExpires(err))
+
+	_, err = NewJWT("secret", WithLeeway(time.Minute)).ParseToken(token)
+	require.NoError(err)
+}
@@ -0,0 +1,134 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// gin.Context中存放request id的key
+	RequestIDKey = "requestID"
+	// 传递request id的header
+	RequestIDHeader = "X-Request-ID"
+	// 超过该长度的request id视为无效，重新生成
+	maxRequestIDLen = 128
+)
+
+type ctxKey int
+
+// 从span中获取trace_id及span_id，由trace.Init注册，避免log依赖具体的tracer实现
+type SpanIDer interface {
+	GetTraceID(opentracing.Span) (string, bool)
+	GetSpanID(opentracing.Span) (string, bool)
+}
+
+type spanIDerHolder struct {
+	SpanIDer
+}
+
+var _spanIDer atomic.Value
+
+const (
+	requestIDCtxKey ctxKey = iota
+	subjectCtxKey
+)
+
+// 生成随机的request id
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// 仅接受可见ascii字符，避免日志注入
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// 设置获取trace_id及span_id的实现，为nil时日志中不输出
+func SetSpanIDer(s SpanIDer) {
+	_spanIDer.Store(spanIDerHolder{s})
+}
+
+// 获取ctx中span的trace_id及span_id，未设置SpanIDer时返回false
+func spanIDs(ctx context.Context) (traceID, spanID string, ok bool) {
+	h, _ := _spanIDer.Load().(spanIDerHolder)
+	if h.SpanIDer == nil {
+		return "", "", false
+	}
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return "", "", false
+	}
+	if traceID, ok = h.GetTraceID(span); !ok {
+		return "", "", false
+	}
+	spanID, _ = h.GetSpanID(span)
+
+	return traceID, spanID, true
+}
+
+// 将request id存入ctx
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey, id)
+}
+
+// 获取ctx中的request id
+func RequestIDFrom(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	id, ok := ctx.Value(requestIDCtxKey).(string)
+	return id, ok && id != ""
+}
+
+// 将请求的用户标识(如jwt的sub)存入ctx
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectCtxKey, subject)
+}
+
+// ctx中携带的trace_id、span_id、request_id及subject
+func Fields(ctx context.Context) []zap.Field {
+	if ctx == nil {
+		return nil
+	}
+
+	var fields []zap.Field
+	if traceID, spanID, ok := spanIDs(ctx); ok {
+		fields = append(fields, zap.String("trace_id", traceID), zap.String("span_id", spanID))
+	}
+	if id, ok := RequestIDFrom(ctx); ok {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	if sub, ok := ctx.Value(subjectCtxKey).(string); ok && sub != "" {
+		fields = append(fields, zap.String("subject", sub))
+	}
+	return fields
+}
+
+// 获取携带ctx中trace_id、request_id等字段的logger
+func Ctx(ctx context.Context) *zap.Logger {
+	return with(zap.L(), Fields(ctx))
+}
+
+func with(logger *zap.Logger, fields []zap.Field) *zap.Logger {
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.With(fields...)
+}
@@ -0,0 +1,222 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// 配置为该值时不输出对应的key
+	omitKey = "-"
+)
+
+// 日志编码配置，未配置的项使用默认值
+type EncoderConf struct {
+	TimeKey       string `toml:"timeKey"`
+	LevelKey      string `toml:"levelKey"`
+	NameKey       string `toml:"nameKey"`
+	CallerKey     string `toml:"callerKey"`
+	MessageKey    string `toml:"messageKey"`
+	StacktraceKey string `toml:"stacktraceKey"`
+	// 时间格式：iso8601(默认)、rfc3339、rfc3339nano、epoch(秒)、millis(毫秒整数)、nanos，其他值按time layout处理
+	TimeFormat string `toml:"timeFormat"`
+	// 级别格式：lowercase(默认)、capital、color、capitalColor
+	LevelFormat string `toml:"levelFormat"`
+	// 耗时格式：seconds(默认)、ms、nanos、string
+	DurationFormat string `toml:"durationFormat"`
+	// 调用位置格式：short(默认)、full
+	CallerFormat string `toml:"callerFormat"`
+}
+
+// 日志采样，每个tick(默认1s)内相同级别及内容的日志输出前initial条，之后每thereafter条输出1条，默认均为100
+type SamplingConf struct {
+	// 关闭采样
+	Disable    bool          `toml:"disable"`
+	Tick       conf.Duration `toml:"tick"`
+	Initial    int           `toml:"initial"`
+	Thereafter int           `toml:"thereafter"`
+}
+
+// 命名的日志输出，如将error级别日志单独输出到带完整堆栈的文件
+type OutputConf struct {
+	Name string `toml:"name"`
+	// 输出的最低级别，仍受全局及模块级别限制
+	Level string   `toml:"level"`
+	Paths []string `toml:"paths"`
+	// 仅输出指定模块(log.Named)的日志，这些模块的日志不再输出到outputPaths
+	Modules []string `toml:"modules"`
+	// 以下未配置时使用[Log]中的配置
+	Encoding string        `toml:"encoding"`
+	Encoder  *EncoderConf  `toml:"encoder"`
+	Sampling *SamplingConf `toml:"sampling"`
+	Rotate   *RotateConf   `toml:"rotate"`
+}
+
+func newEncoder(encoding string, cfg *EncoderConf) (zapcore.Encoder, error) {
+	if cfg == nil {
+		cfg = &EncoderConf{}
+	}
+	encoderCfg := zapcore.EncoderConfig{
+		TimeKey:        encoderKey(cfg.TimeKey, "time"),
+		LevelKey:       encoderKey(cfg.LevelKey, "level"),
+		NameKey:        encoderKey(cfg.NameKey, "logger"),
+		CallerKey:      encoderKey(cfg.CallerKey, "caller"),
+		FunctionKey:    zapcore.OmitKey,
+		MessageKey:     encoderKey(cfg.MessageKey, "msg"),
+		StacktraceKey:  encoderKey(cfg.StacktraceKey, "stacktrace"),
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     timeEncoder(cfg.TimeFormat),
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	// 以下均为未知值时使用默认值
+	encoderCfg.EncodeLevel.UnmarshalText([]byte(cfg.LevelFormat))
+	encoderCfg.EncodeDuration.UnmarshalText([]byte(cfg.DurationFormat))
+	encoderCfg.EncodeCaller.UnmarshalText([]byte(cfg.CallerFormat))
+
+	switch encoding {
+	case "json", "":
+		return zapcore.NewJSONEncoder(encoderCfg), nil
+	case "console":
+		return zapcore.NewConsoleEncoder(encoderCfg), nil
+	default:
+		return nil, fmt.Errorf("invalid log encoding, encoding:%s", encoding)
+	}
+}
+
+func encoderKey(key, def string) string {
+	switch key {
+	case "":
+		return def
+	case omitKey:
+		return zapcore.OmitKey
+	default:
+		return key
+	}
+}
+
+func timeEncoder(format string) zapcore.TimeEncoder {
+	switch strings.ToLower(format) {
+	case "", "iso8601":
+		return zapcore.ISO8601TimeEncoder
+	case "rfc3339":
+		return zapcore.RFC3339TimeEncoder
+	case "rfc3339nano":
+		return zapcore.RFC3339NanoTimeEncoder
+	case "epoch":
+		return zapcore.EpochTimeEncoder
+	case "millis":
+		return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendInt64(t.UnixNano() / int64(time.Millisecond))
+		}
+	case "nanos":
+		return zapcore.EpochNanosTimeEncoder
+	default:
+		return zapcore.TimeEncoderOfLayout(format)
+	}
+}
+
+func newSampler(core zapcore.Core, cfg *SamplingConf) zapcore.Core {
+	if cfg == nil {
+		return zapcore.NewSamplerWithOptions(core, time.Second, 100, 100)
+	}
+	if cfg.Disable {
+		return core
+	}
+
+	tick, initial, thereafter := time.Duration(cfg.Tick), cfg.Initial, cfg.Thereafter
+	if tick <= 0 {
+		tick = time.Second
+	}
+	if initial <= 0 {
+		initial = 100
+	}
+	if thereafter <= 0 {
+		thereafter = 100
+	}
+	return zapcore.NewSamplerWithOptions(core, tick, initial, thereafter)
+}
+
+// 创建一个输出的core，级别由全局及模块logger过滤，此处仅按输出自身的级别过滤
+func newOutputCore(cfg *LogConf, out *OutputConf) (zapcore.Core, []io.Closer, error) {
+	level := zap.DebugLevel
+	if out.Level != "" {
+		l, err := parseLevel(out.Level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid log level, output:%s, level:%s", out.Name, out.Level)
+		}
+		level = l
+	}
+
+	encoding, encoderCfg := out.Encoding, out.Encoder
+	if encoding == "" {
+		encoding = cfg.Encoding
+	}
+	if encoderCfg == nil {
+		encoderCfg = cfg.Encoder
+	}
+	encoder, err := newEncoder(encoding, encoderCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rotate := out.Rotate
+	if rotate == nil {
+		rotate = cfg.Rotate
+	}
+	ws, closers, err := openWriters(out.Paths, rotate)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Async != nil {
+		async, err := newAsyncWriter(ws, cfg.Async)
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, err
+		}
+		ws = async
+		// 先于文件关闭，以便写完队列中的日志
+		closers = append([]io.Closer{async}, closers...)
+	}
+
+	sampling := out.Sampling
+	if sampling == nil {
+		sampling = cfg.Sampling
+	}
+	return newSampler(zapcore.NewCore(encoder, ws, level), sampling), closers, nil
+}
+
+// 按logger名称过滤日志，exclude为true时过滤掉指定模块，否则仅保留指定模块
+type moduleCore struct {
+	zapcore.Core
+	modules []string
+	exclude bool
+}
+
+func (c *moduleCore) With(fields []zapcore.Field) zapcore.Core {
+	return &moduleCore{Core: c.Core.With(fields), modules: c.modules, exclude: c.exclude}
+}
+
+func (c *moduleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if matchModule(ent.LoggerName, c.modules) == c.exclude {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// name为模块名或其子logger(如mysql.slow)时匹配
+func matchModule(name string, modules []string) bool {
+	for _, m := range modules {
+		if name == m || strings.HasPrefix(name, m+".") {
+			return true
+		}
+	}
+	return false
+}
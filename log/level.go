@@ -0,0 +1,252 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	// 全局日志级别，支持运行时修改
+	_level = zap.NewAtomicLevel()
+
+	_mutex   sync.RWMutex
+	_modules = make(map[string]*moduleLevel)
+	_loggers = make(map[string]*zap.Logger)
+)
+
+// 模块日志级别，未单独设置时跟随全局级别
+type moduleLevel struct {
+	mutex sync.RWMutex
+	set   bool
+	level zapcore.Level
+}
+
+func (m *moduleLevel) Enabled(l zapcore.Level) bool {
+	m.mutex.RLock()
+	set, level := m.set, m.level
+	m.mutex.RUnlock()
+
+	if !set {
+		return _level.Enabled(l)
+	}
+	return level.Enabled(l)
+}
+
+func (m *moduleLevel) String() string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if !m.set {
+		return ""
+	}
+	return m.level.String()
+}
+
+func (m *moduleLevel) setLevel(level zapcore.Level, set bool) {
+	m.mutex.Lock()
+	m.set, m.level = set, level
+	m.mutex.Unlock()
+}
+
+// 在原core的基础上按enab过滤日志级别
+type levelCore struct {
+	zapcore.Core
+	enab zapcore.LevelEnabler
+}
+
+func withLevel(enab zapcore.LevelEnabler) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, enab: enab}
+	})
+}
+
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	return c.enab.Enabled(l)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), enab: c.enab}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enab.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// 获取全局日志级别
+func Level() zap.AtomicLevel {
+	return _level
+}
+
+// 获取指定模块的logger，其级别可通过SetModuleLevel单独调整
+// 未调用Init时基于zap.L()创建，不支持单独调整级别
+func Named(name string) *zap.Logger {
+	if !Initialized {
+		return zap.L().Named(name)
+	}
+
+	_mutex.RLock()
+	logger, ok := _loggers[name]
+	_mutex.RUnlock()
+	if ok {
+		return logger
+	}
+
+	m := module(name)
+	_mutex.Lock()
+	defer _mutex.Unlock()
+	if logger, ok = _loggers[name]; ok {
+		return logger
+	}
+	logger = _base.Named(name).WithOptions(withLevel(m))
+	_loggers[name] = logger
+
+	return logger
+}
+
+// 设置模块日志级别，level为空时恢复为跟随全局级别
+func SetModuleLevel(name, level string) error {
+	if level == "" {
+		module(name).setLevel(zap.InfoLevel, false)
+		return nil
+	}
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	module(name).setLevel(l, true)
+	return nil
+}
+
+// 各模块单独设置的日志级别
+func ModuleLevels() map[string]string {
+	_mutex.RLock()
+	defer _mutex.RUnlock()
+
+	levels := make(map[string]string, len(_modules))
+	for name, m := range _modules {
+		if level := m.String(); level != "" {
+			levels[name] = level
+		}
+	}
+	return levels
+}
+
+func module(name string) *moduleLevel {
+	_mutex.RLock()
+	m, ok := _modules[name]
+	_mutex.RUnlock()
+	if ok {
+		return m
+	}
+
+	_mutex.Lock()
+	defer _mutex.Unlock()
+	if m, ok = _modules[name]; !ok {
+		m = &moduleLevel{}
+		_modules[name] = m
+	}
+	return m
+}
+
+func setBase(base *zap.Logger) {
+	_mutex.Lock()
+	_base = base
+	// 模块logger需基于新的输出重建
+	_loggers = make(map[string]*zap.Logger)
+	_mutex.Unlock()
+}
+
+// 按配置设置全局及各模块级别，未配置的模块恢复为跟随全局级别
+func applyLevels(cfg *LogConf) error {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	modules := make(map[string]zapcore.Level, len(cfg.Modules))
+	for name, lv := range cfg.Modules {
+		l, err := parseLevel(lv)
+		if err != nil {
+			return fmt.Errorf("invalid log level, module:%s, level:%s", name, lv)
+		}
+		modules[name] = l
+	}
+
+	_level.SetLevel(level)
+	_mutex.RLock()
+	for name, m := range _modules {
+		if _, ok := modules[name]; !ok {
+			m.setLevel(zap.InfoLevel, false)
+		}
+	}
+	_mutex.RUnlock()
+	for name, l := range modules {
+		module(name).setLevel(l, true)
+	}
+
+	return nil
+}
+
+type levelPayload struct {
+	Module string `json:"module,omitempty"`
+	Level  string `json:"level"`
+}
+
+type levelsPayload struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules"`
+}
+
+// 查询(GET)及修改(PUT)日志级别的http接口
+// PUT {"level":"debug"}修改全局级别，{"module":"naming","level":"debug"}修改模块级别，
+// 模块level为空时恢复为跟随全局级别
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req levelPayload
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				enc.Encode(map[string]string{"error": fmt.Sprintf("request body must be valid json, err:%v", err)})
+				return
+			}
+
+			var err error
+			if req.Module == "" {
+				var l zapcore.Level
+				if l, err = parseLevel(req.Level); err == nil {
+					_level.SetLevel(l)
+				}
+			} else {
+				err = SetModuleLevel(req.Module, req.Level)
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				enc.Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			zap.L().Info("log level changed", zap.String("module", req.Module), zap.String("level", req.Level))
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			enc.Encode(map[string]string{"error": "only GET and PUT are supported"})
+			return
+		}
+
+		enc.Encode(levelsPayload{
+			Level:   _level.String(),
+			Modules: ModuleLevels(),
+		})
+	})
+}
@@ -2,94 +2,210 @@ package log
 
 import (
 	"fmt"
+	"io"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/kaimixu/motor/conf"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-var Initialized = false
+var (
+	Initialized = false
+
+	// 未做级别过滤的logger，全局及各模块logger在此基础上按各自级别过滤
+	_base      = zap.NewNop()
+	_watchOnce sync.Once
+	// 当前打开的日志输出，重新初始化时关闭
+	_closers []io.Closer
+)
 
 type LogConf struct {
-	Level          string                 `toml:level`
-	Encoding       string                 `toml:encoding`
-	OutputPaths    []string               `toml:outputPaths`
-	ErrOutputPaths []string               `toml:errOutputPaths`
-	InitialFields  map[string]interface{} `toml:initialFields`
+	Level          string                 `toml:"level"`
+	Encoding       string                 `toml:"encoding"`
+	OutputPaths    []string               `toml:"outputPaths"`
+	ErrOutputPaths []string               `toml:"errOutputPaths"`
+	InitialFields  map[string]interface{} `toml:"initialFields"`
+	// 各模块的日志级别，未配置的模块使用全局级别
+	Modules map[string]string `toml:"modules"`
+	// 日志文件切割，未配置时不切割
+	Rotate *RotateConf `toml:"rotate"`
+	// 异步写日志，未配置时同步写
+	Async *AsyncConf `toml:"async"`
+
+	// 开发模式，DPanic级别日志会panic，默认warn及以上级别输出堆栈
+	Development bool `toml:"development"`
+	// 不输出调用位置
+	DisableCaller bool `toml:"disableCaller"`
+	// 输出堆栈的最低级别，默认error
+	StacktraceLevel string `toml:"stacktraceLevel"`
+	// 编码配置
+	Encoder *EncoderConf `toml:"encoder"`
+	// 采样配置，未配置时每秒相同日志前100条全部输出，之后每100条输出1条
+	Sampling *SamplingConf `toml:"sampling"`
+	// 除outputPaths外的其他命名输出
+	Outputs []*OutputConf `toml:"outputs"`
 }
 
 // create zap log object
 func Init() {
-	cfg := getConf()
-
-	encoderCfg := zapcore.EncoderConfig{
-		TimeKey:        "time",
-		LevelKey:       "level",
-		NameKey:        "logger",
-		CallerKey:      "caller",
-		FunctionKey:    zapcore.OmitKey,
-		MessageKey:     "msg",
-		StacktraceKey:  "stacktrace",
-		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    zapcore.LowercaseLevelEncoder,
-		EncodeTime:     zapcore.ISO8601TimeEncoder,
-		EncodeDuration: zapcore.SecondsDurationEncoder,
-		EncodeCaller:   zapcore.ShortCallerEncoder,
-	}
-	zapCfg := zap.Config{
-		Level:       zap.NewAtomicLevelAt(getLevel(cfg.Level)),
-		Development: false,
-		Sampling: &zap.SamplingConfig{
-			Initial:    100,
-			Thereafter: 100,
-		},
-		Encoding:         cfg.Encoding,
-		EncoderConfig:    encoderCfg,
-		OutputPaths:      cfg.OutputPaths,
-		ErrorOutputPaths: cfg.ErrOutputPaths,
-		InitialFields:    cfg.InitialFields,
-	}
-
-	logger, err := zapCfg.Build(zap.AddCaller())
+	cfg, err := getConf()
 	if err != nil {
 		panic(err)
 	}
+	if err := build(cfg); err != nil {
+		panic(err)
+	}
+
+	// 配置变更时仅更新日志级别，输出等配置需重启生效
+	_watchOnce.Do(func() {
+		go func() {
+			for range conf.WatchEvent("application.toml") {
+				cfg, err := getConf()
+				if err != nil {
+					zap.L().Error("log reload failed", zap.Error(err))
+					continue
+				}
+				if err := applyLevels(cfg); err != nil {
+					zap.L().Error("log reload failed", zap.Error(err))
+				}
+			}
+		}()
+	})
+}
+
+func build(cfg *LogConf) error {
+	if err := applyLevels(cfg); err != nil {
+		return err
+	}
+
+	stackLevel := zap.ErrorLevel
+	if cfg.Development {
+		stackLevel = zap.WarnLevel
+	}
+	if cfg.StacktraceLevel != "" {
+		l, err := parseLevel(cfg.StacktraceLevel)
+		if err != nil {
+			return err
+		}
+		stackLevel = l
+	}
 
-	zap.ReplaceGlobals(logger)
+	outputs := append([]*OutputConf{{
+		Name:  "default",
+		Paths: cfg.OutputPaths,
+	}}, cfg.Outputs...)
+	var (
+		cores   []zapcore.Core
+		closers []io.Closer
+	)
+	var routed []string
+	for _, out := range cfg.Outputs {
+		routed = append(routed, out.Modules...)
+	}
+	for i, out := range outputs {
+		core, cs, err := newOutputCore(cfg, out)
+		if err != nil {
+			closeAll(closers)
+			return err
+		}
+		switch {
+		case i == 0 && len(routed) > 0:
+			core = &moduleCore{Core: core, modules: routed, exclude: true}
+		case len(out.Modules) > 0:
+			core = &moduleCore{Core: core, modules: out.Modules}
+		}
+		cores = append(cores, core)
+		closers = append(closers, cs...)
+	}
+
+	errOut, errClosers, err := openWriters(cfg.ErrOutputPaths, cfg.Rotate)
+	closers = append(closers, errClosers...)
+	if err != nil {
+		closeAll(closers)
+		return err
+	}
+
+	opts := []zap.Option{
+		zap.ErrorOutput(errOut),
+		zap.AddStacktrace(stackLevel),
+	}
+	if !cfg.DisableCaller {
+		opts = append(opts, zap.AddCaller())
+	}
+	if cfg.Development {
+		opts = append(opts, zap.Development())
+	}
+	if len(cfg.InitialFields) > 0 {
+		keys := make([]string, 0, len(cfg.InitialFields))
+		for k := range cfg.InitialFields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]zap.Field, 0, len(keys))
+		for _, k := range keys {
+			fields = append(fields, zap.Any(k, cfg.InitialFields[k]))
+		}
+		opts = append(opts, zap.Fields(fields...))
+	}
+	base := zap.New(zapcore.NewTee(cores...), opts...)
+
+	old := zap.L()
+	setBase(base)
+	zap.ReplaceGlobals(base.WithOptions(withLevel(_level)))
 	Initialized = true
+
+	// 关闭之前的输出
+	old.Sync()
+	closeAll(_closers)
+	_closers = closers
+	return nil
 }
 
-func getConf() *LogConf {
+func closeAll(closers []io.Closer) {
+	for _, c := range closers {
+		c.Close()
+	}
+}
+
+// 刷新缓冲中的日志，退出前调用
+func Sync() error {
+	return zap.L().Sync()
+}
+
+func getConf() (*LogConf, error) {
 	var st conf.Storage
 	var cfg LogConf
 	if err := conf.Get("application.toml").Unmarshal(&st); err != nil {
-		panic(err)
+		return nil, errors.Wrap(err, "Get(application.toml).Unmarshal failed")
 	}
 	if err := st.Get("Log").UnmarshalTOML(&cfg); err != nil {
-		panic(err)
+		return nil, errors.Wrap(err, "Get(Log).UnmarshalTOML failed")
 	}
 
-	return &cfg
+	return &cfg, nil
 }
 
-func getLevel(level string) zapcore.Level {
+func parseLevel(level string) (zapcore.Level, error) {
 	switch strings.ToLower(level) {
 	case "debug":
-		return zap.DebugLevel
+		return zap.DebugLevel, nil
 	case "info", "": // make the zero value useful
-		return zap.InfoLevel
+		return zap.InfoLevel, nil
 	case "warn":
-		return zap.WarnLevel
+		return zap.WarnLevel, nil
 	case "error":
-		return zap.ErrorLevel
+		return zap.ErrorLevel, nil
 	case "dpanic":
-		return zap.DPanicLevel
+		return zap.DPanicLevel, nil
 	case "panic":
-		return zap.PanicLevel
+		return zap.PanicLevel, nil
 	case "fatal":
-		return zap.FatalLevel
+		return zap.FatalLevel, nil
 	default:
-		panic(fmt.Sprintf("invalid log level, level:%s", level))
+		return zap.InfoLevel, fmt.Errorf("invalid log level, level:%s", level)
 	}
 }
@@ -0,0 +1,236 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNamedBeforeInit(t *testing.T) {
+	require := require.New(t)
+
+	defer func(initialized bool) {
+		Initialized = initialized
+	}(Initialized)
+	Initialized = false
+	core, logs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	// 未初始化时使用zap.L()，且不缓存，避免Init之后仍使用未初始化时的logger
+	Named("early").Info("before init")
+	require.Equal(1, logs.Len())
+	require.Equal("early", logs.All()[0].LoggerName)
+	_mutex.RLock()
+	_, ok := _loggers["early"]
+	_mutex.RUnlock()
+	require.False(ok)
+}
+
+func TestModuleLevel(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "motor-log")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "app.log")
+
+	require.NoError(build(&LogConf{
+		Level:       "info",
+		Encoding:    "json",
+		OutputPaths: []string{output},
+		Modules:     map[string]string{"naming": "debug"},
+	}))
+
+	zap.L().Debug("global debug")
+	Named("naming").Debug("naming debug")
+	Named("mysql").Debug("mysql debug")
+	Named("mysql").Info("mysql info")
+	require.Equal(map[string]string{"naming": "debug"}, ModuleLevels())
+
+	// 运行时修改
+	require.NoError(SetModuleLevel("mysql", "debug"))
+	require.NoError(SetModuleLevel("naming", ""))
+	Named("mysql").Debug("mysql debug again")
+	Named("naming").Debug("naming debug again")
+	require.Error(SetModuleLevel("mysql", "trace"))
+
+	req := httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"error"}`))
+	w := httptest.NewRecorder()
+	LevelHandler().ServeHTTP(w, req)
+	require.Equal(http.StatusOK, w.Code)
+	require.Contains(w.Body.String(), `"level":"error"`)
+	require.Contains(w.Body.String(), `"mysql":"debug"`)
+	zap.L().Warn("global warn")
+
+	req = httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"module":"redis","level":"bad"}`))
+	w = httptest.NewRecorder()
+	LevelHandler().ServeHTTP(w, req)
+	require.Equal(http.StatusBadRequest, w.Code)
+
+	// 重新加载配置时以配置为准
+	require.NoError(applyLevels(&LogConf{Level: "info"}))
+	require.Empty(ModuleLevels())
+	require.Equal("info", Level().String())
+
+	require.NoError(Sync())
+	b, err := ioutil.ReadFile(output)
+	require.NoError(err)
+	content := string(b)
+	require.NotContains(content, "global debug")
+	require.Contains(content, "naming debug")
+	require.NotContains(content, `"mysql debug"`)
+	require.Contains(content, "mysql info")
+	require.Contains(content, "mysql debug again")
+	require.NotContains(content, "naming debug again")
+	require.NotContains(content, "global warn")
+	require.Contains(content, `"logger":"naming"`)
+}
+
+func TestOutputs(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "motor-log")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "app.log")
+	errOutput := filepath.Join(dir, "error.log")
+	auditOutput := filepath.Join(dir, "audit.log")
+
+	require.NoError(build(&LogConf{
+		Level:         "info",
+		DisableCaller: true,
+		OutputPaths:   []string{output},
+		Encoder: &EncoderConf{
+			TimeKey:       "ts",
+			LevelKey:      "severity",
+			StacktraceKey: "-",
+			TimeFormat:    "millis",
+			LevelFormat:   "capital",
+		},
+		Sampling: &SamplingConf{Disable: true},
+		Outputs: []*OutputConf{{
+			Name:    "error",
+			Level:   "error",
+			Paths:   []string{errOutput},
+			Encoder: &EncoderConf{},
+		}, {
+			Name:    "audit",
+			Paths:   []string{auditOutput},
+			Modules: []string{"audit"},
+		}},
+	}))
+
+	for i := 0; i < 200; i++ {
+		zap.L().Info("repeated")
+	}
+	zap.L().Error("failed")
+	Named("audit").Info("audit")
+	Named("audit.login").Info("audit login")
+	require.NoError(Sync())
+
+	b, err := ioutil.ReadFile(output)
+	require.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(lines, 201)
+
+	var entry map[string]interface{}
+	require.NoError(json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal("INFO", entry["severity"])
+	ts, ok := entry["ts"].(float64)
+	require.True(ok)
+	require.Equal(float64(int64(ts)), ts)
+	require.NotContains(entry, "caller")
+	require.NotContains(lines[200], "stacktrace")
+
+	// error输出单独配置了默认编码，携带堆栈
+	b, err = ioutil.ReadFile(errOutput)
+	require.NoError(err)
+	lines = strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(lines, 1)
+	require.NoError(json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal("error", entry["level"])
+	require.Contains(entry, "stacktrace")
+
+	// 指定模块的日志仅输出到对应的输出
+	b, err = ioutil.ReadFile(auditOutput)
+	require.NoError(err)
+	lines = strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(lines, 2)
+	require.Contains(lines[1], "audit login")
+
+	require.Error(build(&LogConf{Encoding: "xml"}))
+	require.Error(build(&LogConf{Outputs: []*OutputConf{{Name: "bad", Level: "trace"}}}))
+}
+
+func TestGetConf(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+
+	cfg, err := getConf()
+	require.NoError(err)
+	require.Equal("debug", cfg.Modules["naming"])
+	require.Equal(conf.ByteSize(100<<20), cfg.Rotate.MaxSize)
+	require.Equal(4096, cfg.Async.BufferSize)
+	require.Equal("iso8601", cfg.Encoder.TimeFormat)
+	require.Equal(100, cfg.Sampling.Thereafter)
+	require.Len(cfg.Outputs, 2)
+	require.Equal("error", cfg.Outputs[0].Level)
+	require.Equal([]string{"bodydump"}, cfg.Outputs[1].Modules)
+}
+
+type mockSpanIDer struct{}
+
+func (mockSpanIDer) GetTraceID(span opentracing.Span) (string, bool) {
+	sc, ok := span.Context().(mocktracer.MockSpanContext)
+	return strconv.Itoa(sc.TraceID), ok
+}
+
+func (mockSpanIDer) GetSpanID(span opentracing.Span) (string, bool) {
+	sc, ok := span.Context().(mocktracer.MockSpanContext)
+	return strconv.Itoa(sc.SpanID), ok
+}
+
+func TestContextFields(t *testing.T) {
+	require := require.New(t)
+
+	core, logs := observer.New(zap.DebugLevel)
+	defer zap.ReplaceGlobals(zap.L())
+	zap.ReplaceGlobals(zap.New(core))
+
+	ctx := WithSubject(WithRequestID(context.Background(), "req-1"), "user-1")
+	Ctx(ctx).Info("from ctx")
+	Ctx(context.Background()).Info("empty ctx")
+
+	span := mocktracer.New().StartSpan("test")
+	spanCtx := opentracing.ContextWithSpan(context.Background(), span)
+	// 未设置SpanIDer时不输出trace_id
+	Ctx(spanCtx).Info("without span ider")
+	defer SetSpanIDer(nil)
+	SetSpanIDer(mockSpanIDer{})
+	Ctx(spanCtx).Info("with span ider")
+
+	sc := span.Context().(mocktracer.MockSpanContext)
+	entries := logs.All()
+	require.Len(entries, 4)
+	require.Equal(map[string]interface{}{"request_id": "req-1", "subject": "user-1"}, entries[0].ContextMap())
+	require.Empty(entries[1].ContextMap())
+	require.Empty(entries[2].ContextMap())
+	require.Equal(map[string]interface{}{
+		"trace_id": strconv.Itoa(sc.TraceID),
+		"span_id":  strconv.Itoa(sc.SpanID),
+	}, entries[3].ContextMap())
+}
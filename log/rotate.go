@@ -0,0 +1,310 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/pkg/errors"
+)
+
+const (
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+)
+
+type RotateConf struct {
+	// 单个文件的最大大小，为0时不按大小切割
+	MaxSize conf.ByteSize `toml:"maxSize"`
+	// 按时间切割的周期，如1h、24h，按本地时间对齐，为0时不按时间切割
+	Interval conf.Duration `toml:"interval"`
+	// 切割后文件的最长保留时间，为0时不按时间清理
+	MaxAge conf.Duration `toml:"maxAge"`
+	// 切割后文件的最多保留个数，为0时不按个数清理
+	MaxBackups int `toml:"maxBackups"`
+	// 是否gzip压缩切割后的文件
+	Compress bool `toml:"compress"`
+}
+
+// 支持按大小及时间切割的日志文件，切割后的文件命名为name-<time>.ext
+type rotateWriter struct {
+	mutex    sync.Mutex
+	filename string
+	cfg      RotateConf
+	file     *os.File
+	size     int64
+	next     time.Time
+
+	millCh   chan struct{}
+	millOnce sync.Once
+	closed   bool
+}
+
+func newRotateWriter(filename string, cfg *RotateConf) (*rotateWriter, error) {
+	w := &rotateWriter{
+		filename: filename,
+		cfg:      *cfg,
+		millCh:   make(chan struct{}, 1),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// 关闭后不再重新打开文件，避免文件句柄泄漏
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotateWriter) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// 关闭文件并停止压缩及清理的goroutine
+func (w *rotateWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.closed {
+		w.closed = true
+		close(w.millCh)
+	}
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *rotateWriter) shouldRotate(n int64) bool {
+	// 空文件不切割，避免单条日志超过MaxSize时产生大量空文件
+	if w.size > 0 && w.cfg.MaxSize > 0 && w.size+n > int64(w.cfg.MaxSize) {
+		return true
+	}
+	return !w.next.IsZero() && !time.Now().Before(w.next)
+}
+
+func (w *rotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.filename), 0755); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("os.MkdirAll failed, filename:%s", w.filename))
+	}
+	f, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("os.OpenFile failed, filename:%s", w.filename))
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, fmt.Sprintf("file.Stat failed, filename:%s", w.filename))
+	}
+
+	w.file = f
+	w.size = info.Size()
+	w.next = nextRotateTime(time.Now(), time.Duration(w.cfg.Interval))
+	return nil
+}
+
+func (w *rotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("file.Close failed, filename:%s", w.filename))
+	}
+	w.file = nil
+
+	// 同一毫秒内多次切割时顺延，避免覆盖
+	t := time.Now()
+	backup := w.backupName(t)
+	for {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		t = t.Add(time.Millisecond)
+		backup = w.backupName(t)
+	}
+	if err := os.Rename(w.filename, backup); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("os.Rename failed, filename:%s", w.filename))
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if w.closed {
+		return nil
+	}
+	w.millOnce.Do(func() {
+		go w.millLoop()
+	})
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (w *rotateWriter) backupName(t time.Time) string {
+	dir, prefix, ext := w.nameParts()
+	return filepath.Join(dir, fmt.Sprintf("%s%s%s", prefix, t.Format(backupTimeFormat), ext))
+}
+
+func (w *rotateWriter) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(w.filename)
+	name := filepath.Base(w.filename)
+	ext = filepath.Ext(name)
+	prefix = name[:len(name)-len(ext)] + "-"
+	return
+}
+
+type backupFile struct {
+	name string
+	t    time.Time
+}
+
+// 压缩及清理切割后的文件，在独立的goroutine中执行
+func (w *rotateWriter) millLoop() {
+	for range w.millCh {
+		if err := w.mill(); err != nil {
+			fmt.Fprintf(os.Stderr, "log rotate mill failed, filename:%s, err:%v\n", w.filename, err)
+		}
+	}
+}
+
+func (w *rotateWriter) mill() error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+
+	var remove []backupFile
+	keep := backups[:0]
+	cutoff := time.Now().Add(-time.Duration(w.cfg.MaxAge))
+	for i, b := range backups {
+		if (w.cfg.MaxBackups > 0 && i >= w.cfg.MaxBackups) ||
+			(w.cfg.MaxAge > 0 && b.t.Before(cutoff)) {
+			remove = append(remove, b)
+			continue
+		}
+		keep = append(keep, b)
+	}
+
+	dir := filepath.Dir(w.filename)
+	for _, b := range remove {
+		if err := os.Remove(filepath.Join(dir, b.name)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, fmt.Sprintf("os.Remove failed, name:%s", b.name))
+		}
+	}
+	if !w.cfg.Compress {
+		return nil
+	}
+	for _, b := range keep {
+		if strings.HasSuffix(b.name, compressSuffix) {
+			continue
+		}
+		if err := compressFile(filepath.Join(dir, b.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 按切割时间倒序返回切割后的文件
+func (w *rotateWriter) backups() ([]backupFile, error) {
+	dir, prefix, ext := w.nameParts()
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("ioutil.ReadDir failed, dir:%s", dir))
+	}
+
+	var backups []backupFile
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), compressSuffix)
+		if !strings.HasSuffix(ts, ext) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, strings.TrimSuffix(ts, ext), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{name: name, t: t})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].t.After(backups[j].t)
+	})
+
+	return backups, nil
+}
+
+func compressFile(src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("os.Open failed, name:%s", src))
+	}
+	defer f.Close()
+
+	dst := src + compressSuffix
+	gzf, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("os.OpenFile failed, name:%s", dst))
+	}
+	gz := gzip.NewWriter(gzf)
+	if _, err = io.Copy(gz, f); err == nil {
+		err = gz.Close()
+	}
+	if cerr := gzf.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return errors.Wrap(err, fmt.Sprintf("compress failed, name:%s", src))
+	}
+
+	return os.Remove(src)
+}
+
+// 下一个按本地时间对齐的切割时间点
+func nextRotateTime(now time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		return time.Time{}
+	}
+	_, offset := now.Zone()
+	shift := time.Duration(offset) * time.Second
+	return now.Add(shift).Truncate(interval).Add(interval).Add(-shift)
+}
@@ -0,0 +1,156 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRotateBySize(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "motor-rotate")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "sub", "app.log")
+	w, err := newRotateWriter(filename, &RotateConf{
+		MaxSize:    100,
+		MaxBackups: 2,
+		Compress:   true,
+	})
+	require.NoError(err)
+	defer w.Close()
+
+	line := []byte(strings.Repeat("x", 39) + "\n")
+	for i := 0; i < 10; i++ {
+		_, err := w.Write(line)
+		require.NoError(err)
+	}
+
+	// 当前文件保留最后两行
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(err)
+	require.Equal(80, len(b))
+
+	require.Eventually(func() bool {
+		backups, err := w.backups()
+		if err != nil || len(backups) != 2 {
+			return false
+		}
+		for _, b := range backups {
+			if !strings.HasSuffix(b.name, ".log.gz") {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestNextRotateTime(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2020, 5, 1, 15, 30, 0, 0, loc)
+
+	require.True(t, nextRotateTime(now, 0).IsZero())
+	require.Equal(t, time.Date(2020, 5, 2, 0, 0, 0, 0, loc), nextRotateTime(now, 24*time.Hour))
+	require.Equal(t, time.Date(2020, 5, 1, 16, 0, 0, 0, loc), nextRotateTime(now, time.Hour))
+}
+
+type blockingWriter struct {
+	mutex   sync.Mutex
+	buf     bytes.Buffer
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) Sync() error {
+	return nil
+}
+
+func (w *blockingWriter) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriter(t *testing.T) {
+	require := require.New(t)
+
+	_, err := newAsyncWriter(zapcore.AddSync(ioutil.Discard), &AsyncConf{DropPolicy: "unknown"})
+	require.Error(err)
+
+	ws := &blockingWriter{release: make(chan struct{})}
+	w, err := newAsyncWriter(ws, &AsyncConf{BufferSize: 2})
+	require.NoError(err)
+
+	dropped := Dropped()
+	// 写goroutine阻塞在第一条，队列可再容纳两条
+	for i := 0; i < 5; i++ {
+		_, err := w.Write([]byte("a"))
+		require.NoError(err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(dropped+2, Dropped())
+
+	close(ws.release)
+	require.NoError(w.Sync())
+	require.Equal("aaa", ws.String())
+
+	// 关闭时写入队列中剩余的日志，关闭后写入的日志计入丢弃数
+	_, err = w.Write([]byte("b"))
+	require.NoError(err)
+	require.NoError(w.Close())
+	require.Equal("aaab", ws.String())
+	dropped = Dropped()
+	_, err = w.Write([]byte("c"))
+	require.NoError(err)
+	require.Equal(dropped+1, Dropped())
+	require.NoError(w.Sync())
+	require.NoError(w.Close())
+}
+
+func TestRotateWriterClose(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "motor-rotate")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	w, err := newRotateWriter(filepath.Join(dir, "app.log"), &RotateConf{MaxSize: 10})
+	require.NoError(err)
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(err)
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	// 关闭后mill goroutine退出
+	require.Eventually(func() bool {
+		select {
+		case _, ok := <-w.millCh:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+
+	// 关闭后写入返回错误，不再重新打开文件
+	_, err = w.Write([]byte("0123456789"))
+	require.Equal(os.ErrClosed, err)
+	require.Nil(w.file)
+	require.NoError(w.Close())
+}
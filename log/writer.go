@@ -0,0 +1,211 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// 队列满时丢弃日志
+	DropPolicyDrop = "drop"
+	// 队列满时阻塞等待
+	DropPolicyBlock = "block"
+
+	defaultBufferSize = 4096
+)
+
+var (
+	// 异步写日志时因队列满而丢弃的日志条数
+	_dropped uint64
+)
+
+type AsyncConf struct {
+	// 队列长度(日志条数)，默认4096
+	BufferSize int `toml:"bufferSize"`
+	// 队列满时的处理策略：drop(默认)、block
+	DropPolicy string `toml:"dropPolicy"`
+}
+
+// 异步写日志时丢弃的日志条数
+func Dropped() uint64 {
+	return atomic.LoadUint64(&_dropped)
+}
+
+// 打开日志输出，stdout、stderr之外的路径按文件处理，配置了rotate时支持切割
+func openWriters(paths []string, rotate *RotateConf) (zapcore.WriteSyncer, []io.Closer, error) {
+	var (
+		writers []zapcore.WriteSyncer
+		closers []io.Closer
+	)
+
+	for _, path := range paths {
+		switch path {
+		case "stdout":
+			writers = append(writers, zapcore.Lock(os.Stdout))
+			continue
+		case "stderr":
+			writers = append(writers, zapcore.Lock(os.Stderr))
+			continue
+		}
+
+		path = strings.TrimPrefix(path, "file://")
+		if rotate != nil {
+			w, err := newRotateWriter(path, rotate)
+			if err != nil {
+				closeAll(closers)
+				return nil, nil, err
+			}
+			writers = append(writers, w)
+			closers = append(closers, w)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			closeAll(closers)
+			return nil, nil, errors.Wrap(err, fmt.Sprintf("os.MkdirAll failed, path:%s", path))
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, errors.Wrap(err, fmt.Sprintf("os.OpenFile failed, path:%s", path))
+		}
+		writers = append(writers, zapcore.Lock(f))
+		closers = append(closers, f)
+	}
+
+	return zapcore.NewMultiWriteSyncer(writers...), closers, nil
+}
+
+type asyncEntry struct {
+	b []byte
+	// 不为nil时表示flush请求，写入之前的日志并Sync后返回结果
+	done chan error
+}
+
+// 异步写日志，由独立的goroutine写入下层输出
+type asyncWriter struct {
+	ws    zapcore.WriteSyncer
+	queue chan asyncEntry
+	block bool
+
+	// 关闭后不再入队，保证loop退出前能取出队列中的全部日志
+	mutex     sync.RWMutex
+	closed    bool
+	quit      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newAsyncWriter(ws zapcore.WriteSyncer, cfg *AsyncConf) (*asyncWriter, error) {
+	size := cfg.BufferSize
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+
+	var block bool
+	switch cfg.DropPolicy {
+	case DropPolicyDrop, "":
+	case DropPolicyBlock:
+		block = true
+	default:
+		return nil, fmt.Errorf("invalid log async dropPolicy, dropPolicy:%s", cfg.DropPolicy)
+	}
+
+	w := &asyncWriter{
+		ws:    ws,
+		queue: make(chan asyncEntry, size),
+		block: block,
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go w.loop()
+
+	return w, nil
+}
+
+// 关闭后写入的日志计入丢弃数
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	// zap会复用p，需拷贝后入队
+	b := make([]byte, len(p))
+	copy(b, p)
+
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.closed {
+		atomic.AddUint64(&_dropped, 1)
+		return len(p), nil
+	}
+
+	if w.block {
+		w.queue <- asyncEntry{b: b}
+		return len(p), nil
+	}
+	select {
+	case w.queue <- asyncEntry{b: b}:
+	default:
+		atomic.AddUint64(&_dropped, 1)
+	}
+	return len(p), nil
+}
+
+// 等待队列中的日志写入完成
+func (w *asyncWriter) Sync() error {
+	w.mutex.RLock()
+	if w.closed {
+		w.mutex.RUnlock()
+		return w.ws.Sync()
+	}
+	done := make(chan error, 1)
+	w.queue <- asyncEntry{done: done}
+	w.mutex.RUnlock()
+
+	return <-done
+}
+
+// 写入队列中剩余的日志后返回，可重复调用
+func (w *asyncWriter) Close() error {
+	w.closeOnce.Do(func() {
+		w.mutex.Lock()
+		w.closed = true
+		close(w.quit)
+		w.mutex.Unlock()
+	})
+	<-w.done
+	return w.ws.Sync()
+}
+
+func (w *asyncWriter) loop() {
+	defer close(w.done)
+	for {
+		select {
+		case e := <-w.queue:
+			w.handle(e)
+		case <-w.quit:
+			// 关闭后不再有新的日志入队
+			for {
+				select {
+				case e := <-w.queue:
+					w.handle(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *asyncWriter) handle(e asyncEntry) {
+	if e.done != nil {
+		e.done <- w.ws.Sync()
+		return
+	}
+	w.ws.Write(e.b)
+}
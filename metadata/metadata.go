@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"context"
+	"strings"
+)
+
+// 请求级的元数据(trace上下文、baggage等)，key统一为小写
+// 实现了opentracing.TextMapReader/TextMapWriter，可直接作为Inject/Extract的carrier
+type Metadata map[string]string
+
+type ctxKey struct{}
+
+// 由kv对创建Metadata，kv个数为奇数时忽略最后一个
+func Pairs(kv ...string) Metadata {
+	md := make(Metadata, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		md.Set(kv[i], kv[i+1])
+	}
+	return md
+}
+
+// 获取key对应的值，key不区分大小写
+func (md Metadata) Get(key string) (string, bool) {
+	val, ok := md[strings.ToLower(key)]
+	return val, ok
+}
+
+// 设置key对应的值，key不区分大小写
+func (md Metadata) Set(key, val string) {
+	md[strings.ToLower(key)] = val
+}
+
+func (md Metadata) Delete(key string) {
+	delete(md, strings.ToLower(key))
+}
+
+// 遍历所有kv，handler返回错误时中止
+func (md Metadata) ForeachKey(handler func(key, val string) error) error {
+	for k, v := range md {
+		if err := handler(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (md Metadata) Copy() Metadata {
+	cp := make(Metadata, len(md))
+	for k, v := range md {
+		cp[k] = v
+	}
+	return cp
+}
+
+// 将ctx中已有的元数据与md合并，md中的值优先
+func NewContext(ctx context.Context, md Metadata) context.Context {
+	merged := md.Copy()
+	if old, ok := FromContext(ctx); ok {
+		for k, v := range old {
+			if _, exists := merged[k]; !exists {
+				merged[k] = v
+			}
+		}
+	}
+	return context.WithValue(ctx, ctxKey{}, merged)
+}
+
+// 获取ctx中的元数据，返回的是副本，修改后需通过NewContext写回
+func FromContext(ctx context.Context) (Metadata, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	md, ok := ctx.Value(ctxKey{}).(Metadata)
+	if !ok {
+		return nil, false
+	}
+	return md.Copy(), true
+}
+
+// 获取ctx中key对应的值
+func Get(ctx context.Context, key string) (string, bool) {
+	md, ok := FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return md.Get(key)
+}
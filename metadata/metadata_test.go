@@ -0,0 +1,48 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ opentracing.TextMapReader = Metadata{}
+	_ opentracing.TextMapWriter = Metadata{}
+)
+
+func TestMetadata(t *testing.T) {
+	require := require.New(t)
+
+	md := Pairs("Uber-Trace-Id", "1:2:0:1", "tenant", "a", "odd")
+	require.Len(md, 2)
+	val, ok := md.Get("uber-trace-id")
+	require.True(ok)
+	require.Equal("1:2:0:1", val)
+	md.Delete("TENANT")
+	_, ok = md.Get("tenant")
+	require.False(ok)
+
+	_, ok = FromContext(context.Background())
+	require.False(ok)
+
+	ctx := NewContext(context.Background(), Pairs("tenant", "a", "region", "bj"))
+	ctx = NewContext(ctx, Pairs("tenant", "b"))
+	got, ok := FromContext(ctx)
+	require.True(ok)
+	require.Equal(Metadata{"tenant": "b", "region": "bj"}, got)
+
+	// 修改副本不影响ctx中的值
+	got.Set("region", "sh")
+	val, _ = Get(ctx, "Region")
+	require.Equal("bj", val)
+}
+
+func TestCancelPropagation(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	ctx := NewContext(parent, Pairs("k", "v"))
+	cancel()
+	require.Equal(t, context.Canceled, ctx.Err())
+}
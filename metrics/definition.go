@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 指标类型
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+)
+
+// 指标定义，埋点与dashboard、告警规则的生成共用，Name不含namespace
+type Definition struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels []string
+}
+
+// 加上namespace后的指标名
+func (d Definition) FQName(namespace string) string {
+	return prometheus.BuildFQName(namespace, "", d.Name)
+}
+
+// http请求统计的指标，开启MethodLabel时请求数及耗时额外携带method标签
+var (
+	HTTPRequests = Definition{
+		Name:   "requests_total",
+		Help:   "http client requests count",
+		Type:   TypeCounter,
+		Labels: []string{"path", "code"},
+	}
+	HTTPRequestErrors = Definition{
+		Name:   "requests_errcode_total",
+		Help:   "http client error requests count",
+		Type:   TypeCounter,
+		Labels: []string{"path", "code"},
+	}
+	HTTPDuration = Definition{
+		Name:   "duration_ms",
+		Help:   "http client requests duration(ms)",
+		Type:   TypeHistogram,
+		Labels: []string{"path"},
+	}
+	HTTPInFlight = Definition{
+		Name: "requests_in_flight",
+		Help: "http client requests being served",
+		Type: TypeGauge,
+	}
+)
+
+func HTTPDefinitions() []Definition {
+	return []Definition{HTTPRequests, HTTPRequestErrors, HTTPDuration, HTTPInFlight}
+}
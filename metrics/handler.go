@@ -5,9 +5,14 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// 输出默认Registry中的指标，未初始化时输出prometheus全局注册表中的指标
 func MetricsHandler() gin.HandlerFunc {
-	h := promhttp.Handler()
+	def := promhttp.Handler()
 	return func(c *gin.Context) {
-		h.ServeHTTP(c.Writer, c.Request)
+		if r := Default(); r != nil {
+			r.Handler().ServeHTTP(c.Writer, c.Request)
+			return
+		}
+		def.ServeHTTP(c.Writer, c.Request)
 	}
 }
@@ -1,39 +1,194 @@
 package metrics
 
 import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/log"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-var (
-	ReqCnt *prometheus.CounterVec
-	ReqDur *prometheus.HistogramVec
-	ReqErr *prometheus.CounterVec
+const (
+	// 耗时统计方式
+	DurationHistogram = "histogram"
+	DurationSummary   = "summary"
 
+	// 未匹配到路由(404)的请求使用的path标签
+	UnmatchedPath = "unmatched"
+)
+
+var (
 	DefaultPath = "/metrics"
+
+	// 默认的耗时bucket(ms)
+	DefaultBuckets = []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+	// 默认的summary分位数及误差
+	DefaultObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+
+	_mutex    sync.RWMutex
+	_registry *Registry
 )
 
+type MetricsConf struct {
+	Namespace string
+	// 所有指标携带的标签，如app、idc、pubenv
+	ConstLabels map[string]string
+	// 是否采集go运行时指标
+	GoCollector bool
+	// 是否采集进程指标
+	ProcessCollector bool
+
+	// 耗时统计方式：histogram(默认)、summary
+	DurationType string
+	// histogram的bucket(ms)，为空时使用DefaultBuckets
+	Buckets []float64
+	// summary的分位数及误差，如{"0.99" = 0.001}，为空时使用DefaultObjectives
+	Objectives map[string]float64
+	// 是否增加method标签
+	MethodLabel bool
+	// 是否统计处理中的请求数
+	InFlight bool
+}
+
+// 从application.toml的[Metrics]中加载配置
+func LoadConf() (*MetricsConf, error) {
+	var cfg MetricsConf
+	if err := conf.UnmarshalSection("application.toml", "Metrics", &cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// 创建默认Registry，可重复调用，后者替换前者
 func Init(namespace string) {
-	ReqCnt = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "requests_total",
-			Help:      "http client requests count",
-		}, []string{"path", "code"})
-
-	ReqDur = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Namespace: namespace,
-			Name:      "duration_ms",
-			Buckets:   []float64{0.5, 1, 2, 5, 10},
-			Help:      "http client requests duration(ms)",
-		}, []string{"path"})
-
-	ReqErr = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "requests_errcode_total",
-			Help:      "http client error requests count",
-		}, []string{"path", "code"})
+	cfg := &MetricsConf{
+		Namespace:        namespace,
+		GoCollector:      true,
+		ProcessCollector: true,
+	}
+	if err := InitWithConf(cfg); err != nil {
+		panic(err)
+	}
+}
+
+func InitWithConf(cfg *MetricsConf) error {
+	r, err := NewRegistry(cfg)
+	if err != nil {
+		return err
+	}
+	SetDefault(r)
+	return nil
+}
+
+// 获取默认Registry，未初始化时返回nil
+func Default() *Registry {
+	_mutex.RLock()
+	defer _mutex.RUnlock()
+	return _registry
+}
+
+func SetDefault(r *Registry) {
+	_mutex.Lock()
+	_registry = r
+	_mutex.Unlock()
+}
+
+// 使用默认Registry记录一次http请求，未初始化时忽略
+func ObserveRequest(path, method string, status int, dur time.Duration, isErr bool) {
+	if r := Default(); r != nil {
+		r.ObserveRequest(path, method, status, dur, isErr)
+	}
+}
+
+// http请求统计
+type httpMetrics struct {
+	cnt         *prometheus.CounterVec
+	dur         prometheus.ObserverVec
+	errCnt      *prometheus.CounterVec
+	inFlight    prometheus.Gauge
+	methodLabel bool
+}
+
+func (r *Registry) initHTTP(cfg *MetricsConf) error {
+	labels := HTTPRequests.Labels
+	durLabels := HTTPDuration.Labels
+	if cfg.MethodLabel {
+		labels = []string{"path", "method", "code"}
+		durLabels = []string{"path", "method"}
+	}
+
+	h := &httpMetrics{methodLabel: cfg.MethodLabel}
+	h.cnt = r.Counter(HTTPRequests.Name, HTTPRequests.Help, labels...)
+	h.errCnt = r.Counter(HTTPRequestErrors.Name, HTTPRequestErrors.Help, labels...)
+
+	switch cfg.DurationType {
+	case DurationHistogram, "":
+		buckets := cfg.Buckets
+		if len(buckets) == 0 {
+			buckets = DefaultBuckets
+		}
+		h.dur = r.Histogram(HTTPDuration.Name, HTTPDuration.Help, buckets, durLabels...)
+	case DurationSummary:
+		objectives := DefaultObjectives
+		if len(cfg.Objectives) > 0 {
+			objectives = make(map[float64]float64, len(cfg.Objectives))
+			for q, e := range cfg.Objectives {
+				f, err := strconv.ParseFloat(q, 64)
+				if err != nil {
+					return errors.Wrap(err, fmt.Sprintf("invalid metrics objective, quantile:%s", q))
+				}
+				objectives[f] = e
+			}
+		}
+		h.dur = r.Summary(HTTPDuration.Name, HTTPDuration.Help, objectives, durLabels...)
+	default:
+		return fmt.Errorf("invalid metrics durationType, durationType:%s", cfg.DurationType)
+	}
 
-	prometheus.MustRegister(ReqCnt, ReqDur, ReqErr)
+	if cfg.InFlight {
+		h.inFlight = r.Gauge(HTTPInFlight.Name, HTTPInFlight.Help).WithLabelValues()
+	}
+
+	r.http = h
+	return nil
+}
+
+// 记录一次http请求，path应为路由模板，未匹配到路由时使用UnmatchedPath
+func (r *Registry) ObserveRequest(path, method string, status int, dur time.Duration, isErr bool) {
+	h := r.http
+	code := strconv.Itoa(status)
+	labels := []string{path, code}
+	durLabels := []string{path}
+	if h.methodLabel {
+		labels = []string{path, method, code}
+		durLabels = []string{path, method}
+	}
+
+	h.cnt.WithLabelValues(labels...).Inc()
+	h.dur.WithLabelValues(durLabels...).Observe(float64(dur) / float64(time.Millisecond))
+	if isErr {
+		h.errCnt.WithLabelValues(labels...).Inc()
+	}
+}
+
+// 处理中的请求数，未开启时返回nil
+func (r *Registry) InFlight() prometheus.Gauge {
+	return r.http.inFlight
+}
+
+func logDropped(r *Registry) prometheus.Collector {
+	return prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Namespace:   r.namespace,
+			Name:        "log_dropped_total",
+			Help:        "log entries dropped by async writer",
+			ConstLabels: r.constLabels,
+		}, func() float64 {
+			return float64(log.Dropped())
+		})
 }
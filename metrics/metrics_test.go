@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConf(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+
+	cfg, err := LoadConf()
+	require.NoError(err)
+	require.Equal("motor", cfg.Namespace)
+	require.Equal(DurationHistogram, cfg.DurationType)
+	require.Len(cfg.Buckets, 13)
+	require.Equal(0.001, cfg.Objectives["0.99"])
+	require.True(cfg.MethodLabel)
+	require.True(cfg.InFlight)
+	require.Equal("bj", cfg.ConstLabels["idc"])
+	require.True(cfg.GoCollector)
+
+	require.Error(InitWithConf(&MetricsConf{DurationType: "unknown"}))
+	require.Error(InitWithConf(&MetricsConf{DurationType: DurationSummary, Objectives: map[string]float64{"p99": 0.1}}))
+}
+
+func scrape(t *testing.T, r *Registry) string {
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", DefaultPath, nil))
+	require.Equal(t, 200, w.Code)
+	return w.Body.String()
+}
+
+func TestRegistry(t *testing.T) {
+	require := require.New(t)
+
+	cfg := &MetricsConf{
+		Namespace:   "test",
+		ConstLabels: map[string]string{"app": "motor", "idc": "bj"},
+	}
+	r1, err := NewRegistry(cfg)
+	require.NoError(err)
+	r2, err := NewRegistry(cfg)
+	require.NoError(err)
+
+	// 同名指标重复定义时返回已有的指标
+	c := r1.Counter("orders_total", "orders count", "type")
+	require.True(c == r1.Counter("orders_total", "orders count", "type"))
+	c.WithLabelValues("vip").Inc()
+	r2.Counter("orders_total", "orders count", "type").WithLabelValues("vip").Add(2)
+	r1.Gauge("queue_size", "queue size").WithLabelValues().Set(3)
+	r1.Histogram("task_duration_ms", "task duration(ms)", nil, "task").WithLabelValues("sync").Observe(5)
+
+	body := scrape(t, r1)
+	require.Contains(body, `test_orders_total{app="motor",idc="bj",type="vip"} 1`)
+	require.Contains(body, `test_queue_size{app="motor",idc="bj"} 3`)
+	require.Contains(body, `test_task_duration_ms_count{app="motor",idc="bj",task="sync"} 1`)
+	require.NotContains(body, "go_goroutines")
+	require.Contains(scrape(t, r2), `test_orders_total{app="motor",idc="bj",type="vip"} 2`)
+
+	// 开启运行时指标
+	r3, err := NewRegistry(&MetricsConf{Namespace: "test", GoCollector: true})
+	require.NoError(err)
+	require.Contains(scrape(t, r3), "go_goroutines")
+
+	// 重复初始化不会panic
+	require.NotPanics(func() {
+		Init("test")
+		Init("test")
+	})
+	require.NotNil(Default())
+}
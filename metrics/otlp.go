@@ -0,0 +1,294 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kaimixu/motor/internal/otlp"
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	otlpScopeName = "github.com/kaimixu/motor/metrics"
+	// 累计值
+	otlpTemporalityCumulative = 2
+)
+
+type OtlpConf struct {
+	// OTLP/HTTP metrics地址，如：http://localhost:4318/v1/metrics
+	Endpoint string
+	// 附加的请求头，如鉴权token
+	Headers map[string]string
+	// resource属性，未配置service.name时使用namespace
+	Resource map[string]string
+}
+
+// 以OTLP/HTTP JSON格式推送，所有指标均按累计值上报
+type OtlpExporter struct {
+	conf     *OtlpConf
+	client   *http.Client
+	resource otlp.Resource
+	start    string
+}
+
+func NewOtlpExporter(cfg *OtlpConf, serviceName string) (*OtlpExporter, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("otlp endpoint cannot be empty")
+	}
+
+	attrs := make(map[string]string, len(cfg.Resource)+1)
+	for k, v := range cfg.Resource {
+		attrs[k] = v
+	}
+	if _, ok := attrs["service.name"]; !ok && serviceName != "" {
+		attrs["service.name"] = serviceName
+	}
+
+	return &OtlpExporter{
+		conf:     cfg,
+		client:   &http.Client{},
+		resource: otlp.NewResource(attrs),
+		start:    unixNano(time.Now()),
+	}, nil
+}
+
+func (e *OtlpExporter) Name() string {
+	return "otlp"
+}
+
+func (e *OtlpExporter) Export(ctx context.Context, mfs []*dto.MetricFamily) error {
+	body, err := json.Marshal(e.convert(mfs, time.Now()))
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal failed")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.conf.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("http.NewRequest failed, endpoint:%s", e.conf.Endpoint))
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d, body:%s", resp.StatusCode, msg)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+func (e *OtlpExporter) Close() error {
+	return nil
+}
+
+func (e *OtlpExporter) convert(mfs []*dto.MetricFamily, now time.Time) *otlpRequest {
+	ts := unixNano(now)
+	metrics := make([]otlpMetric, 0, len(mfs))
+	for _, mf := range mfs {
+		m := otlpMetric{
+			Name:        mf.GetName(),
+			Description: mf.GetHelp(),
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := &otlpSum{AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}
+			for _, pm := range mf.Metric {
+				sum.DataPoints = append(sum.DataPoints, otlpNumberPoint{
+					Attributes:        otlpAttrs(pm.Label),
+					StartTimeUnixNano: e.start,
+					TimeUnixNano:      ts,
+					AsDouble:          pm.GetCounter().GetValue(),
+				})
+			}
+			m.Sum = sum
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			gauge := &otlpGauge{}
+			for _, pm := range mf.Metric {
+				v := pm.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					v = pm.GetUntyped().GetValue()
+				}
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					continue
+				}
+				gauge.DataPoints = append(gauge.DataPoints, otlpNumberPoint{
+					Attributes:   otlpAttrs(pm.Label),
+					TimeUnixNano: ts,
+					AsDouble:     v,
+				})
+			}
+			m.Gauge = gauge
+		case dto.MetricType_HISTOGRAM:
+			hist := &otlpHistogram{AggregationTemporality: otlpTemporalityCumulative}
+			for _, pm := range mf.Metric {
+				hist.DataPoints = append(hist.DataPoints, e.histogramPoint(pm, ts))
+			}
+			m.Histogram = hist
+		case dto.MetricType_SUMMARY:
+			summary := &otlpSummary{}
+			for _, pm := range mf.Metric {
+				s := pm.GetSummary()
+				p := otlpSummaryPoint{
+					Attributes:        otlpAttrs(pm.Label),
+					StartTimeUnixNano: e.start,
+					TimeUnixNano:      ts,
+					Count:             strconv.FormatUint(s.GetSampleCount(), 10),
+					Sum:               s.GetSampleSum(),
+				}
+				for _, q := range s.Quantile {
+					// 无样本时分位数为NaN，json无法编码
+					if math.IsNaN(q.GetValue()) {
+						continue
+					}
+					p.QuantileValues = append(p.QuantileValues, otlpQuantile{
+						Quantile: q.GetQuantile(),
+						Value:    q.GetValue(),
+					})
+				}
+				summary.DataPoints = append(summary.DataPoints, p)
+			}
+			m.Summary = summary
+		default:
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	return &otlpRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: e.resource,
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlp.Scope{Name: otlpScopeName},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+// prometheus的bucket为累计值，OTLP要求各区间的计数，且最后一个区间为(最大边界, +Inf)
+func (e *OtlpExporter) histogramPoint(pm *dto.Metric, ts string) otlpHistogramPoint {
+	h := pm.GetHistogram()
+	p := otlpHistogramPoint{
+		Attributes:        otlpAttrs(pm.Label),
+		StartTimeUnixNano: e.start,
+		TimeUnixNano:      ts,
+		Count:             strconv.FormatUint(h.GetSampleCount(), 10),
+		Sum:               h.GetSampleSum(),
+	}
+
+	var prev uint64
+	for _, b := range h.Bucket {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		p.ExplicitBounds = append(p.ExplicitBounds, b.GetUpperBound())
+		p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-prev, 10))
+		prev = b.GetCumulativeCount()
+	}
+	p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(h.GetSampleCount()-prev, 10))
+	return p
+}
+
+func otlpAttrs(labels []*dto.LabelPair) []otlp.Attr {
+	attrs := make([]otlp.Attr, 0, len(labels))
+	for _, lp := range labels {
+		attrs = append(attrs, otlp.StringAttr(lp.GetName(), lp.GetValue()))
+	}
+	return attrs
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// OTLP JSON编码中的64位整数使用字符串表示
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlp.Resource      `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlp.Scope   `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	Summary     *otlpSummary   `json:"summary,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlp.Attr `json:"attributes"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string      `json:"timeUnixNano"`
+	AsDouble          float64     `json:"asDouble"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlp.Attr `json:"attributes"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	TimeUnixNano      string      `json:"timeUnixNano"`
+	Count             string      `json:"count"`
+	Sum               float64     `json:"sum"`
+	BucketCounts      []string    `json:"bucketCounts"`
+	ExplicitBounds    []float64   `json:"explicitBounds"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryPoint `json:"dataPoints"`
+}
+
+type otlpSummaryPoint struct {
+	Attributes        []otlp.Attr    `json:"attributes"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	QuantileValues    []otlpQuantile `json:"quantileValues"`
+}
+
+type otlpQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
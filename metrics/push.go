@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+)
+
+const (
+	defPushInterval = 15 * time.Second
+	defPushTimeout  = 5 * time.Second
+)
+
+// 主动推送配置，用于无法被拉取的短任务或NAT后的服务
+type PushConf struct {
+	// 推送间隔，默认15s
+	Interval conf.Duration
+	// 单次推送超时，默认5s
+	Timeout conf.Duration
+
+	Pushgateway *PushgatewayConf
+	Statsd      *StatsdConf
+	Otlp        *OtlpConf
+}
+
+// 将Registry中的指标推送到外部系统
+type Exporter interface {
+	Name() string
+	Export(ctx context.Context, mfs []*dto.MetricFamily) error
+	Close() error
+}
+
+// 从metrics.toml的[Push]中加载配置
+func LoadPushConf() (*PushConf, error) {
+	var st conf.Storage
+	var cfg PushConf
+	if err := conf.Get("metrics.toml").Unmarshal(&st); err != nil {
+		return nil, errors.Wrap(err, "Get(metrics.toml).Unmarshal failed")
+	}
+	v := st.Get("Push")
+	if v == nil {
+		return nil, errors.New("section Push not found in metrics.toml")
+	}
+	if err := v.UnmarshalTOML(&cfg); err != nil {
+		return nil, errors.Wrap(err, "Get(Push).UnmarshalTOML failed")
+	}
+
+	return &cfg, nil
+}
+
+// 定时将Registry中的指标推送到各Exporter，关闭时再推送一次
+type Pusher struct {
+	g         prometheus.Gatherer
+	interval  time.Duration
+	timeout   time.Duration
+	exporters []Exporter
+
+	mutex     sync.Mutex
+	quit      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+func NewPusher(r *Registry, cfg *PushConf) (*Pusher, error) {
+	if r == nil {
+		return nil, errors.New("metrics registry uninitialized")
+	}
+
+	p := &Pusher{
+		g:        r.Gatherer(),
+		interval: time.Duration(cfg.Interval),
+		timeout:  time.Duration(cfg.Timeout),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if p.interval <= 0 {
+		p.interval = defPushInterval
+	}
+	if p.timeout <= 0 {
+		p.timeout = defPushTimeout
+	}
+
+	if cfg.Pushgateway != nil {
+		e, err := NewPushgatewayExporter(cfg.Pushgateway)
+		if err != nil {
+			return nil, err
+		}
+		p.exporters = append(p.exporters, e)
+	}
+	if cfg.Statsd != nil {
+		e, err := NewStatsdExporter(cfg.Statsd)
+		if err != nil {
+			p.closeExporters()
+			return nil, err
+		}
+		p.exporters = append(p.exporters, e)
+	}
+	if cfg.Otlp != nil {
+		e, err := NewOtlpExporter(cfg.Otlp, r.Namespace())
+		if err != nil {
+			p.closeExporters()
+			return nil, err
+		}
+		p.exporters = append(p.exporters, e)
+	}
+	if len(p.exporters) == 0 {
+		return nil, errors.New("no metrics exporter configured")
+	}
+
+	return p, nil
+}
+
+// 开始定时推送
+func (p *Pusher) Start() {
+	p.startOnce.Do(func() {
+		go p.loop()
+	})
+}
+
+func (p *Pusher) loop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+			_ = p.Push(ctx)
+			cancel()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// 立即推送一次，各Exporter的失败会记录日志，返回第一个错误
+func (p *Pusher) Push(ctx context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	mfs, err := p.g.Gather()
+	if err != nil {
+		// 部分指标采集失败时仍推送其余指标
+		log.Named("metrics").Warn("gather metrics failed", zap.Error(err))
+	}
+
+	var first error
+	for _, e := range p.exporters {
+		if err := e.Export(ctx, mfs); err != nil {
+			err = errors.Wrap(err, fmt.Sprintf("export metrics failed, exporter:%s", e.Name()))
+			log.Named("metrics").Error(fmt.Sprintf("%+v", err))
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// 停止定时推送，推送剩余的指标后关闭各Exporter
+func (p *Pusher) Close(ctx context.Context) (err error) {
+	p.closeOnce.Do(func() {
+		close(p.quit)
+		started := true
+		p.startOnce.Do(func() {
+			started = false
+		})
+		if started {
+			<-p.done
+		}
+
+		err = p.Push(ctx)
+		p.closeExporters()
+	})
+	return
+}
+
+func (p *Pusher) closeExporters() {
+	for _, e := range p.exporters {
+		if err := e.Close(); err != nil {
+			log.Named("metrics").Warn("close metrics exporter failed",
+				zap.String("exporter", e.Name()),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/internal/otlp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPushConf(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+
+	cfg, err := LoadPushConf()
+	require.NoError(err)
+	require.Equal(15*time.Second, time.Duration(cfg.Interval))
+	require.Equal("motor", cfg.Pushgateway.Job)
+	require.Equal("127.0.0.1", cfg.Pushgateway.Grouping["instance"])
+	require.True(cfg.Statsd.Dogstatsd)
+	require.Equal("motor", cfg.Otlp.Resource["service.name"])
+}
+
+func newPushRegistry(t *testing.T) *Registry {
+	r, err := NewRegistry(&MetricsConf{
+		Namespace:   "push",
+		ConstLabels: map[string]string{"app": "motor"},
+	})
+	require.NoError(t, err)
+	return r
+}
+
+func TestPushgatewayExporter(t *testing.T) {
+	require := require.New(t)
+
+	reqs := make(chan *http.Request, 4)
+	bodies := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		reqs <- req
+		bodies <- string(b)
+	}))
+	defer srv.Close()
+
+	r := newPushRegistry(t)
+	r.Counter("jobs_total", "jobs count", "type").WithLabelValues("sync").Inc()
+	p, err := NewPusher(r, &PushConf{Pushgateway: &PushgatewayConf{
+		URL:      srv.URL,
+		Job:      "batch",
+		Grouping: map[string]string{"instance": "a"},
+	}})
+	require.NoError(err)
+	require.NoError(p.Push(context.Background()))
+
+	req := <-reqs
+	require.Equal(http.MethodPut, req.Method)
+	require.Equal("/metrics/job/batch/instance/a", req.URL.Path)
+	require.NotEmpty(<-bodies)
+}
+
+func TestStatsdExporter(t *testing.T) {
+	require := require.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(err)
+	defer pc.Close()
+	read := func() string {
+		_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 65536)
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(err)
+		return string(buf[:n])
+	}
+
+	r := newPushRegistry(t)
+	cnt := r.Counter("jobs_total", "jobs count", "type")
+	cnt.WithLabelValues("sync").Add(3)
+	r.Gauge("queue_size", "queue size").WithLabelValues().Set(7)
+	p, err := NewPusher(r, &PushConf{Statsd: &StatsdConf{
+		Addr:      pc.LocalAddr().String(),
+		Prefix:    "svc",
+		Dogstatsd: true,
+	}})
+	require.NoError(err)
+	defer p.Close(context.Background())
+
+	require.NoError(p.Push(context.Background()))
+	lines := read()
+	require.Contains(lines, "svc.push_jobs_total:3|c|#app:motor,type:sync")
+	require.Contains(lines, "svc.push_queue_size:7|g|#app:motor")
+
+	// counter按差值上报
+	cnt.WithLabelValues("sync").Add(2)
+	require.NoError(p.Push(context.Background()))
+	lines = read()
+	require.Contains(lines, "svc.push_jobs_total:2|c|#app:motor,type:sync")
+
+	// 未开启DogStatsD时标签值拼接到指标名中
+	e := &StatsdExporter{conf: &StatsdConf{}, last: map[string]float64{}}
+	mfs, err := r.Gatherer().Gather()
+	require.NoError(err)
+	require.Contains(e.lines(mfs), "push_queue_size.motor:7|g")
+}
+
+func TestOtlpExporter(t *testing.T) {
+	require := require.New(t)
+
+	reqs := make(chan *http.Request, 4)
+	bodies := make(chan []byte, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		reqs <- req
+		bodies <- b
+	}))
+	defer srv.Close()
+
+	r := newPushRegistry(t)
+	r.Counter("jobs_total", "jobs count").WithLabelValues().Inc()
+	h := r.Histogram("job_duration_ms", "job duration(ms)", []float64{10, 100}).WithLabelValues()
+	h.Observe(5)
+	h.Observe(50)
+	h.Observe(500)
+	r.Summary("job_size", "job size", nil).WithLabelValues()
+
+	p, err := NewPusher(r, &PushConf{Otlp: &OtlpConf{
+		Endpoint: srv.URL + "/v1/metrics",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	}})
+	require.NoError(err)
+	require.NoError(p.Push(context.Background()))
+
+	req := <-reqs
+	require.Equal("/v1/metrics", req.URL.Path)
+	require.Equal("application/json", req.Header.Get("Content-Type"))
+	require.Equal("Bearer token", req.Header.Get("Authorization"))
+
+	var body otlpRequest
+	require.NoError(json.Unmarshal(<-bodies, &body))
+	require.Len(body.ResourceMetrics, 1)
+	rm := body.ResourceMetrics[0]
+	require.Equal([]otlp.Attr{otlp.StringAttr("service.name", "push")}, rm.Resource.Attributes)
+
+	metrics := make(map[string]otlpMetric)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	jobs := metrics["push_jobs_total"]
+	require.NotNil(jobs.Sum)
+	require.True(jobs.Sum.IsMonotonic)
+	require.Equal(1.0, jobs.Sum.DataPoints[0].AsDouble)
+	require.Equal([]otlp.Attr{otlp.StringAttr("app", "motor")}, jobs.Sum.DataPoints[0].Attributes)
+
+	dur := metrics["push_job_duration_ms"]
+	require.NotNil(dur.Histogram)
+	point := dur.Histogram.DataPoints[0]
+	require.Equal("3", point.Count)
+	require.Equal([]float64{10, 100}, point.ExplicitBounds)
+	require.Equal([]string{"1", "1", "1"}, point.BucketCounts)
+
+	// 无样本的summary分位数不上报
+	size := metrics["push_job_size"]
+	require.NotNil(size.Summary)
+	require.Empty(size.Summary.DataPoints[0].QuantileValues)
+
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("invalid metrics"))
+	})
+	err = p.Push(context.Background())
+	require.Error(err)
+	require.True(strings.Contains(err.Error(), "invalid metrics"))
+}
+
+func TestPusher(t *testing.T) {
+	require := require.New(t)
+
+	_, err := NewPusher(nil, &PushConf{})
+	require.Error(err)
+	_, err = NewPusher(newPushRegistry(t), &PushConf{})
+	require.Error(err)
+
+	pushed := make(chan struct{}, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		pushed <- struct{}{}
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(newPushRegistry(t), &PushConf{
+		Interval: conf.Duration(10 * time.Millisecond),
+		Otlp:     &OtlpConf{Endpoint: srv.URL},
+	})
+	require.NoError(err)
+	p.Start()
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("periodic push timeout")
+	}
+
+	require.NoError(p.Close(context.Background()))
+	require.NoError(p.Close(context.Background()))
+
+	// 关闭时推送剩余的指标
+	for len(pushed) > 0 {
+		<-pushed
+	}
+	p, err = NewPusher(newPushRegistry(t), &PushConf{Otlp: &OtlpConf{Endpoint: srv.URL}})
+	require.NoError(err)
+	require.NoError(p.Close(context.Background()))
+	require.Len(pushed, 1)
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type PushgatewayConf struct {
+	// pushgateway地址，如：http://localhost:9091
+	URL string
+	Job string
+	// 分组标签，如：{instance = "10.0.0.1"}
+	Grouping map[string]string
+	// 均不为空时使用basic auth
+	Username string
+	Password string
+}
+
+// 推送到Prometheus Pushgateway，每次推送替换同一分组下的全部指标
+type PushgatewayExporter struct {
+	conf *PushgatewayConf
+}
+
+func NewPushgatewayExporter(cfg *PushgatewayConf) (*PushgatewayExporter, error) {
+	if cfg.URL == "" || cfg.Job == "" {
+		return nil, errors.New("pushgateway url and job cannot be empty")
+	}
+	return &PushgatewayExporter{conf: cfg}, nil
+}
+
+func (e *PushgatewayExporter) Name() string {
+	return "pushgateway"
+}
+
+func (e *PushgatewayExporter) Export(ctx context.Context, mfs []*dto.MetricFamily) error {
+	p := push.New(e.conf.URL, e.conf.Job).
+		Client(&ctxDoer{ctx: ctx}).
+		Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			return mfs, nil
+		}))
+
+	// 按标签名排序，保证推送的url稳定
+	names := make([]string, 0, len(e.conf.Grouping))
+	for name := range e.conf.Grouping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p = p.Grouping(name, e.conf.Grouping[name])
+	}
+	if e.conf.Username != "" && e.conf.Password != "" {
+		p = p.BasicAuth(e.conf.Username, e.conf.Password)
+	}
+
+	return p.Push()
+}
+
+func (e *PushgatewayExporter) Close() error {
+	return nil
+}
+
+// push包不支持传入ctx，通过HTTPDoer为请求附加ctx
+type ctxDoer struct {
+	ctx context.Context
+}
+
+func (d *ctxDoer) Do(req *http.Request) (*http.Response, error) {
+	return http.DefaultClient.Do(req.WithContext(d.ctx))
+}
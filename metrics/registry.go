@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 指标注册表，其中的指标共享namespace及const labels，
+// 不使用prometheus的全局注册表，可重复创建
+type Registry struct {
+	reg         *prometheus.Registry
+	namespace   string
+	constLabels prometheus.Labels
+
+	http    *httpMetrics
+	handler http.Handler
+}
+
+func NewRegistry(cfg *MetricsConf) (*Registry, error) {
+	r := &Registry{
+		reg:         prometheus.NewRegistry(),
+		namespace:   cfg.Namespace,
+		constLabels: prometheus.Labels(cfg.ConstLabels),
+	}
+
+	if cfg.GoCollector {
+		if err := r.reg.Register(prometheus.NewGoCollector()); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ProcessCollector {
+		if err := r.reg.Register(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{
+			Namespace: cfg.Namespace,
+		})); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.initHTTP(cfg); err != nil {
+		return nil, err
+	}
+	if err := r.Register(logDropped(r)); err != nil {
+		return nil, err
+	}
+	r.handler = promhttp.HandlerFor(r.reg, promhttp.HandlerOpts{})
+
+	return r, nil
+}
+
+func (r *Registry) Namespace() string {
+	return r.namespace
+}
+
+// 创建自定义Collector时使用，与其他指标共享namespace及const labels
+func (r *Registry) NewDesc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(r.namespace, "", name), help, labels, r.constLabels)
+}
+
+func (r *Registry) Register(c prometheus.Collector) error {
+	return r.reg.Register(c)
+}
+
+// 已注册同名同标签的指标时返回已有的指标，其他注册失败的情况panic
+func (r *Registry) mustRegister(c prometheus.Collector) prometheus.Collector {
+	if err := r.reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+func (r *Registry) Counter(name, help string, labels ...string) *prometheus.CounterVec {
+	return r.mustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   r.namespace,
+		Name:        name,
+		Help:        help,
+		ConstLabels: r.constLabels,
+	}, labels)).(*prometheus.CounterVec)
+}
+
+func (r *Registry) Gauge(name, help string, labels ...string) *prometheus.GaugeVec {
+	return r.mustRegister(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   r.namespace,
+		Name:        name,
+		Help:        help,
+		ConstLabels: r.constLabels,
+	}, labels)).(*prometheus.GaugeVec)
+}
+
+// buckets为空时使用DefaultBuckets
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return r.mustRegister(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   r.namespace,
+		Name:        name,
+		Help:        help,
+		Buckets:     buckets,
+		ConstLabels: r.constLabels,
+	}, labels)).(*prometheus.HistogramVec)
+}
+
+// objectives为空时使用DefaultObjectives
+func (r *Registry) Summary(name, help string, objectives map[float64]float64, labels ...string) *prometheus.SummaryVec {
+	if len(objectives) == 0 {
+		objectives = DefaultObjectives
+	}
+	return r.mustRegister(prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Namespace:   r.namespace,
+		Name:        name,
+		Help:        help,
+		Objectives:  objectives,
+		ConstLabels: r.constLabels,
+	}, labels)).(*prometheus.SummaryVec)
+}
+
+func (r *Registry) Gatherer() prometheus.Gatherer {
+	return r.reg
+}
+
+func (r *Registry) Handler() http.Handler {
+	return r.handler
+}
@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// 以太网MTU下不分片的最大udp负载
+	defStatsdPacketSize = 1432
+)
+
+type StatsdConf struct {
+	// udp地址，如：127.0.0.1:8125
+	Addr string
+	// 指标名前缀
+	Prefix string
+	// 为true时按DogStatsD格式以tag携带标签，否则将标签值拼接到指标名中
+	Dogstatsd bool
+	// 单个udp包的最大长度，默认1432
+	MaxPacketSize int
+}
+
+// 通过udp推送到StatsD/DogStatsD，counter及histogram、summary的count/sum按差值上报，
+// gauge及summary的分位数按当前值上报
+type StatsdExporter struct {
+	conf *StatsdConf
+	conn net.Conn
+
+	// 上次推送时counter的值，由Pusher保证串行调用
+	last map[string]float64
+}
+
+func NewStatsdExporter(cfg *StatsdConf) (*StatsdExporter, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("statsd addr cannot be empty")
+	}
+	conn, err := net.Dial("udp", cfg.Addr)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("net.Dial failed, addr:%s", cfg.Addr))
+	}
+
+	return &StatsdExporter{
+		conf: cfg,
+		conn: conn,
+		last: make(map[string]float64),
+	}, nil
+}
+
+func (e *StatsdExporter) Name() string {
+	return "statsd"
+}
+
+func (e *StatsdExporter) Export(ctx context.Context, mfs []*dto.MetricFamily) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = e.conn.SetWriteDeadline(deadline)
+	}
+
+	size := e.conf.MaxPacketSize
+	if size <= 0 {
+		size = defStatsdPacketSize
+	}
+	var buf bytes.Buffer
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		_, err := e.conn.Write(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+
+	for _, line := range e.lines(mfs) {
+		if buf.Len() > 0 && buf.Len()+1+len(line) > size {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	return flush()
+}
+
+func (e *StatsdExporter) Close() error {
+	return e.conn.Close()
+}
+
+func (e *StatsdExporter) lines(mfs []*dto.MetricFamily) []string {
+	var lines []string
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				lines = e.appendCount(lines, name, m.Label, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				lines = append(lines, e.line(name, m.Label, m.GetGauge().GetValue(), "g"))
+			case dto.MetricType_UNTYPED:
+				lines = append(lines, e.line(name, m.Label, m.GetUntyped().GetValue(), "g"))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				lines = e.appendCount(lines, name+"_count", m.Label, float64(h.GetSampleCount()))
+				lines = e.appendCount(lines, name+"_sum", m.Label, h.GetSampleSum())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				lines = e.appendCount(lines, name+"_count", m.Label, float64(s.GetSampleCount()))
+				lines = e.appendCount(lines, name+"_sum", m.Label, s.GetSampleSum())
+				for _, q := range s.Quantile {
+					if math.IsNaN(q.GetValue()) {
+						continue
+					}
+					labels := append(m.Label[:len(m.Label):len(m.Label)], &dto.LabelPair{
+						Name:  stringPtr("quantile"),
+						Value: stringPtr(strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)),
+					})
+					lines = append(lines, e.line(name, labels, q.GetValue(), "g"))
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// 累计值转换为与上次推送的差值，进程重启等导致值变小时按当前值上报
+func (e *StatsdExporter) appendCount(lines []string, name string, labels []*dto.LabelPair, value float64) []string {
+	key := seriesKey(name, labels)
+	delta := value - e.last[key]
+	if delta < 0 {
+		delta = value
+	}
+	e.last[key] = value
+	if delta == 0 {
+		return lines
+	}
+	return append(lines, e.line(name, labels, delta, "c"))
+}
+
+func (e *StatsdExporter) line(name string, labels []*dto.LabelPair, value float64, typ string) string {
+	var b strings.Builder
+	if e.conf.Prefix != "" {
+		b.WriteString(e.conf.Prefix)
+		b.WriteByte('.')
+	}
+	b.WriteString(name)
+	if !e.conf.Dogstatsd {
+		for _, lp := range labels {
+			b.WriteByte('.')
+			b.WriteString(statsdNameReplacer.Replace(lp.GetValue()))
+		}
+	}
+	b.WriteByte(':')
+	b.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	b.WriteByte('|')
+	b.WriteString(typ)
+	if e.conf.Dogstatsd && len(labels) > 0 {
+		b.WriteString("|#")
+		for i, lp := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(lp.GetName())
+			b.WriteByte(':')
+			b.WriteString(statsdTagReplacer.Replace(lp.GetValue()))
+		}
+	}
+	return b.String()
+}
+
+var (
+	// 拼接到指标名中的标签值
+	statsdNameReplacer = strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_", " ", "_")
+	// DogStatsD的tag
+	statsdTagReplacer = strings.NewReplacer("|", "_", "#", "_", ",", "_", "\n", "_")
+)
+
+func seriesKey(name string, labels []*dto.LabelPair) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, lp := range labels {
+		b.WriteByte(0xff)
+		b.WriteString(lp.GetName())
+		b.WriteByte(0xfe)
+		b.WriteString(lp.GetValue())
+	}
+	return b.String()
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
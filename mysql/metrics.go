@@ -0,0 +1,179 @@
+package mysql
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/kaimixu/motor/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	_metricsMutex sync.RWMutex
+	_metrics      *mysqlMetrics
+)
+
+// mysql的指标定义
+var (
+	QueryDuration = metrics.Definition{
+		Name:   "mysql_query_duration_ms",
+		Help:   "mysql query duration(ms)",
+		Type:   metrics.TypeHistogram,
+		Labels: []string{"db", "table", "op"},
+	}
+	QueryErrors = metrics.Definition{
+		Name:   "mysql_query_errors_total",
+		Help:   "mysql query errors count",
+		Type:   metrics.TypeCounter,
+		Labels: []string{"db", "table", "op"},
+	}
+	PoolOpen = metrics.Definition{
+		Name:   "mysql_pool_open_connections",
+		Help:   "mysql open connections",
+		Type:   metrics.TypeGauge,
+		Labels: []string{"db", "role"},
+	}
+	PoolInUse = metrics.Definition{
+		Name:   "mysql_pool_in_use_connections",
+		Help:   "mysql connections in use",
+		Type:   metrics.TypeGauge,
+		Labels: []string{"db", "role"},
+	}
+	PoolIdle = metrics.Definition{
+		Name:   "mysql_pool_idle_connections",
+		Help:   "mysql idle connections",
+		Type:   metrics.TypeGauge,
+		Labels: []string{"db", "role"},
+	}
+	PoolMaxOpen = metrics.Definition{
+		Name:   "mysql_pool_max_open_connections",
+		Help:   "mysql max open connections, 0 means unlimited",
+		Type:   metrics.TypeGauge,
+		Labels: []string{"db", "role"},
+	}
+	PoolWait = metrics.Definition{
+		Name:   "mysql_pool_wait_total",
+		Help:   "mysql connections waited for",
+		Type:   metrics.TypeCounter,
+		Labels: []string{"db", "role"},
+	}
+	PoolWaitSeconds = metrics.Definition{
+		Name:   "mysql_pool_wait_seconds_total",
+		Help:   "mysql time blocked waiting for connections",
+		Type:   metrics.TypeCounter,
+		Labels: []string{"db", "role"},
+	}
+)
+
+func MetricDefinitions() []metrics.Definition {
+	return []metrics.Definition{QueryDuration, QueryErrors, PoolOpen, PoolInUse, PoolIdle, PoolMaxOpen, PoolWait, PoolWaitSeconds}
+}
+
+type mysqlMetrics struct {
+	dur    *prometheus.HistogramVec
+	errCnt *prometheus.CounterVec
+}
+
+// 注册mysql的请求耗时、错误数及连接池指标
+func RegisterMetrics(r *metrics.Registry) error {
+	m := &mysqlMetrics{
+		dur:    r.Histogram(QueryDuration.Name, QueryDuration.Help, nil, QueryDuration.Labels...),
+		errCnt: r.Counter(QueryErrors.Name, QueryErrors.Help, QueryErrors.Labels...),
+	}
+	if err := r.Register(newPoolCollector(r)); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+
+	_metricsMutex.Lock()
+	_metrics = m
+	_metricsMutex.Unlock()
+	return nil
+}
+
+func observe(db, table, op string, dur time.Duration, err error) {
+	_metricsMutex.RLock()
+	m := _metrics
+	_metricsMutex.RUnlock()
+	if m == nil {
+		return
+	}
+
+	m.dur.WithLabelValues(db, table, op).Observe(float64(dur) / float64(time.Millisecond))
+	if err != nil {
+		m.errCnt.WithLabelValues(db, table, op).Inc()
+	}
+}
+
+// 采集时读取各库主从连接池的状态
+type poolCollector struct {
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	maxOpen      *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+func newPoolCollector(r *metrics.Registry) *poolCollector {
+	desc := func(d metrics.Definition) *prometheus.Desc {
+		return r.NewDesc(d.Name, d.Help, d.Labels...)
+	}
+	return &poolCollector{
+		open:         desc(PoolOpen),
+		inUse:        desc(PoolInUse),
+		idle:         desc(PoolIdle),
+		maxOpen:      desc(PoolMaxOpen),
+		waitCount:    desc(PoolWait),
+		waitDuration: desc(PoolWaitSeconds),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.maxOpen
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	if _mysqlPool == nil {
+		return
+	}
+
+	for role, m := range map[string]*sync.Map{"master": &_mysqlPool.mMap, "slave": &_mysqlPool.sMap} {
+		val, ok := m.Load(cacheKey)
+		if !ok {
+			continue
+		}
+		for dbname, dbs := range val.(map[string][]*sql.DB) {
+			var stats sql.DBStats
+			var unlimited bool
+			for _, db := range dbs {
+				s := db.Stats()
+				stats.OpenConnections += s.OpenConnections
+				stats.InUse += s.InUse
+				stats.Idle += s.Idle
+				stats.MaxOpenConnections += s.MaxOpenConnections
+				unlimited = unlimited || s.MaxOpenConnections <= 0
+				stats.WaitCount += s.WaitCount
+				stats.WaitDuration += s.WaitDuration
+			}
+			// 任一连接池不限制连接数时，整体视为不限制
+			if unlimited {
+				stats.MaxOpenConnections = 0
+			}
+
+			ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections), dbname, role)
+			ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse), dbname, role)
+			ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle), dbname, role)
+			ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections), dbname, role)
+			ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount), dbname, role)
+			ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), dbname, role)
+		}
+	}
+}
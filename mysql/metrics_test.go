@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kaimixu/motor/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, r *metrics.Registry) string {
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", metrics.DefaultPath, nil))
+	require.Equal(t, 200, w.Code)
+	return w.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	require := require.New(t)
+
+	sqldb, mock, err := sqlmock.New()
+	require.NoError(err)
+	defer sqldb.Close()
+	sqldb.SetMaxOpenConns(10)
+
+	old := _mysqlPool
+	defer func() {
+		_mysqlPool = old
+		_metricsMutex.Lock()
+		_metrics = nil
+		_metricsMutex.Unlock()
+	}()
+	_mysqlPool = &mysqlPool{}
+	_mysqlPool.mMap.Store(cacheKey, map[string][]*sql.DB{"test": {sqldb}})
+
+	r, err := metrics.NewRegistry(&metrics.MetricsConf{Namespace: "motor"})
+	require.NoError(err)
+	require.NoError(RegisterMetrics(r))
+	// 重复注册时使用已注册的指标
+	require.NoError(RegisterMetrics(r))
+
+	db := &DB{DB: sqldb, IsMaster: true, Dbname: "test", Table: "stu"}
+	mock.ExpectExec("UPDATE stu").WillReturnResult(sqlmock.NewResult(0, 2))
+	n, err := db.Update(map[string]interface{}{"id": 1}, map[string]interface{}{"score": 90})
+	require.NoError(err)
+	require.Equal(int64(2), n)
+
+	// LastInsertId出错时同样计为错误
+	mock.ExpectExec("INSERT INTO stu").WillReturnResult(sqlmock.NewErrorResult(errors.New("no insert id")))
+	_, err = db.Insert([]map[string]interface{}{{"name": "a"}})
+	require.Error(err)
+	require.NoError(mock.ExpectationsWereMet())
+
+	body := scrape(t, r)
+	require.Contains(body, `motor_mysql_query_duration_ms_count{db="test",op="Update",table="stu"} 1`)
+	require.Contains(body, `motor_mysql_query_duration_ms_count{db="test",op="Insert",table="stu"} 1`)
+	require.Contains(body, `motor_mysql_query_errors_total{db="test",op="Insert",table="stu"} 1`)
+	require.NotContains(body, `motor_mysql_query_errors_total{db="test",op="Update"`)
+	require.Contains(body, `motor_mysql_pool_max_open_connections{db="test",role="master"} 10`)
+	require.Contains(body, `motor_mysql_pool_open_connections{db="test",role="master"} 1`)
+	require.Contains(body, `motor_mysql_pool_in_use_connections{db="test",role="master"} 0`)
+	require.Contains(body, `motor_mysql_pool_wait_total{db="test",role="master"} 0`)
+	require.NotContains(body, `role="slave"`)
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"math/rand"
@@ -10,6 +11,8 @@ import (
 	"github.com/didi/gendry/manager"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/health"
+	"github.com/kaimixu/motor/log"
 	"github.com/kaimixu/motor/naming"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -83,7 +86,7 @@ type mysqlPool struct {
 // pubenv: 部署环境，仅confLoadMode=ModeNaming有效
 func InitMysql(confLoadMode MysqlConfLoadMode, productLine, idc, pubenv string) {
 	if _mysqlPool != nil {
-		zap.L().Info("cannot be re-iinitialized")
+		log.Named("mysql").Info("cannot be re-iinitialized")
 		return
 	}
 
@@ -110,6 +113,16 @@ func InitMysql(confLoadMode MysqlConfLoadMode, productLine, idc, pubenv string)
 	_mysqlPool = db
 }
 
+// 主从库连通性检查
+func HealthChecker() health.Checker {
+	return health.NewChecker("mysql", func(ctx context.Context) error {
+		if _mysqlPool == nil {
+			return errors.New("mysql uninitialized")
+		}
+		return _mysqlPool.ping(ctx)
+	})
+}
+
 // 连接池释放
 func Close() {
 	if _mysqlPool != nil {
@@ -129,7 +142,7 @@ func (my *mysqlPool) loadConfFromFile() error {
 			var cfg mysqlConf
 
 			if err := conf.Get("mysql.toml").UnmarshalTOML(&cfg); err != nil {
-				zap.L().Error("Get(mysql.toml).UnmarshalTOML failed",
+				log.Named("mysql").Error("Get(mysql.toml).UnmarshalTOML failed",
 					zap.Error(err))
 				continue
 			}
@@ -165,7 +178,7 @@ func (my *mysqlPool) parseFileConf(cfg *mysqlConf) {
 				manager.SetWriteTimeout(time.Duration(cluster.WriteTimeout)*time.Second),
 			).Port(dbconf.Port).Open(true)
 			if err != nil {
-				zap.L().Error("create mysql master db failed",
+				log.Named("mysql").Error("create mysql master db failed",
 					zap.Error(err),
 					zap.Any("dbconf", dbconf))
 				continue
@@ -191,7 +204,7 @@ func (my *mysqlPool) parseFileConf(cfg *mysqlConf) {
 				manager.SetWriteTimeout(time.Duration(cluster.WriteTimeout)*time.Second),
 			).Port(dbconf.Port).Open(true)
 			if err != nil {
-				zap.L().Error("create mysql slave db failed",
+				log.Named("mysql").Error("create mysql slave db failed",
 					zap.Error(err),
 					zap.Any("dbconf", dbconf))
 				continue
@@ -267,7 +280,7 @@ func (my *mysqlPool) parseNamingInstance(ins []*naming.Instance) {
 		var attr mysqlConf
 		err := in.StructuredAttr(&attr)
 		if err != nil {
-			zap.L().Error("invalid instance",
+			log.Named("mysql").Error("invalid instance",
 				zap.Error(err),
 				zap.Any("in", in))
 			continue
@@ -289,7 +302,7 @@ func (my *mysqlPool) parseNamingInstance(ins []*naming.Instance) {
 					manager.SetWriteTimeout(time.Duration(cluster.WriteTimeout)*time.Second),
 				).Port(dbconf.Port).Open(true)
 				if err != nil {
-					zap.L().Error("create mysql master db failed",
+					log.Named("mysql").Error("create mysql master db failed",
 						zap.Error(err),
 						zap.Any("dbconf", dbconf))
 					continue
@@ -315,7 +328,7 @@ func (my *mysqlPool) parseNamingInstance(ins []*naming.Instance) {
 					manager.SetWriteTimeout(time.Duration(cluster.WriteTimeout)*time.Second),
 				).Port(dbconf.Port).Open(true)
 				if err != nil {
-					zap.L().Error("create mysql slave db failed",
+					log.Named("mysql").Error("create mysql slave db failed",
 						zap.Error(err),
 						zap.Any("dbconf", dbconf))
 					continue
@@ -343,7 +356,7 @@ func (my *mysqlPool) close() {
 				for _, db := range dbs {
 					err := db.Close()
 					if err != nil {
-						zap.L().Warn("db.Close failed",
+						log.Named("mysql").Warn("db.Close failed",
 							zap.Error(err),
 							zap.String("dbname", dbname))
 					}
@@ -360,7 +373,7 @@ func (my *mysqlPool) close() {
 				for _, db := range dbs {
 					err := db.Close()
 					if err != nil {
-						zap.L().Warn("db.Close failed",
+						log.Named("mysql").Warn("db.Close failed",
 							zap.Error(err),
 							zap.String("dbname", dbname))
 					}
@@ -370,6 +383,25 @@ func (my *mysqlPool) close() {
 	}
 }
 
+// 依次ping所有主从库
+func (my *mysqlPool) ping(ctx context.Context) error {
+	for role, m := range map[string]*sync.Map{"master": &my.mMap, "slave": &my.sMap} {
+		val, ok := m.Load(cacheKey)
+		if !ok {
+			return errors.New(fmt.Sprintf("mysql %s config uninitialized", role))
+		}
+		for dbname, dbs := range val.(map[string][]*sql.DB) {
+			for _, db := range dbs {
+				if err := db.PingContext(ctx); err != nil {
+					return errors.Wrap(err, fmt.Sprintf("ping %s db(%s) failed", role, dbname))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func (my *mysqlPool) getDB(dbname string, m OpMode) (*sql.DB, error) {
 	if m == READ {
 		val, ok := my.sMap.Load(cacheKey)
@@ -9,6 +9,7 @@ import (
 	"github.com/didi/gendry/scanner"
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/kaimixu/motor/log"
 	"github.com/kaimixu/motor/trace"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
@@ -34,7 +35,7 @@ type DB struct {
 func GetDB(ctx *gin.Context, dbname, table string, m OpMode) *DB {
 	db, err := _mysqlPool.getDB(dbname, m)
 	if err != nil {
-		zap.L().Error("GetDB failed",
+		log.Named("mysql").With(ginFields(ctx)...).Error("GetDB failed",
 			zap.String("dbname", dbname),
 			zap.Any("opmode", m),
 			zap.Error(err))
@@ -65,7 +66,7 @@ func (db *DB) GetList(where map[string]interface{}, selectFields []string, resul
 			fmt.Sprintf("builder.BuildSelect failed, table:%s, where:%v, selectFields:%v", db.Table, where, selectFields))
 	}
 	now := time.Now()
-	defer slowLog(fmt.Sprintf("cond(%s) args(%+v)", cond, vals), now)
+	defer db.finish("GetList", fmt.Sprintf("cond(%s) args(%+v)", cond, vals), now, &err)
 
 	rows, err := db.Query(cond, vals...)
 	if err != nil {
@@ -108,7 +109,7 @@ func (db *DB) Insert(data []map[string]interface{}) (int64, error) {
 			fmt.Sprintf("builder.BuildInsert failed, table:%s, data:%v", db.Table, data))
 	}
 	now := time.Now()
-	defer slowLog(fmt.Sprintf("cond(%s) args(%+v)", cond, vals), now)
+	defer db.finish("Insert", fmt.Sprintf("cond(%s) args(%+v)", cond, vals), now, &err)
 
 	result, err := db.Exec(cond, vals...)
 	if err != nil {
@@ -116,7 +117,12 @@ func (db *DB) Insert(data []map[string]interface{}) (int64, error) {
 			fmt.Sprintf("db.Exec failed, cond:%s, vals:%v", cond, vals))
 	}
 
-	return result.LastInsertId()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("result.LastInsertId failed, table:%s", db.Table))
+	}
+
+	return id, nil
 }
 
 func (db *DB) Update(where map[string]interface{}, update map[string]interface{}) (int64, error) {
@@ -134,7 +140,7 @@ func (db *DB) Update(where map[string]interface{}, update map[string]interface{}
 			fmt.Sprintf("builder.BuildUpdate failed, table:%s, where:%v, update:%v", db.Table, where, update))
 	}
 	now := time.Now()
-	defer slowLog(fmt.Sprintf("cond(%s) args(%+v)", cond, vals), now)
+	defer db.finish("Update", fmt.Sprintf("cond(%s) args(%+v)", cond, vals), now, &err)
 
 	result, err := db.Exec(cond, vals...)
 	if nil != err {
@@ -142,7 +148,12 @@ func (db *DB) Update(where map[string]interface{}, update map[string]interface{}
 			fmt.Sprintf("db.Exec failed, table:%s, cond:%v, vals:%v", db.Table, cond, vals))
 	}
 
-	return result.RowsAffected()
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("result.RowsAffected failed, table:%s", db.Table))
+	}
+
+	return n, nil
 }
 
 func (db *DB) NamedQuery(query string, data map[string]interface{}, result interface{}) error {
@@ -160,7 +171,7 @@ func (db *DB) NamedQuery(query string, data map[string]interface{}, result inter
 			fmt.Sprintf("builder.NamedQuery failed, table:%s, query:%v, data:%v", db.Table, query, data))
 	}
 	now := time.Now()
-	defer slowLog(fmt.Sprintf("cond(%s) args(%+v)", cond, vals), now)
+	defer db.finish("NamedQuery", fmt.Sprintf("cond(%s) args(%+v)", cond, vals), now, &err)
 
 	rows, err := db.Query(cond, vals...)
 	if err != nil {
@@ -179,10 +190,25 @@ func (db *DB) NamedQuery(query string, data map[string]interface{}, result inter
 	return nil
 }
 
-func slowLog(statement string, now time.Time) {
+// 携带请求trace_id、request_id等字段的logger
+func (db *DB) logger() *zap.Logger {
+	return log.Named("mysql").With(ginFields(db.ctx)...)
+}
+
+// gin请求ctx中的trace_id、request_id等字段
+func ginFields(c *gin.Context) []zap.Field {
+	if c == nil || c.Request == nil {
+		return nil
+	}
+	return log.Fields(c.Request.Context())
+}
+
+// 记录耗时统计及慢日志
+func (db *DB) finish(op, statement string, now time.Time, err *error) {
 	dur := time.Since(now)
+	observe(db.Dbname, db.Table, op, dur, *err)
 	if dur > SlowLogDur {
-		zap.L().Warn("slow log",
+		db.logger().Warn("slow log",
 			zap.String("sqlinfo", statement),
 			zap.Duration("dur", dur))
 	}
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/health"
+	"github.com/kaimixu/motor/log"
 	"github.com/pkg/errors"
 	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/mvcc/mvccpb"
@@ -102,7 +104,7 @@ func Build() Builder {
 func create() *EtcdBuilder {
 	econf, err := getConf()
 	if err != nil {
-		zap.L().Error(fmt.Sprintf("%+v", err))
+		log.Named("naming").Error(fmt.Sprintf("%+v", err))
 		return nil
 	}
 
@@ -122,7 +124,7 @@ func create() *EtcdBuilder {
 		}
 		tlsconf, err := tlsInfo.ClientConfig()
 		if err != nil {
-			zap.L().Error(fmt.Sprintf("%+v", err))
+			log.Named("naming").Error(fmt.Sprintf("%+v", err))
 			return nil
 		}
 		c.TLS = tlsconf
@@ -130,7 +132,7 @@ func create() *EtcdBuilder {
 
 	client, err := clientv3.New(c)
 	if err != nil {
-		zap.L().Error(fmt.Sprintf("clientv3.New failed, err:%+v", err))
+		log.Named("naming").Error(fmt.Sprintf("clientv3.New failed, err:%+v", err))
 		return nil
 	}
 
@@ -200,7 +202,7 @@ func (e *EtcdBuilder) Register(in *Instance) (cancelFunc context.CancelFunc, err
 		delete(e.registry, in.Name)
 		e.rmutex.Unlock()
 		cancel()
-		zap.L().Error(fmt.Sprintf("%+v", err))
+		log.Named("naming").Error(fmt.Sprintf("%+v", err))
 		return
 	}
 	ch := make(chan struct{}, 1)
@@ -217,10 +219,14 @@ func (e *EtcdBuilder) Register(in *Instance) (cancelFunc context.CancelFunc, err
 			case <-ticker.C:
 				err := e.registerLease(ctx, in)
 				if err != nil {
-					zap.L().Error(fmt.Sprintf("%+v", err))
+					log.Named("naming").Error(fmt.Sprintf("%+v", err))
 				}
 			case <-ctx.Done():
 				_ = e.unregister(in)
+				// 注销后允许重新注册
+				e.rmutex.Lock()
+				delete(e.registry, in.Name)
+				e.rmutex.Unlock()
 				ch <- struct{}{}
 				return
 			}
@@ -237,11 +243,13 @@ func (e *EtcdBuilder) registerLease(ctx context.Context, in *Instance) (err erro
 
 	ttlResp, err := e.client.Grant(context.TODO(), e.conf.LeaseTTL)
 	if err != nil {
+		incError("grant")
 		return errors.Wrap(err, fmt.Sprintf("client.Grant failed, ins:%+v", in))
 	}
 
 	_, err = e.client.Put(ctx, key, string(val), clientv3.WithLease(ttlResp.ID))
 	if err != nil {
+		incError("put")
 		return errors.Wrap(err, fmt.Sprintf("client.Put failed, key:%s, in:%+v", key, in))
 	}
 
@@ -252,13 +260,14 @@ func (e *EtcdBuilder) registerLease(ctx context.Context, in *Instance) (err erro
 func (e *EtcdBuilder) unregister(ins *Instance) (err error) {
 	key := e.key(ins.Name, ins.Idc, ins.PubEnv)
 	if _, err = e.client.Delete(context.TODO(), key); err != nil {
-		zap.L().Error(fmt.Sprintf("client.Delete failed, err:%+v", err),
+		incError("delete")
+		log.Named("naming").Error(fmt.Sprintf("client.Delete failed, err:%+v", err),
 			zap.String("key", key),
 			zap.Any("ins", ins))
 		return
 	}
 
-	zap.L().Info("client.Delete success", zap.String("key", key), zap.Any("ins", ins))
+	log.Named("naming").Info("client.Delete success", zap.String("key", key), zap.Any("ins", ins))
 	return
 }
 
@@ -277,6 +286,18 @@ func (e *EtcdBuilder) key(args ...string) string {
 	return strings.TrimRight(buf.String(), "/")
 }
 
+// etcd连接检查，仅在名字服务已初始化时可用
+func HealthChecker() health.Checker {
+	return health.NewChecker("naming", func(ctx context.Context) error {
+		e, ok := _builder.(*EtcdBuilder)
+		if !ok || e == nil {
+			return errors.New("naming uninitialized")
+		}
+		_, err := e.client.Get(ctx, e.key(), clientv3.WithPrefix(), clientv3.WithCountOnly())
+		return err
+	})
+}
+
 func (e *EtcdBuilder) Close() {
 	e.cancelFunc()
 	e.client.Close()
@@ -297,12 +318,13 @@ func (srv *serverInfo) watch() {
 func (srv *serverInfo) getstore(typ string) error {
 	resp, err := srv.e.client.Get(srv.e.ctx, srv.e.key(srv.sn), clientv3.WithPrefix())
 	if err != nil {
-		zap.L().Error(fmt.Sprintf("client.Get failed, err:%+v", err), zap.String("sn", srv.sn))
+		incError("get")
+		log.Named("naming").Error(fmt.Sprintf("client.Get failed, err:%+v", err), zap.String("sn", srv.sn))
 		return err
 	}
 	// 首次get时服务可能还未注册，此情况下不唤醒resolver
 	if typ == "get" && len(resp.Kvs) == 0 {
-		zap.L().Info("naming.getstore: client.get return null",
+		log.Named("naming").Info("naming.getstore: client.get return null",
 			zap.String("typ", typ),
 			zap.String("sn", srv.sn))
 		return nil
@@ -310,7 +332,7 @@ func (srv *serverInfo) getstore(typ string) error {
 
 	ins, err := srv.parseIns(resp)
 	if err != nil {
-		zap.L().Error(fmt.Sprintf("parseIns failed, err:%+v", err), zap.String("sn", srv.sn))
+		log.Named("naming").Error(fmt.Sprintf("parseIns failed, err:%+v", err), zap.String("sn", srv.sn))
 		return err
 	}
 
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/kaimixu/motor/log"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 )
 
 type InstancesInfo struct {
@@ -53,6 +55,35 @@ func (in *Instance) StructuredAttr(s interface{}) error {
 	return json.Unmarshal([]byte(attrStr), s)
 }
 
+// 按机房、部署环境过滤实例，返回去重后的DefaultInstanceAttr.Addrs，idc、pubenv为空时不过滤
+func InstanceAddrs(ins []*Instance, idc, pubenv string) []string {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, in := range ins {
+		if idc != "" && in.Idc != idc {
+			continue
+		}
+		if pubenv != "" && in.PubEnv != pubenv {
+			continue
+		}
+
+		var attr DefaultInstanceAttr
+		if err := in.StructuredAttr(&attr); err != nil {
+			log.Named("naming").Error("invalid instance",
+				zap.Error(err),
+				zap.Any("in", in))
+			continue
+		}
+		for _, addr := range attr.Addrs {
+			if addr != "" && !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
 type Builder interface {
 	Discovery(sn string) (Resolver, error)
 	Register(ins *Instance) (cancelFunc context.CancelFunc, err error)
@@ -0,0 +1,77 @@
+package naming
+
+import (
+	"sync"
+
+	"github.com/kaimixu/motor/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	_metricsMutex sync.RWMutex
+	_metrics      *namingMetrics
+)
+
+type namingMetrics struct {
+	errCnt *prometheus.CounterVec
+}
+
+// 注册名字服务的etcd错误数及各服务实例数指标
+func RegisterMetrics(r *metrics.Registry) error {
+	m := &namingMetrics{
+		errCnt: r.Counter("naming_etcd_errors_total", "naming etcd request errors count", "op"),
+	}
+	if err := r.Register(newInstanceCollector(r)); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+
+	_metricsMutex.Lock()
+	_metrics = m
+	_metricsMutex.Unlock()
+	return nil
+}
+
+func incError(op string) {
+	_metricsMutex.RLock()
+	m := _metrics
+	_metricsMutex.RUnlock()
+	if m == nil {
+		return
+	}
+
+	m.errCnt.WithLabelValues(op).Inc()
+}
+
+// 采集时读取已发现服务的实例数
+type instanceCollector struct {
+	instances *prometheus.Desc
+}
+
+func newInstanceCollector(r *metrics.Registry) *instanceCollector {
+	return &instanceCollector{
+		instances: r.NewDesc("naming_instances", "naming discovered instances", "service"),
+	}
+}
+
+func (c *instanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.instances
+}
+
+func (c *instanceCollector) Collect(ch chan<- prometheus.Metric) {
+	e, ok := _builder.(*EtcdBuilder)
+	if !ok || e == nil {
+		return
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	for sn, srv := range e.servers {
+		var count int
+		if insI, ok := srv.ins.Load().(*InstancesInfo); ok {
+			count = len(insI.Instances[sn])
+		}
+		ch <- prometheus.MustNewConstMetric(c.instances, prometheus.GaugeValue, float64(count), sn)
+	}
+}
@@ -0,0 +1,50 @@
+package naming
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaimixu/motor/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, r *metrics.Registry) string {
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", metrics.DefaultPath, nil))
+	require.Equal(t, 200, w.Code)
+	return w.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	require := require.New(t)
+
+	user := &serverInfo{sn: "user"}
+	user.ins.Store(&InstancesInfo{Instances: map[string][]*Instance{
+		"user": {{Name: "user"}, {Name: "user"}},
+	}})
+	old := _builder
+	defer func() {
+		_builder = old
+		_metricsMutex.Lock()
+		_metrics = nil
+		_metricsMutex.Unlock()
+	}()
+	// 尚未加载实例的服务计为0
+	_builder = &EtcdBuilder{servers: map[string]*serverInfo{"user": user, "order": {sn: "order"}}}
+
+	r, err := metrics.NewRegistry(&metrics.MetricsConf{Namespace: "motor"})
+	require.NoError(err)
+	require.NoError(RegisterMetrics(r))
+	// 重复注册时使用已注册的指标
+	require.NoError(RegisterMetrics(r))
+
+	incError("get")
+	incError("get")
+	incError("put")
+
+	body := scrape(t, r)
+	require.Contains(body, `motor_naming_etcd_errors_total{op="get"} 2`)
+	require.Contains(body, `motor_naming_etcd_errors_total{op="put"} 1`)
+	require.Contains(body, `motor_naming_instances{service="user"} 2`)
+	require.Contains(body, `motor_naming_instances{service="order"} 0`)
+}
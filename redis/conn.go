@@ -1,7 +1,11 @@
 package redis
 
 import (
+	"context"
+	"time"
+
 	"github.com/gomodule/redigo/redis"
+	"github.com/kaimixu/motor/log"
 	"go.uber.org/zap"
 )
 
@@ -16,12 +20,18 @@ type RedisConn struct {
 	redis.Conn
 	IsMaster    bool
 	clusterName string
+	ctx         context.Context
 }
 
 func GetConn(clusterName string, m OpMode) *RedisConn {
+	return GetConnContext(context.Background(), clusterName, m)
+}
+
+// 获取连接，ctx中的trace_id、request_id等会记录到日志中
+func GetConnContext(ctx context.Context, clusterName string, m OpMode) *RedisConn {
 	conn, err := _redisPool.getConn(clusterName, m)
 	if err != nil {
-		zap.L().Error("getConn failed",
+		log.Named("redis").With(log.Fields(ctx)...).Error("getConn failed",
 			zap.String("clusterName", clusterName),
 			zap.Any("opmode", m),
 			zap.Error(err))
@@ -32,5 +42,21 @@ func GetConn(clusterName string, m OpMode) *RedisConn {
 		Conn:        conn,
 		IsMaster:    m == WRITE,
 		clusterName: clusterName,
+		ctx:         ctx,
+	}
+}
+
+// 执行命令并记录耗时指标，失败时记录携带ctx中trace_id、request_id等字段的日志
+func (rc *RedisConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	now := time.Now()
+	reply, err := rc.Conn.Do(commandName, args...)
+	observe(rc.clusterName, commandName, time.Since(now), err)
+	if err != nil && err != redis.ErrNil {
+		log.Named("redis").With(log.Fields(rc.ctx)...).Warn("redis command failed",
+			zap.String("clusterName", rc.clusterName),
+			zap.String("command", commandName),
+			zap.Error(err))
 	}
+
+	return reply, err
 }
@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/kaimixu/motor/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	_metricsMutex sync.RWMutex
+	_metrics      *redisMetrics
+)
+
+// redis的指标定义
+var (
+	CommandDuration = metrics.Definition{
+		Name:   "redis_command_duration_ms",
+		Help:   "redis command duration(ms)",
+		Type:   metrics.TypeHistogram,
+		Labels: []string{"cluster", "command"},
+	}
+	CommandErrors = metrics.Definition{
+		Name:   "redis_command_errors_total",
+		Help:   "redis command errors count",
+		Type:   metrics.TypeCounter,
+		Labels: []string{"cluster", "command"},
+	}
+	PoolActive = metrics.Definition{
+		Name:   "redis_pool_active_connections",
+		Help:   "redis active connections",
+		Type:   metrics.TypeGauge,
+		Labels: []string{"cluster", "role"},
+	}
+	PoolIdle = metrics.Definition{
+		Name:   "redis_pool_idle_connections",
+		Help:   "redis idle connections",
+		Type:   metrics.TypeGauge,
+		Labels: []string{"cluster", "role"},
+	}
+	PoolMaxActive = metrics.Definition{
+		Name:   "redis_pool_max_active_connections",
+		Help:   "redis max active connections, 0 means unlimited",
+		Type:   metrics.TypeGauge,
+		Labels: []string{"cluster", "role"},
+	}
+)
+
+func MetricDefinitions() []metrics.Definition {
+	return []metrics.Definition{CommandDuration, CommandErrors, PoolActive, PoolIdle, PoolMaxActive}
+}
+
+type redisMetrics struct {
+	dur    *prometheus.HistogramVec
+	errCnt *prometheus.CounterVec
+}
+
+// 注册redis的命令耗时、错误数及连接池指标
+func RegisterMetrics(r *metrics.Registry) error {
+	m := &redisMetrics{
+		dur:    r.Histogram(CommandDuration.Name, CommandDuration.Help, nil, CommandDuration.Labels...),
+		errCnt: r.Counter(CommandErrors.Name, CommandErrors.Help, CommandErrors.Labels...),
+	}
+	if err := r.Register(newPoolCollector(r)); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+
+	_metricsMutex.Lock()
+	_metrics = m
+	_metricsMutex.Unlock()
+	return nil
+}
+
+func observe(cluster, command string, dur time.Duration, err error) {
+	_metricsMutex.RLock()
+	m := _metrics
+	_metricsMutex.RUnlock()
+	if m == nil {
+		return
+	}
+
+	m.dur.WithLabelValues(cluster, command).Observe(float64(dur) / float64(time.Millisecond))
+	if err != nil && err != redis.ErrNil {
+		m.errCnt.WithLabelValues(cluster, command).Inc()
+	}
+}
+
+// 采集时读取各集群主从连接池的状态
+type poolCollector struct {
+	active    *prometheus.Desc
+	idle      *prometheus.Desc
+	maxActive *prometheus.Desc
+}
+
+func newPoolCollector(r *metrics.Registry) *poolCollector {
+	desc := func(d metrics.Definition) *prometheus.Desc {
+		return r.NewDesc(d.Name, d.Help, d.Labels...)
+	}
+	return &poolCollector{
+		active:    desc(PoolActive),
+		idle:      desc(PoolIdle),
+		maxActive: desc(PoolMaxActive),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.active
+	ch <- c.idle
+	ch <- c.maxActive
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	if _redisPool == nil {
+		return
+	}
+
+	for role, m := range map[string]*sync.Map{"master": &_redisPool.mMap, "slave": &_redisPool.sMap} {
+		val, ok := m.Load(cacheKey)
+		if !ok {
+			continue
+		}
+		for cluster, pools := range val.(map[string][]*redis.Pool) {
+			var stats redis.PoolStats
+			var maxActive int
+			var unlimited bool
+			for _, pool := range pools {
+				s := pool.Stats()
+				stats.ActiveCount += s.ActiveCount
+				stats.IdleCount += s.IdleCount
+				maxActive += pool.MaxActive
+				unlimited = unlimited || pool.MaxActive <= 0
+			}
+			// 任一连接池不限制连接数时，整体视为不限制
+			if unlimited {
+				maxActive = 0
+			}
+
+			ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(stats.ActiveCount), cluster, role)
+			ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.IdleCount), cluster, role)
+			ch <- prometheus.MustNewConstMetric(c.maxActive, prometheus.GaugeValue, float64(maxActive), cluster, role)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/kaimixu/motor/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// 按命令返回结果，不连接redis
+type fakeConn struct{}
+
+func (fakeConn) Close() error { return nil }
+func (fakeConn) Err() error   { return nil }
+func (fakeConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	switch commandName {
+	case "GET":
+		return nil, redis.ErrNil
+	case "INCR":
+		return nil, errors.New("ERR value is not an integer")
+	}
+	return "OK", nil
+}
+func (fakeConn) Send(commandName string, args ...interface{}) error { return nil }
+func (fakeConn) Flush() error                                       { return nil }
+func (fakeConn) Receive() (interface{}, error)                      { return nil, nil }
+
+func scrape(t *testing.T, r *metrics.Registry) string {
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", metrics.DefaultPath, nil))
+	require.Equal(t, 200, w.Code)
+	return w.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	require := require.New(t)
+
+	dial := func() (redis.Conn, error) {
+		return fakeConn{}, nil
+	}
+	master := &redis.Pool{MaxActive: 5, Dial: dial}
+	defer master.Close()
+	slave := &redis.Pool{Dial: dial}
+	defer slave.Close()
+
+	old := _redisPool
+	defer func() {
+		_redisPool = old
+		_metricsMutex.Lock()
+		_metrics = nil
+		_metricsMutex.Unlock()
+	}()
+	_redisPool = &redisPool{}
+	_redisPool.mMap.Store(cacheKey, map[string][]*redis.Pool{"cache": {master}})
+	_redisPool.sMap.Store(cacheKey, map[string][]*redis.Pool{"cache": {slave, master}})
+
+	r, err := metrics.NewRegistry(&metrics.MetricsConf{Namespace: "motor"})
+	require.NoError(err)
+	require.NoError(RegisterMetrics(r))
+	// 重复注册时使用已注册的指标
+	require.NoError(RegisterMetrics(r))
+
+	rc := &RedisConn{Conn: master.Get(), IsMaster: true, clusterName: "cache"}
+	defer rc.Close()
+	_, err = rc.Do("SET", "k", "v")
+	require.NoError(err)
+	// ErrNil不计为错误
+	_, err = rc.Do("GET", "missing")
+	require.Equal(redis.ErrNil, err)
+	_, err = rc.Do("INCR", "k")
+	require.Error(err)
+
+	body := scrape(t, r)
+	require.Contains(body, `motor_redis_command_duration_ms_count{cluster="cache",command="SET"} 1`)
+	require.Contains(body, `motor_redis_command_duration_ms_count{cluster="cache",command="GET"} 1`)
+	require.Contains(body, `motor_redis_command_errors_total{cluster="cache",command="INCR"} 1`)
+	require.NotContains(body, `motor_redis_command_errors_total{cluster="cache",command="GET"}`)
+	require.Contains(body, `motor_redis_pool_active_connections{cluster="cache",role="master"} 1`)
+	require.Contains(body, `motor_redis_pool_max_active_connections{cluster="cache",role="master"} 5`)
+	// 任一连接池不限制时整体视为不限制
+	require.Contains(body, `motor_redis_pool_max_active_connections{cluster="cache",role="slave"} 0`)
+}
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"sync"
@@ -8,6 +9,8 @@ import (
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/health"
+	"github.com/kaimixu/motor/log"
 	"github.com/kaimixu/motor/naming"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -105,8 +108,21 @@ func InitRedis(confLoadMode RedisConfLoadMode, productLine, idc, pubenv string)
 	_redisPool = redi
 }
 
-func Close() {
+// 主从节点连通性检查，超时由health通过ctx控制
+func HealthChecker() health.Checker {
+	return health.NewChecker("redis", func(ctx context.Context) error {
+		if _redisPool == nil {
+			return errors.New("redis uninitialized")
+		}
+		return _redisPool.ping(ctx)
+	})
+}
 
+// 连接池释放
+func Close() {
+	if _redisPool != nil {
+		_redisPool.close()
+	}
 }
 
 func (redi *redisPool) loadConfFromFile() error {
@@ -121,7 +137,7 @@ func (redi *redisPool) loadConfFromFile() error {
 			var cfg redisConf
 
 			if err := conf.Get("redis.toml").UnmarshalTOML(&cfg); err != nil {
-				zap.L().Error("Get(redis.toml).UnmarshalTOML failed",
+				log.Named("redis").Error("Get(redis.toml).UnmarshalTOML failed",
 					zap.Error(err))
 				continue
 			}
@@ -265,7 +281,7 @@ func (redi *redisPool) parseNamingInstance(ins []*naming.Instance) {
 		var attr redisConf
 		err := in.StructuredAttr(&attr)
 		if err != nil {
-			zap.L().Error("invalid instance",
+			log.Named("redis").Error("invalid instance",
 				zap.Error(err),
 				zap.Any("in", in))
 			continue
@@ -346,7 +362,7 @@ func (redi *redisPool) close() {
 				for _, pool := range pools {
 					err := pool.Close()
 					if err != nil {
-						zap.L().Warn("pool.Close failed",
+						log.Named("redis").Warn("pool.Close failed",
 							zap.Error(err),
 							zap.String("clusterName", clusterName))
 					}
@@ -363,7 +379,7 @@ func (redi *redisPool) close() {
 				for _, pool := range pools {
 					err := pool.Close()
 					if err != nil {
-						zap.L().Warn("pool.Close failed",
+						log.Named("redis").Warn("pool.Close failed",
 							zap.Error(err),
 							zap.String("clusterName", clusterName))
 					}
@@ -373,6 +389,46 @@ func (redi *redisPool) close() {
 	}
 }
 
+// 依次对所有主从节点执行PING
+// 获取连接及PING均受ctx的deadline约束
+func (redi *redisPool) ping(ctx context.Context) error {
+	for role, m := range map[string]*sync.Map{"master": &redi.mMap, "slave": &redi.sMap} {
+		val, ok := m.Load(cacheKey)
+		if !ok {
+			return errors.New(fmt.Sprintf("redis %s config uninitialized", role))
+		}
+		for clusterName, pools := range val.(map[string][]*redis.Pool) {
+			for _, pool := range pools {
+				err := pingPool(ctx, pool)
+				if err != nil {
+					return errors.Wrap(err, fmt.Sprintf("ping %s redis(%s) failed", role, clusterName))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func pingPool(ctx context.Context, pool *redis.Pool) error {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+		_, err = redis.DoWithTimeout(conn, timeout, "PING")
+		return err
+	}
+	_, err = conn.Do("PING")
+	return err
+}
+
 func (redi *redisPool) getConn(clusterName string, m OpMode) (redis.Conn, error) {
 	if m == READ {
 		val, ok := redi.sMap.Load(cacheKey)
@@ -1,9 +1,13 @@
 package redis
 
 import (
+	"context"
+	"net"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/gomodule/redigo/redis"
 	"github.com/kaimixu/motor/conf"
 	"github.com/kaimixu/motor/log"
 	"github.com/kaimixu/motor/naming"
@@ -60,3 +64,37 @@ func TestNamingConf(t *testing.T) {
 
 	InitRedis(ModeNaming, "motor_test", "", "test")
 }
+
+func TestPingPoolTimeout(t *testing.T) {
+	require := require.New(t)
+
+	// 接受连接但不响应的redis
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", ln.Addr().String())
+		},
+	}
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.Error(pingPool(ctx, pool))
+	require.True(time.Since(start) < time.Second)
+
+	// ctx已超时
+	require.Equal(context.DeadlineExceeded, pingPool(ctx, pool))
+}
@@ -0,0 +1,240 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/naming"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+)
+
+type ClientConf struct {
+	// 调用的默认超时时间，调用方ctx已设置deadline时不生效，0表示不限制
+	Timeout conf.Duration
+	// 负载均衡策略：round_robin(默认)、pick_first
+	Balancer string
+	// 通过名字服务发现实例时，仅使用指定机房、部署环境的实例
+	Idc    string
+	PubEnv string
+	// 连接空闲多久后发送keepalive ping及等待响应的时间，0表示不发送
+	KeepaliveTime    conf.Duration
+	KeepaliveTimeout conf.Duration
+}
+
+// 从application.toml的[RpcClient]中加载配置
+func LoadClientConf() (*ClientConf, error) {
+	var cfg ClientConf
+	if err := conf.UnmarshalSection("application.toml", "RpcClient", &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// 创建连接，集成了超时、request id、trace及metrics拦截器
+// target为naming:///<服务名>时通过名字服务(naming.Build)发现实例，
+// 也可在opts中通过grpc.WithResolvers(NewResolverBuilder(...))指定其他的naming.Builder
+func Dial(ctx context.Context, target string, cfg *ClientConf, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	balancer := cfg.Balancer
+	if balancer == "" {
+		balancer = roundrobin.Name
+	}
+	dopts := []grpc.DialOption{
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingPolicy":%q}`, balancer)),
+		grpc.WithChainUnaryInterceptor(
+			UnaryClientTimeout(time.Duration(cfg.Timeout)),
+			UnaryClientRequestID(),
+			UnaryClientTrace(),
+			UnaryClientMetrics(),
+		),
+		grpc.WithChainStreamInterceptor(
+			StreamClientRequestID(),
+			StreamClientTrace(),
+			StreamClientMetrics(),
+		),
+	}
+	if cfg.KeepaliveTime > 0 {
+		dopts = append(dopts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Duration(cfg.KeepaliveTime),
+			Timeout:             time.Duration(cfg.KeepaliveTimeout),
+			PermitWithoutStream: true,
+		}))
+	}
+	dopts = append(dopts, opts...)
+	// 位于opts之后，调用方指定的resolver优先
+	dopts = append(dopts, grpc.WithResolvers(&resolverBuilder{
+		builder: naming.Build,
+		idc:     cfg.Idc,
+		pubenv:  cfg.PubEnv,
+	}))
+
+	return grpc.DialContext(ctx, target, dopts...)
+}
+
+// 调用方ctx未设置deadline时使用timeout，timeout为0时不限制
+func UnaryClientTimeout(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// 将ctx中的request id通过x-request-id传递给下游
+func UnaryClientRequestID() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+func StreamClientRequestID() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+	}
+}
+
+// 创建client span并将trace上下文注入到metadata中
+func UnaryClientTrace() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span, ctx := startClientSpan(ctx, method, cc.Target())
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finishSpan(span, err)
+		return err
+	}
+}
+
+func StreamClientTrace() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := startClientSpan(ctx, method, cc.Target())
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finishSpan(span, err)
+			return nil, err
+		}
+		return newClientStream(cs, desc, func(err error) {
+			finishSpan(span, err)
+		}), nil
+	}
+}
+
+// 按方法及状态码统计调用数及耗时，需先调用RegisterMetrics
+func UnaryClientMetrics() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		observeClient(method, toStatus(err).Code().String(), time.Since(start))
+		return err
+	}
+}
+
+func StreamClientMetrics() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			observeClient(method, toStatus(err).Code().String(), time.Since(start))
+			return nil, err
+		}
+		return newClientStream(cs, desc, func(err error) {
+			observeClient(method, toStatus(err).Code().String(), time.Since(start))
+		}), nil
+	}
+}
+
+func outgoingRequestID(ctx context.Context) context.Context {
+	id, ok := log.RequestIDFrom(ctx)
+	if !ok {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get(RequestIDKey)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+}
+
+func startClientSpan(ctx context.Context, method, target string) (opentracing.Span, context.Context) {
+	tracer := opentracing.GlobalTracer()
+	opts := []opentracing.StartSpanOption{ext.SpanKindRPCClient, grpcComponent}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	span := tracer.StartSpan(method, opts...)
+	ext.PeerService.Set(span, target)
+
+	// 不修改调用方ctx中的metadata
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	if err := tracer.Inject(span.Context(), opentracing.TextMap, mdCarrier(md)); err != nil {
+		log.Ctx(ctx).Error("tracer.Inject failed",
+			zap.String("method", method),
+			zap.Error(err))
+	}
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	return span, metadata.NewOutgoingContext(ctx, md)
+}
+
+// 在stream结束时回调finish，正常结束时err为nil
+type clientStream struct {
+	grpc.ClientStream
+	desc   *grpc.StreamDesc
+	finish func(err error)
+	once   sync.Once
+}
+
+func newClientStream(cs grpc.ClientStream, desc *grpc.StreamDesc, finish func(err error)) grpc.ClientStream {
+	return &clientStream{ClientStream: cs, desc: desc, finish: finish}
+}
+
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.done(err)
+	}
+	return md, err
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		s.done(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.done(nil)
+	case err != nil:
+		s.done(err)
+	case !s.desc.ServerStreams:
+		// 服务端非stream时收到响应即结束
+		s.done(nil)
+	}
+	return err
+}
+
+func (s *clientStream) done(err error) {
+	s.once.Do(func() {
+		s.finish(err)
+	})
+}
@@ -0,0 +1,431 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/kaimixu/motor/ecode"
+	"github.com/kaimixu/motor/jwt"
+	"github.com/kaimixu/motor/log"
+	motormd "github.com/kaimixu/motor/metadata"
+	"github.com/kaimixu/motor/tolerant"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// 传递request id的metadata key
+	RequestIDKey = "x-request-id"
+
+	authorizationKey = "authorization"
+	jwtTokenKey      = "jwt-token"
+)
+
+var grpcComponent = opentracing.Tag{Key: string(ext.Component), Value: "grpc"}
+
+type claimsKey struct{}
+
+// 替换Context()的ServerStream，用于向stream handler传递ctx
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// 将handler返回的*ecode.Error等错误转换为grpc status，应置于最外层
+func UnaryStatus() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, toStatus(err).Err()
+		}
+		return resp, nil
+	}
+}
+
+func StreamStatus() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return toStatus(err).Err()
+		}
+		return nil
+	}
+}
+
+// 使用请求携带的x-request-id，没有时生成，并通过响应header返回，log.Ctx会输出到日志
+func UnaryRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := incomingRequestID(ctx)
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+		return handler(log.WithRequestID(ctx, id), req)
+	}
+}
+
+func StreamRequestID() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id := incomingRequestID(ss.Context())
+		ss.SetHeader(metadata.Pairs(RequestIDKey, id))
+		return handler(srv, &serverStream{ServerStream: ss, ctx: log.WithRequestID(ss.Context(), id)})
+	}
+}
+
+// 从metadata中提取上游的trace上下文并创建server span
+func UnaryTrace() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		span, ctx := startServerSpan(ctx, info.FullMethod)
+		defer func() {
+			finishSpan(span, err)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func StreamTrace() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		span, ctx := startServerSpan(ss.Context(), info.FullMethod)
+		defer func() {
+			finishSpan(span, err)
+		}()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// 结构化的accesslog，通过log.Named("access")输出，skipMethods中的方法不记录
+func UnaryLogger(skipMethods ...string) grpc.UnaryServerInterceptor {
+	skip := methodSet(skipMethods)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if _, ok := skip[info.FullMethod]; !ok {
+			logAccess(ctx, info.FullMethod, "unary", start, err)
+		}
+		return resp, err
+	}
+}
+
+func StreamLogger(skipMethods ...string) grpc.StreamServerInterceptor {
+	skip := methodSet(skipMethods)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		if _, ok := skip[info.FullMethod]; !ok {
+			logAccess(ss.Context(), info.FullMethod, "stream", start, err)
+		}
+		return err
+	}
+}
+
+// 按方法及状态码统计调用数及耗时，需先调用RegisterMetrics
+func UnaryMetrics() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeServer(info.FullMethod, toStatus(err).Code().String(), time.Since(start))
+		return resp, err
+	}
+}
+
+func StreamMetrics() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeServer(info.FullMethod, toStatus(err).Code().String(), time.Since(start))
+		return err
+	}
+}
+
+// 捕获handler中的panic，记录堆栈并返回codes.Internal
+func UnaryRecovery() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverError(ctx, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func StreamRecovery() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverError(ss.Context(), info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// 校验metadata中authorization: Bearer <token>或jwt-token携带的token，
+// 解析出的claims可通过ClaimsFromContext获取，skipMethods中的方法不校验
+func UnaryJwt(j *jwt.JWT, skipMethods ...string) grpc.UnaryServerInterceptor {
+	skip := methodSet(skipMethods)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := skip[info.FullMethod]; ok {
+			return handler(ctx, req)
+		}
+		ctx, err := jwtContext(ctx, j)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamJwt(j *jwt.JWT, skipMethods ...string) grpc.StreamServerInterceptor {
+	skip := methodSet(skipMethods)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if _, ok := skip[info.FullMethod]; ok {
+			return handler(srv, ss)
+		}
+		ctx, err := jwtContext(ss.Context(), j)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// 获取Jwt拦截器解析出的claims
+func ClaimsFromContext(ctx context.Context) (*jwt.MotorClaims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*jwt.MotorClaims)
+	return claims, ok
+}
+
+// 按sentinel.toml中的FlowRule限流，需先调用tolerant.Init
+func UnaryRatelimit() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !tolerant.Svc.FlowRule.Enabled {
+			return handler(ctx, req)
+		}
+		e, b := sentinel.Entry(tolerant.Svc.FlowRule.Resource, sentinel.WithTrafficType(base.Inbound))
+		if b != nil {
+			return nil, ecode.TooManyRequests.WithCause(b)
+		}
+		defer e.Exit()
+		return handler(ctx, req)
+	}
+}
+
+func StreamRatelimit() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !tolerant.Svc.FlowRule.Enabled {
+			return handler(srv, ss)
+		}
+		e, b := sentinel.Entry(tolerant.Svc.FlowRule.Resource, sentinel.WithTrafficType(base.Inbound))
+		if b != nil {
+			return ecode.TooManyRequests.WithCause(b)
+		}
+		defer e.Exit()
+		return handler(srv, ss)
+	}
+}
+
+func incomingRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(RequestIDKey); len(ids) > 0 && log.ValidRequestID(ids[0]) {
+		return ids[0]
+	}
+	return log.NewRequestID()
+}
+
+func startServerSpan(ctx context.Context, method string) (opentracing.Span, context.Context) {
+	tracer := opentracing.GlobalTracer()
+	md, _ := metadata.FromIncomingContext(ctx)
+	opts := []opentracing.StartSpanOption{ext.SpanKindRPCServer, grpcComponent}
+	if sc, err := tracer.Extract(opentracing.TextMap, mdCarrier(md)); err == nil {
+		opts = append(opts, opentracing.ChildOf(sc))
+	}
+	span := tracer.StartSpan(method, opts...)
+	if p, ok := peer.FromContext(ctx); ok {
+		ext.PeerAddress.Set(span, p.Addr.String())
+	}
+
+	// 与http的Trace中间件一致，将trace上下文存入metadata
+	carrier := motormd.Metadata{}
+	if err := tracer.Inject(span.Context(), opentracing.TextMap, carrier); err != nil {
+		log.Ctx(ctx).Error("tracer.Inject failed",
+			zap.String("method", method),
+			zap.Error(err))
+	}
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	return span, motormd.NewContext(ctx, carrier)
+}
+
+// 记录状态码，服务端错误标记为error
+func finishSpan(span opentracing.Span, err error) {
+	code := toStatus(err).Code()
+	span.SetTag("grpc.code", code.String())
+	if serverError(code) {
+		ext.Error.Set(span, true)
+		span.LogKV("event", "error", "message", err.Error())
+	}
+	span.Finish()
+}
+
+func serverError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+func logAccess(ctx context.Context, method, typ string, start time.Time, err error) {
+	code := toStatus(err).Code()
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.String("type", typ),
+		zap.String("code", code.String()),
+		zap.Duration("latency", time.Since(start)),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			fields = append(fields, zap.String("userAgent", ua[0]))
+		}
+	}
+	if err != nil {
+		fields = append(fields, zap.String("errmsg", err.Error()))
+	}
+
+	logger := log.Named("access").With(log.Fields(ctx)...)
+	if serverError(code) {
+		logger.Warn("access", fields...)
+	} else {
+		logger.Info("access", fields...)
+	}
+}
+
+func recoverError(ctx context.Context, method string, r interface{}) error {
+	log.Ctx(ctx).Error("catch panic",
+		zap.String("method", method),
+		zap.Any("panic", r),
+		zap.String("stack", string(debug.Stack())))
+	return ecode.ServerErr.WithCause(fmt.Errorf("panic: %v", r))
+}
+
+func jwtContext(ctx context.Context, j *jwt.JWT) (context.Context, error) {
+	token := incomingToken(ctx)
+	if token == "" {
+		return nil, ecode.Unauthorized.WithMessage(jwt.ErrTokenMissing.Error())
+	}
+	claims, err := j.ParseToken(token)
+	if err != nil {
+		e := ecode.Unauthorized.WithMessage("token无效").WithCause(err)
+		if jwt.IsExpired(err) {
+			e = e.WithMessage("token已过期")
+		}
+		return nil, e
+	}
+
+	ctx = context.WithValue(ctx, claimsKey{}, claims)
+	return log.WithSubject(ctx, claims.Subject), nil
+}
+
+func incomingToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if tokens := md.Get(jwtTokenKey); len(tokens) > 0 && tokens[0] != "" {
+		return tokens[0]
+	}
+	if auths := md.Get(authorizationKey); len(auths) > 0 {
+		auth := auths[0]
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			return strings.TrimSpace(auth[7:])
+		}
+	}
+	return ""
+}
+
+// 转换为grpc status，*ecode.Error按http状态码映射
+func toStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+	switch err {
+	case context.Canceled:
+		return status.New(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.New(codes.DeadlineExceeded, err.Error())
+	}
+
+	e := ecode.FromError(err)
+	return status.New(httpStatusCode(e.Status), e.Message)
+}
+
+func httpStatusCode(s int) codes.Code {
+	switch s {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if s >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+func methodSet(methods []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		set[m] = struct{}{}
+	}
+	return set
+}
+
+// grpc metadata作为opentracing的TextMap carrier，key统一为小写，忽略二进制的-bin项
+type mdCarrier metadata.MD
+
+func (c mdCarrier) Set(key, val string) {
+	metadata.MD(c).Set(key, val)
+}
+
+func (c mdCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, vs := range c {
+		if strings.HasSuffix(k, "-bin") {
+			continue
+		}
+		for _, v := range vs {
+			if err := handler(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kaimixu/motor/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	_metricsMutex sync.RWMutex
+	_metrics      *rpcMetrics
+)
+
+// grpc的指标定义，method为完整的方法名，如/user.User/Get，code为grpc状态码
+var (
+	ServerHandled = metrics.Definition{
+		Name:   "grpc_server_handled_total",
+		Help:   "grpc server handled rpcs count",
+		Type:   metrics.TypeCounter,
+		Labels: []string{"method", "code"},
+	}
+	ServerDuration = metrics.Definition{
+		Name:   "grpc_server_handling_ms",
+		Help:   "grpc server rpc handling duration(ms)",
+		Type:   metrics.TypeHistogram,
+		Labels: []string{"method"},
+	}
+	ClientHandled = metrics.Definition{
+		Name:   "grpc_client_handled_total",
+		Help:   "grpc client completed rpcs count",
+		Type:   metrics.TypeCounter,
+		Labels: []string{"method", "code"},
+	}
+	ClientDuration = metrics.Definition{
+		Name:   "grpc_client_handling_ms",
+		Help:   "grpc client rpc duration(ms)",
+		Type:   metrics.TypeHistogram,
+		Labels: []string{"method"},
+	}
+)
+
+func MetricDefinitions() []metrics.Definition {
+	return []metrics.Definition{ServerHandled, ServerDuration, ClientHandled, ClientDuration}
+}
+
+type rpcMetrics struct {
+	serverCnt *prometheus.CounterVec
+	serverDur *prometheus.HistogramVec
+	clientCnt *prometheus.CounterVec
+	clientDur *prometheus.HistogramVec
+}
+
+// 注册grpc服务端及客户端的调用数、耗时指标
+func RegisterMetrics(r *metrics.Registry) error {
+	m := &rpcMetrics{
+		serverCnt: r.Counter(ServerHandled.Name, ServerHandled.Help, ServerHandled.Labels...),
+		serverDur: r.Histogram(ServerDuration.Name, ServerDuration.Help, nil, ServerDuration.Labels...),
+		clientCnt: r.Counter(ClientHandled.Name, ClientHandled.Help, ClientHandled.Labels...),
+		clientDur: r.Histogram(ClientDuration.Name, ClientDuration.Help, nil, ClientDuration.Labels...),
+	}
+
+	_metricsMutex.Lock()
+	_metrics = m
+	_metricsMutex.Unlock()
+	return nil
+}
+
+func loadMetrics() *rpcMetrics {
+	_metricsMutex.RLock()
+	defer _metricsMutex.RUnlock()
+	return _metrics
+}
+
+func observeServer(method, code string, dur time.Duration) {
+	if m := loadMetrics(); m != nil {
+		m.serverCnt.WithLabelValues(method, code).Inc()
+		m.serverDur.WithLabelValues(method).Observe(float64(dur) / float64(time.Millisecond))
+	}
+}
+
+func observeClient(method, code string, dur time.Duration) {
+	if m := loadMetrics(); m != nil {
+		m.clientCnt.WithLabelValues(method, code).Inc()
+		m.clientDur.WithLabelValues(method).Observe(float64(dur) / float64(time.Millisecond))
+	}
+}
@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/naming"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/resolver"
+)
+
+// 通过名字服务发现实例的target scheme，如naming:///user-service
+const Scheme = "naming"
+
+type resolverBuilder struct {
+	builder func() naming.Builder
+	idc     string
+	pubenv  string
+}
+
+// 基于名字服务的grpc resolver，idc、pubenv不为空时仅使用指定机房、部署环境的实例
+func NewResolverBuilder(b naming.Builder, idc, pubenv string) resolver.Builder {
+	return &resolverBuilder{
+		builder: func() naming.Builder { return b },
+		idc:     idc,
+		pubenv:  pubenv,
+	}
+}
+
+func (rb *resolverBuilder) Scheme() string {
+	return Scheme
+}
+
+func (rb *resolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	b := rb.builder()
+	if b == nil {
+		return nil, errors.New("naming.Build failed")
+	}
+	sn := target.Endpoint
+	nr, err := b.Discovery(sn)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("builder.Discovery failed, sn:%s", sn))
+	}
+
+	r := &namingResolver{
+		sn:       sn,
+		idc:      rb.idc,
+		pubenv:   rb.pubenv,
+		cc:       cc,
+		resolver: nr,
+		quit:     make(chan struct{}),
+	}
+	if ins, ok := nr.Fetch(); ok {
+		r.update(ins)
+	}
+	go r.watch()
+
+	return r, nil
+}
+
+type namingResolver struct {
+	sn     string
+	idc    string
+	pubenv string
+
+	cc        resolver.ClientConn
+	resolver  naming.Resolver
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+// 监听实例变化
+func (r *namingResolver) watch() {
+	for {
+		select {
+		case <-r.quit:
+			return
+		case <-r.resolver.Watch():
+			ins, ok := r.resolver.Fetch()
+			if !ok {
+				continue
+			}
+			r.update(ins)
+		}
+	}
+}
+
+func (r *namingResolver) update(ins []*naming.Instance) {
+	addrs := naming.InstanceAddrs(ins, r.idc, r.pubenv)
+	state := resolver.State{Addresses: make([]resolver.Address, 0, len(addrs))}
+	for _, addr := range addrs {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+	}
+	r.cc.UpdateState(state)
+
+	log.Named("rpc").Info("endpoints updated",
+		zap.String("service", r.sn),
+		zap.Strings("addrs", addrs))
+}
+
+// 实例变化由名字服务推送，无需主动解析
+func (r *namingResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *namingResolver) Close() {
+	r.closeOnce.Do(func() {
+		close(r.quit)
+		r.resolver.Close()
+	})
+}
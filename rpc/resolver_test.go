@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kaimixu/motor/naming"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeResolver struct {
+	mutex  sync.Mutex
+	ins    []*naming.Instance
+	loaded bool
+	event  chan struct{}
+	closed bool
+}
+
+func (r *fakeResolver) Watch() <-chan struct{} {
+	return r.event
+}
+
+func (r *fakeResolver) Fetch() ([]*naming.Instance, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.ins, r.loaded
+}
+
+func (r *fakeResolver) Close() {
+	r.mutex.Lock()
+	r.closed = true
+	r.mutex.Unlock()
+}
+
+func (r *fakeResolver) set(ins ...*naming.Instance) {
+	r.mutex.Lock()
+	r.ins = ins
+	r.loaded = true
+	r.mutex.Unlock()
+	r.event <- struct{}{}
+}
+
+type fakeBuilder struct {
+	resolver *fakeResolver
+	sn       string
+}
+
+func (b *fakeBuilder) Discovery(sn string) (naming.Resolver, error) {
+	b.sn = sn
+	return b.resolver, nil
+}
+
+func (b *fakeBuilder) Register(ins *naming.Instance) (context.CancelFunc, error) {
+	return func() {}, nil
+}
+
+func (b *fakeBuilder) Close() {}
+
+// 按名字服务中存储的格式构造实例
+func newTestInstance(t *testing.T, idc, pubenv string, addrs ...string) *naming.Instance {
+	attr, _ := json.Marshal(map[string]interface{}{
+		"data": naming.DefaultInstanceAttr{Addrs: addrs},
+	})
+	b := fmt.Sprintf(`{"name":"user","idc":%q,"pubenv":%q,"attr":%s}`, idc, pubenv, attr)
+	var in naming.Instance
+	require.NoError(t, json.Unmarshal([]byte(b), &in))
+	return &in
+}
+
+func TestResolver(t *testing.T) {
+	require := require.New(t)
+
+	lisA, stopA := startTestServer(t, "a")
+	defer stopA()
+	lisB, stopB := startTestServer(t, "b")
+	defer stopB()
+	listeners := map[string]*bufconn.Listener{"a:9000": lisA, "b:9000": lisB}
+
+	resolver := &fakeResolver{event: make(chan struct{})}
+	builder := &fakeBuilder{resolver: resolver}
+	cc, err := Dial(context.Background(), Scheme+":///user", &ClientConf{},
+		grpc.WithInsecure(),
+		grpc.WithResolvers(NewResolverBuilder(builder, "bj", "online")),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			lis, ok := listeners[addr]
+			if !ok {
+				return nil, fmt.Errorf("unknown addr %s", addr)
+			}
+			return lis.Dial()
+		}))
+	require.NoError(err)
+	defer cc.Close()
+	require.Equal("user", builder.sn)
+	client := grpc_health_v1.NewHealthClient(cc)
+
+	resolver.set(
+		newTestInstance(t, "bj", "online", "a:9000", "b:9000"),
+		newTestInstance(t, "sh", "online", "c:9000"),
+	)
+
+	// 轮询各实例
+	check := func() string {
+		var header metadata.MD
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.WaitForReady(true), grpc.Header(&header))
+		require.NoError(err)
+		return header.Get("server")[0]
+	}
+	require.Eventually(func() bool {
+		got := make(map[string]int)
+		for i := 0; i < 4; i++ {
+			got[check()]++
+		}
+		return got["a"] == 2 && got["b"] == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// 实例变化
+	resolver.set(newTestInstance(t, "bj", "online", "b:9000"))
+	require.Eventually(func() bool {
+		for i := 0; i < 4; i++ {
+			if check() != "b" {
+				return false
+			}
+		}
+		return true
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// 服务下线
+	resolver.set()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.Error(err)
+	require.Contains([]codes.Code{codes.Unavailable, codes.DeadlineExceeded}, status.Code(err))
+
+	cc.Close()
+	require.Eventually(func() bool {
+		resolver.mutex.Lock()
+		defer resolver.mutex.Unlock()
+		return resolver.closed
+	}, time.Second, 10*time.Millisecond)
+}
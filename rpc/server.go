@@ -0,0 +1,179 @@
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+type ServerConf struct {
+	// 监听类型：tcp(默认)、unix
+	Network string
+	Addr    string
+	// 建立连接(含tls握手)的超时时间
+	ConnectionTimeout conf.Duration
+	// 为0时使用grpc的默认值，接收4M、发送不限制
+	MaxRecvMsgSize conf.ByteSize
+	MaxSendMsgSize conf.ByteSize
+	// 单个连接的最大并发stream数，0表示不限制
+	MaxConcurrentStreams uint32
+	// 连接空闲多久后发送keepalive ping及等待响应的时间
+	KeepaliveTime    conf.Duration
+	KeepaliveTimeout conf.Duration
+	// 连接的最长存活时间，用于客户端重新负载均衡，0表示不限制
+	MaxConnectionAge conf.Duration
+
+	// 收到退出信号后等待处理中请求完成的最长时间，默认10s
+	ShutdownTimeout conf.Duration
+	// 不记录accesslog的方法，如/grpc.health.v1.Health/Check
+	SkipMethods []string
+}
+
+// 集成了accesslog、recovery、trace、metrics及request id拦截器的grpc服务
+type Server struct {
+	*grpc.Server
+	conf *ServerConf
+
+	quit     chan struct{}
+	quitOnce sync.Once
+}
+
+// 从application.toml的[RpcServer]中加载配置
+func LoadServerConf() (*ServerConf, error) {
+	var cfg ServerConf
+	if err := conf.UnmarshalSection("application.toml", "RpcServer", &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// 默认拦截器依次为：status转换、request id、trace、accesslog、metrics、recovery，
+// opts中通过grpc.ChainUnaryInterceptor添加的拦截器(如UnaryJwt、UnaryRatelimit)位于其后
+func NewServer(cfg *ServerConf, opts ...grpc.ServerOption) *Server {
+	serverOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			UnaryStatus(),
+			UnaryRequestID(),
+			UnaryTrace(),
+			UnaryLogger(cfg.SkipMethods...),
+			UnaryMetrics(),
+			UnaryRecovery(),
+		),
+		grpc.ChainStreamInterceptor(
+			StreamStatus(),
+			StreamRequestID(),
+			StreamTrace(),
+			StreamLogger(cfg.SkipMethods...),
+			StreamMetrics(),
+			StreamRecovery(),
+		),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:             time.Duration(cfg.KeepaliveTime),
+			Timeout:          time.Duration(cfg.KeepaliveTimeout),
+			MaxConnectionAge: time.Duration(cfg.MaxConnectionAge),
+		}),
+	}
+	if cfg.ConnectionTimeout > 0 {
+		serverOpts = append(serverOpts, grpc.ConnectionTimeout(time.Duration(cfg.ConnectionTimeout)))
+	}
+	if cfg.MaxRecvMsgSize > 0 {
+		serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(int(cfg.MaxRecvMsgSize)))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		serverOpts = append(serverOpts, grpc.MaxSendMsgSize(int(cfg.MaxSendMsgSize)))
+	}
+	if cfg.MaxConcurrentStreams > 0 {
+		serverOpts = append(serverOpts, grpc.MaxConcurrentStreams(cfg.MaxConcurrentStreams))
+	}
+
+	return &Server{
+		Server: grpc.NewServer(append(serverOpts, opts...)...),
+		conf:   cfg,
+		quit:   make(chan struct{}),
+	}
+}
+
+// 监听配置的地址并提供服务，阻塞至收到SIGTERM/SIGINT或调用Shutdown，之后平滑退出
+func (s *Server) Run() error {
+	network := s.conf.Network
+	if network == "" {
+		network = "tcp"
+	}
+	if network == "unix" {
+		// 清理上次退出残留的socket文件
+		if fi, err := os.Stat(s.conf.Addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(s.conf.Addr); err != nil {
+				return errors.Wrap(err, "os.Remove")
+			}
+		}
+	}
+	lis, err := net.Listen(network, s.conf.Addr)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("net.Listen failed, network:%s, addr:%s", network, s.conf.Addr))
+	}
+	return s.ServeListener(lis)
+}
+
+// 在指定的listener上提供服务，退出方式同Run
+func (s *Server) ServeListener(lis net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Server.Serve(lis)
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-errCh:
+		zap.L().Error("grpc server exited", zap.Error(err))
+		return err
+	case sig := <-sigCh:
+		zap.L().Info("receive signal, shutting down", zap.String("signal", sig.String()))
+	case <-s.quit:
+		zap.L().Info("shutting down")
+	}
+
+	s.gracefulStop()
+	return nil
+}
+
+// 触发平滑退出，Run/ServeListener将在退出完成后返回
+func (s *Server) Shutdown() {
+	s.quitOnce.Do(func() {
+		close(s.quit)
+	})
+}
+
+// 等待处理中的请求完成，超时后强制关闭
+func (s *Server) gracefulStop() {
+	timeout := time.Duration(s.conf.ShutdownTimeout)
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		zap.L().Warn("grpc server graceful stop timeout, force stop", zap.Duration("timeout", timeout))
+		s.Server.Stop()
+	}
+}
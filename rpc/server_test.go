@@ -0,0 +1,291 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/core/flow"
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/ecode"
+	"github.com/kaimixu/motor/jwt"
+	"github.com/kaimixu/motor/log"
+	"github.com/kaimixu/motor/metrics"
+	"github.com/kaimixu/motor/tolerant"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// 按服务名模拟不同的处理结果，通过响应header返回请求上下文
+type testHealth struct {
+	name string
+}
+
+func (h *testHealth) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	switch req.Service {
+	case "panic":
+		panic("boom")
+	case "missing":
+		return nil, ecode.NotFound
+	}
+
+	id, _ := log.RequestIDFrom(ctx)
+	var traceID, subject string
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		if sc, ok := span.Context().(mocktracer.MockSpanContext); ok {
+			traceID = strconv.Itoa(sc.TraceID)
+		}
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		subject = claims.Subject
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("server", h.name, "got-request-id", id, "trace-id", traceID, "subject", subject))
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (h *testHealth) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	if req.Service == "panic" {
+		panic("boom")
+	}
+	for _, s := range []grpc_health_v1.HealthCheckResponse_ServingStatus{
+		grpc_health_v1.HealthCheckResponse_SERVING,
+		grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+	} {
+		if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: s}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 在bufconn上启动服务，返回监听器及停止函数
+func startTestServer(t *testing.T, name string, opts ...grpc.ServerOption) (*bufconn.Listener, func()) {
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(&ServerConf{ShutdownTimeout: conf.Duration(time.Second)}, opts...)
+	grpc_health_v1.RegisterHealthServer(srv.Server, &testHealth{name: name})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ServeListener(lis)
+	}()
+	return lis, func() {
+		srv.Shutdown()
+		require.NoError(t, <-done)
+	}
+}
+
+func dialBufconn(t *testing.T, lis *bufconn.Listener, cfg *ClientConf) *grpc.ClientConn {
+	cc, err := Dial(context.Background(), "passthrough:///bufnet", cfg,
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+	require.NoError(t, err)
+	return cc
+}
+
+func TestServer(t *testing.T) {
+	require := require.New(t)
+
+	defer opentracing.SetGlobalTracer(opentracing.GlobalTracer())
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+
+	r, err := metrics.NewRegistry(&metrics.MetricsConf{Namespace: "rpc"})
+	require.NoError(err)
+	require.NoError(RegisterMetrics(r))
+	defer func() {
+		_metricsMutex.Lock()
+		_metrics = nil
+		_metricsMutex.Unlock()
+	}()
+
+	lis, stop := startTestServer(t, "a")
+	defer stop()
+	cc := dialBufconn(t, lis, &ClientConf{Timeout: conf.Duration(time.Second)})
+	defer cc.Close()
+	client := grpc_health_v1.NewHealthClient(cc)
+
+	// request id及trace上下文传递给服务端
+	parent := tracer.StartSpan("handler")
+	ctx := opentracing.ContextWithSpan(log.WithRequestID(context.Background(), "abc-123"), parent)
+	var header metadata.MD
+	resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+	require.NoError(err)
+	parent.Finish()
+	require.Equal(grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+	require.Equal([]string{"abc-123"}, header.Get(RequestIDKey))
+	require.Equal([]string{"abc-123"}, header.Get("got-request-id"))
+	require.Equal([]string{strconv.Itoa(parent.Context().(mocktracer.MockSpanContext).TraceID)}, header.Get("trace-id"))
+
+	spans := tracer.FinishedSpans()
+	require.Len(spans, 3)
+	server, client1 := spans[0], spans[1]
+	require.Equal("/grpc.health.v1.Health/Check", server.OperationName)
+	require.Equal(client1.SpanContext.SpanID, server.ParentID)
+	require.Equal(parent.Context().(mocktracer.MockSpanContext).SpanID, client1.ParentID)
+	require.Equal("OK", server.Tag("grpc.code"))
+
+	// 未携带时生成request id
+	header = nil
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+	require.NoError(err)
+	require.Len(header.Get(RequestIDKey), 1)
+	require.NotEmpty(header.Get(RequestIDKey)[0])
+
+	// ecode转换为对应的状态码
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "missing"})
+	require.Equal(codes.NotFound, status.Code(err))
+
+	// panic时返回Internal
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "panic"})
+	require.Equal(codes.Internal, status.Code(err))
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "panic"})
+	require.NoError(err)
+	_, err = stream.Recv()
+	require.Equal(codes.Internal, status.Code(err))
+
+	// stream
+	tracer.Reset()
+	stream, err = client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(err)
+	var got []grpc_health_v1.HealthCheckResponse_ServingStatus
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(err)
+		got = append(got, resp.Status)
+	}
+	require.Equal([]grpc_health_v1.HealthCheckResponse_ServingStatus{
+		grpc_health_v1.HealthCheckResponse_SERVING,
+		grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+	}, got)
+	require.Eventually(func() bool {
+		return len(tracer.FinishedSpans()) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", metrics.DefaultPath, nil))
+	body := w.Body.String()
+	require.Contains(body, `rpc_grpc_server_handled_total{code="OK",method="/grpc.health.v1.Health/Check"} 2`)
+	require.Contains(body, `rpc_grpc_server_handled_total{code="Internal",method="/grpc.health.v1.Health/Check"} 1`)
+	require.Contains(body, `rpc_grpc_server_handled_total{code="OK",method="/grpc.health.v1.Health/Watch"} 1`)
+	require.Contains(body, `rpc_grpc_client_handled_total{code="NotFound",method="/grpc.health.v1.Health/Check"} 1`)
+	require.Contains(body, `rpc_grpc_client_handled_total{code="OK",method="/grpc.health.v1.Health/Watch"} 1`)
+}
+
+func TestServerJwt(t *testing.T) {
+	require := require.New(t)
+
+	j := jwt.NewJWT("secret")
+	lis, stop := startTestServer(t, "a",
+		grpc.ChainUnaryInterceptor(UnaryJwt(j, "/grpc.health.v1.Health/Watch")),
+		grpc.ChainStreamInterceptor(StreamJwt(j, "/grpc.health.v1.Health/Watch")))
+	defer stop()
+	cc := dialBufconn(t, lis, &ClientConf{})
+	defer cc.Close()
+	client := grpc_health_v1.NewHealthClient(cc)
+
+	_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid")
+	_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	token, err := j.GenToken(&jwt.MotorClaims{
+		StandardClaims: jwtgo.StandardClaims{
+			Subject:   "user1",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		},
+	})
+	require.NoError(err)
+	var header metadata.MD
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+	require.NoError(err)
+	require.Equal([]string{"user1"}, header.Get("subject"))
+
+	// 跳过的方法不校验
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(err)
+	_, err = stream.Recv()
+	require.NoError(err)
+}
+
+func TestServerRatelimit(t *testing.T) {
+	require := require.New(t)
+
+	old := tolerant.Svc.FlowRule
+	defer func() {
+		tolerant.Svc.FlowRule = old
+		flow.ClearRules()
+	}()
+	tolerant.Svc.FlowRule.Enabled = true
+	tolerant.Svc.FlowRule.Resource = "rpc_ratelimit_test"
+	_, err := flow.LoadRules([]*flow.Rule{{
+		Resource:               "rpc_ratelimit_test",
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		MetricType:             flow.QPS,
+		Count:                  1,
+	}})
+	require.NoError(err)
+
+	lis, stop := startTestServer(t, "a",
+		grpc.ChainUnaryInterceptor(UnaryRatelimit()),
+		grpc.ChainStreamInterceptor(StreamRatelimit()))
+	defer stop()
+	cc := dialBufconn(t, lis, &ClientConf{})
+	defer cc.Close()
+	client := grpc_health_v1.NewHealthClient(cc)
+
+	// 每秒仅允许1次，超过阈值后返回ResourceExhausted
+	var got []codes.Code
+	for i := 0; i < 3; i++ {
+		_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		got = append(got, status.Code(err))
+	}
+	require.Contains(got, codes.ResourceExhausted)
+	require.Subset([]codes.Code{codes.OK, codes.ResourceExhausted}, got)
+
+	got = nil
+	for i := 0; i < 3; i++ {
+		stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(err)
+		_, err = stream.Recv()
+		got = append(got, status.Code(err))
+	}
+	require.Contains(got, codes.ResourceExhausted)
+	require.Subset([]codes.Code{codes.OK, codes.ResourceExhausted}, got)
+}
+
+func TestLoadConf(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(conf.Parse("../test/configs"))
+	scfg, err := LoadServerConf()
+	require.NoError(err)
+	require.Equal(":9000", scfg.Addr)
+	require.Equal(conf.Duration(10*time.Second), scfg.ShutdownTimeout)
+	require.Equal([]string{"/grpc.health.v1.Health/Check"}, scfg.SkipMethods)
+
+	ccfg, err := LoadClientConf()
+	require.NoError(err)
+	require.Equal(conf.Duration(time.Second), ccfg.Timeout)
+	require.Equal("round_robin", ccfg.Balancer)
+}
@@ -2,6 +2,8 @@ package tolerant
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	sentinel "github.com/alibaba/sentinel-golang/api"
@@ -13,6 +15,10 @@ import (
 
 var (
 	Svc SentinelConf
+
+	// sentinel的LoadRules会替换全部熔断规则，故在此合并各处添加的规则
+	_breakerMutex sync.Mutex
+	_breakerRules = make(map[string]*circuitbreaker.Rule)
 )
 
 type serverConf struct {
@@ -54,6 +60,24 @@ type SentinelConf struct {
 	BreakerRule breakerRuleConf
 }
 
+// 当前生效的限流及熔断规则，熔断规则包含AddBreakerRules添加的全部规则
+func Rules() map[string]interface{} {
+	_breakerMutex.Lock()
+	breakers := make([]*circuitbreaker.Rule, 0, len(_breakerRules))
+	for _, rule := range _breakerRules {
+		breakers = append(breakers, rule)
+	}
+	_breakerMutex.Unlock()
+	sort.Slice(breakers, func(i, j int) bool {
+		return breakers[i].Resource < breakers[j].Resource
+	})
+
+	return map[string]interface{}{
+		"flow":    flow.GetRules(),
+		"breaker": breakers,
+	}
+}
+
 func getConf() SentinelConf {
 	var cfg SentinelConf
 	if err := conf.Get("sentinel.toml").UnmarshalTOML(&cfg); err != nil {
@@ -95,19 +119,33 @@ func Init() {
 	}
 
 	if Svc.BreakerRule.Enabled {
-		_, err := circuitbreaker.LoadRules([]*circuitbreaker.Rule{
-			{
-				Resource:         Svc.BreakerRule.Resource,
-				Strategy:         circuitbreaker.Strategy(Svc.BreakerRule.Strategy),
-				RetryTimeoutMs:   uint32(time.Duration(Svc.BreakerRule.RetryTimeout).Milliseconds()),
-				MinRequestAmount: Svc.BreakerRule.MinRequestAmount,
-				StatIntervalMs:   uint32(time.Duration(Svc.BreakerRule.StatInterval).Milliseconds()),
-				Threshold:        Svc.BreakerRule.Threshold,
-				MaxAllowedRtMs:   uint64(time.Duration(Svc.BreakerRule.MaxAllowedRt).Milliseconds()),
-			},
+		err := AddBreakerRules(&circuitbreaker.Rule{
+			Resource:         Svc.BreakerRule.Resource,
+			Strategy:         circuitbreaker.Strategy(Svc.BreakerRule.Strategy),
+			RetryTimeoutMs:   uint32(time.Duration(Svc.BreakerRule.RetryTimeout).Milliseconds()),
+			MinRequestAmount: Svc.BreakerRule.MinRequestAmount,
+			StatIntervalMs:   uint32(time.Duration(Svc.BreakerRule.StatInterval).Milliseconds()),
+			Threshold:        Svc.BreakerRule.Threshold,
+			MaxAllowedRtMs:   uint64(time.Duration(Svc.BreakerRule.MaxAllowedRt).Milliseconds()),
 		})
 		if err != nil {
 			panic(fmt.Sprintf("Unexpected error: %+v", err))
 		}
 	}
 }
+
+// 添加或替换(按Resource)熔断规则，不影响其他已添加的规则
+func AddBreakerRules(rules ...*circuitbreaker.Rule) error {
+	_breakerMutex.Lock()
+	defer _breakerMutex.Unlock()
+
+	for _, rule := range rules {
+		_breakerRules[rule.Resource] = rule
+	}
+	all := make([]*circuitbreaker.Rule, 0, len(_breakerRules))
+	for _, rule := range _breakerRules {
+		all = append(all, rule)
+	}
+	_, err := circuitbreaker.LoadRules(all)
+	return err
+}
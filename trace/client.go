@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kaimixu/motor/log"
 	"github.com/opentracing/opentracing-go"
 )
 
@@ -14,6 +15,7 @@ const (
 const (
 	TYPE_JAEGER = iota
 	TYPE_ZIPKIN
+	TYPE_OTLP
 )
 
 var (
@@ -23,6 +25,7 @@ var (
 type ITrace interface {
 	GetTraceCtx(*gin.Context) (context.Context, bool)
 	GetTraceID(opentracing.Span) (string, bool)
+	GetSpanID(opentracing.Span) (string, bool)
 
 	Close() error
 }
@@ -34,16 +37,24 @@ func Init(typ int, serverName string) ITrace {
 	switch typ {
 	case TYPE_JAEGER:
 		_Trace = newJaeger(serverName)
-		return _Trace
 	case TYPE_ZIPKIN:
-		// todo
-		return nil
+		_Trace = newZipkin(serverName)
+	case TYPE_OTLP:
+		_Trace = newOtlp(serverName)
 	default:
 		panic("invalid type")
 	}
+	// 日志中输出trace_id、span_id
+	log.SetSpanIDer(_Trace)
+
+	return _Trace
 }
 
+// 未初始化时返回false
 func GetTraceCtx(c *gin.Context) (context.Context, bool) {
+	if _Trace == nil {
+		return nil, false
+	}
 	return _Trace.GetTraceCtx(c)
 }
 
@@ -51,6 +62,48 @@ func GetTraceID(span opentracing.Span) (string, bool) {
 	return _Trace.GetTraceID(span)
 }
 
+func GetSpanID(span opentracing.Span) (string, bool) {
+	return _Trace.GetSpanID(span)
+}
+
+// 获取ctx中span的traceID及spanID，未开启trace时返回false
+func SpanIDs(ctx context.Context) (traceID, spanID string, ok bool) {
+	if _Trace == nil || ctx == nil {
+		return "", "", false
+	}
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return "", "", false
+	}
+	if traceID, ok = _Trace.GetTraceID(span); !ok {
+		return "", "", false
+	}
+	spanID, _ = _Trace.GetSpanID(span)
+
+	return traceID, spanID, true
+}
+
+// 未初始化时直接返回
 func Close() error {
+	if _Trace == nil {
+		return nil
+	}
 	return _Trace.Close()
 }
+
+// 获取gin请求对应的traceID，未开启trace时返回false
+func TraceID(c *gin.Context) (string, bool) {
+	if _Trace == nil {
+		return "", false
+	}
+	ctx, ok := _Trace.GetTraceCtx(c)
+	if !ok {
+		return "", false
+	}
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return "", false
+	}
+
+	return _Trace.GetTraceID(span)
+}
@@ -0,0 +1,90 @@
+package trace
+
+import (
+	"fmt"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/pkg/errors"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+)
+
+// 传播格式
+const (
+	PropagationJaeger = "jaeger"
+	PropagationW3C    = "w3c"
+	PropagationB3     = "b3"
+)
+
+// 默认同时支持uber-trace-id、traceparent及b3
+var DefaultPropagation = []string{PropagationJaeger, PropagationW3C, PropagationB3}
+
+type PropagationConf struct {
+	// 提取时按顺序尝试，注入时全部写入
+	Formats []string
+}
+
+// zipkin、otlp的上报配置
+type ExporterConf struct {
+	// zipkin如：http://localhost:9411/api/v2/spans，otlp如：http://localhost:4318/v1/traces
+	Endpoint string
+	// 附加的请求头，如鉴权token
+	Headers map[string]string
+	// 单次请求超时，默认5s
+	Timeout conf.Duration
+	// 每批发送的span数，默认100
+	BatchSize int
+	// 待发送span的队列长度，默认100
+	QueueSize int
+	// 定时发送的间隔，默认1s
+	FlushInterval conf.Duration
+}
+
+type traceConf struct {
+	Sampler     jaegercfg.SamplerConfig
+	Reporter    jaegercfg.ReporterConfig
+	Propagation PropagationConf
+	Zipkin      *ExporterConf
+	Otlp        *ExporterConf
+}
+
+// 读取jaeger.toml、trace.toml等配置，Sampler外的section均可省略
+func loadConf(name string) (*traceConf, error) {
+	var st conf.Storage
+	var cfg traceConf
+	val := conf.Get(name)
+	if val == nil {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	if err := val.Unmarshal(&st); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Get(%s).Unmarshal failed", name))
+	}
+
+	sections := []struct {
+		name     string
+		v        interface{}
+		required bool
+	}{
+		{"Sampler", &cfg.Sampler, true},
+		{"Reporter", &cfg.Reporter, false},
+		{"Propagation", &cfg.Propagation, false},
+		{"Zipkin", &cfg.Zipkin, false},
+		{"Otlp", &cfg.Otlp, false},
+	}
+	for _, s := range sections {
+		v := st.Get(s.name)
+		if v == nil {
+			if s.required {
+				return nil, fmt.Errorf("section %s not found in %s", s.name, name)
+			}
+			continue
+		}
+		if err := v.UnmarshalTOML(s.v); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Get(%s).UnmarshalTOML failed", s.name))
+		}
+	}
+	if len(cfg.Propagation.Formats) == 0 {
+		cfg.Propagation.Formats = DefaultPropagation
+	}
+
+	return &cfg, nil
+}
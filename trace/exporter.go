@@ -0,0 +1,151 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go"
+)
+
+const (
+	defExportTimeout   = 5 * time.Second
+	defExportBatchSize = 100
+	defQueueSize       = 100
+	defFlushInterval   = time.Second
+)
+
+// 将span转换为后端的格式，encode时编码一批span
+type spanEncoder interface {
+	convert(span *jaeger.Span) interface{}
+	encode(spans []interface{}) ([]byte, error)
+}
+
+// 通过http批量发送span，由jaeger的RemoteReporter在同一goroutine中调用，无需加锁
+type httpTransport struct {
+	conf    *ExporterConf
+	client  *http.Client
+	encoder spanEncoder
+	batch   []interface{}
+	size    int
+}
+
+func newHTTPTransport(cfg *ExporterConf, encoder spanEncoder) (*httpTransport, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("trace exporter endpoint cannot be empty")
+	}
+
+	timeout := time.Duration(cfg.Timeout)
+	if timeout <= 0 {
+		timeout = defExportTimeout
+	}
+	size := cfg.BatchSize
+	if size <= 0 {
+		size = defExportBatchSize
+	}
+	return &httpTransport{
+		conf:    cfg,
+		client:  &http.Client{Timeout: timeout},
+		encoder: encoder,
+		size:    size,
+	}, nil
+}
+
+// span在Append返回后可能被复用，需立即转换
+func (t *httpTransport) Append(span *jaeger.Span) (int, error) {
+	t.batch = append(t.batch, t.encoder.convert(span))
+	if len(t.batch) >= t.size {
+		return t.Flush()
+	}
+	return 0, nil
+}
+
+func (t *httpTransport) Flush() (int, error) {
+	n := len(t.batch)
+	if n == 0 {
+		return 0, nil
+	}
+	batch := t.batch
+	t.batch = nil
+
+	body, err := t.encoder.encode(batch)
+	if err != nil {
+		return n, errors.Wrap(err, "encode spans failed")
+	}
+	return n, t.send(body)
+}
+
+func (t *httpTransport) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, t.conf.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("http.NewRequest failed, endpoint:%s", t.conf.Endpoint))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d, body:%s", resp.StatusCode, msg)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+func (t *httpTransport) Close() error {
+	_, err := t.Flush()
+	return err
+}
+
+// 基于transport创建RemoteReporter，logSpans时同时将span记录到日志
+func newReporter(transport jaeger.Transport, cfg *ExporterConf, logSpans bool) jaeger.Reporter {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defQueueSize
+	}
+	interval := time.Duration(cfg.FlushInterval)
+	if interval <= 0 {
+		interval = defFlushInterval
+	}
+
+	reporter := jaeger.NewRemoteReporter(transport,
+		jaeger.ReporterOptions.QueueSize(queueSize),
+		jaeger.ReporterOptions.BufferFlushInterval(interval),
+		jaeger.ReporterOptions.Logger(jLogger))
+	if logSpans {
+		return jaeger.NewCompositeReporter(jaeger.NewLoggingReporter(jLogger), reporter)
+	}
+	return reporter
+}
+
+// 将opentracing的tag值转换为字符串
+func tagString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case error:
+		return val.Error()
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func traceIDHex(id jaeger.TraceID) string {
+	return fmt.Sprintf("%016x%016x", id.High, id.Low)
+}
+
+func spanIDHex(id jaeger.SpanID) string {
+	return fmt.Sprintf("%016x", uint64(id))
+}
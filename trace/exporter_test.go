@@ -0,0 +1,204 @@
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kaimixu/motor/conf"
+	"github.com/kaimixu/motor/internal/otlp"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
+)
+
+type capture struct {
+	reqs   chan *http.Request
+	bodies chan []byte
+}
+
+func newCaptureServer() (*httptest.Server, *capture) {
+	c := &capture{reqs: make(chan *http.Request, 8), bodies: make(chan []byte, 8)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		c.reqs <- req
+		c.bodies <- b
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	return srv, c
+}
+
+// 生成一个server span及其child client span，关闭tracer时上报
+func emitSpans(t *testing.T, transport jaeger.Transport) (server, client jaeger.SpanContext) {
+	reporter := newReporter(transport, &ExporterConf{FlushInterval: conf.Duration(time.Hour)}, false)
+	tracer, closer := newTestTracer(t, DefaultPropagation, reporter)
+
+	span := tracer.StartSpan("GET /users/:id", ext.SpanKindRPCServer)
+	ext.HTTPStatusCode.Set(span, 500)
+	ext.Error.Set(span, true)
+	child := tracer.StartSpan("mysql.query", opentracing.ChildOf(span.Context()), ext.SpanKindRPCClient)
+	child.LogKV("event", "retry", "attempt", 2)
+	child.Finish()
+	span.Finish()
+	require.NoError(t, closer.Close())
+
+	return span.Context().(jaeger.SpanContext), child.Context().(jaeger.SpanContext)
+}
+
+func TestZipkinTransport(t *testing.T) {
+	require := require.New(t)
+	srv, c := newCaptureServer()
+	defer srv.Close()
+
+	transport, err := newZipkinTransport("motor", &ExporterConf{Endpoint: srv.URL + "/api/v2/spans"})
+	require.NoError(err)
+	server, client := emitSpans(t, transport)
+
+	req := <-c.reqs
+	require.Equal("/api/v2/spans", req.URL.Path)
+	require.Equal("application/json", req.Header.Get("Content-Type"))
+	var spans []zipkinSpan
+	require.NoError(json.Unmarshal(<-c.bodies, &spans))
+	require.Len(spans, 2)
+
+	cs, ss := spans[0], spans[1]
+	require.Equal(traceIDHex(server.TraceID()), ss.TraceID)
+	require.Len(ss.TraceID, 32)
+	require.Equal(spanIDHex(server.SpanID()), ss.ID)
+	require.Empty(ss.ParentID)
+	require.Equal("SERVER", ss.Kind)
+	require.Equal("motor", ss.LocalEndpoint.ServiceName)
+	require.Equal("500", ss.Tags["http.status_code"])
+	require.Equal("true", ss.Tags["error"])
+
+	require.Equal(spanIDHex(client.SpanID()), cs.ID)
+	require.Equal(ss.ID, cs.ParentID)
+	require.Equal("CLIENT", cs.Kind)
+	require.Len(cs.Annotations, 1)
+	require.Equal("event=retry attempt=2", cs.Annotations[0].Value)
+}
+
+func TestOtlpTransport(t *testing.T) {
+	require := require.New(t)
+	srv, c := newCaptureServer()
+	defer srv.Close()
+
+	transport, err := newOtlpTransport("motor", &ExporterConf{
+		Endpoint: srv.URL + "/v1/traces",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	})
+	require.NoError(err)
+	server, client := emitSpans(t, transport)
+
+	req := <-c.reqs
+	require.Equal("/v1/traces", req.URL.Path)
+	require.Equal("Bearer token", req.Header.Get("Authorization"))
+
+	var body struct {
+		ResourceSpans []struct {
+			Resource   otlp.Resource `json:"resource"`
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	require.NoError(json.Unmarshal(<-c.bodies, &body))
+	rs := body.ResourceSpans[0]
+	require.Equal("service.name", rs.Resource.Attributes[0].Key)
+	require.Equal("motor", *rs.Resource.Attributes[0].Value.StringValue)
+	spans := rs.ScopeSpans[0].Spans
+	require.Len(spans, 2)
+
+	cs, ss := spans[0], spans[1]
+	require.Equal(traceIDHex(server.TraceID()), ss.TraceID)
+	require.Equal(2, ss.Kind)
+	require.Equal(otlpStatusError, ss.Status.Code)
+	attrs := make(map[string]otlp.Value)
+	for _, a := range ss.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	require.Equal("500", *attrs["http.status_code"].IntValue)
+
+	require.Equal(spanIDHex(client.SpanID()), cs.SpanID)
+	require.Equal(ss.SpanID, cs.ParentSpanID)
+	require.Equal(3, cs.Kind)
+	require.Len(cs.Events, 1)
+	require.Equal("retry", cs.Events[0].Name)
+	require.Equal("attempt", cs.Events[0].Attributes[0].Key)
+}
+
+func TestHTTPTransport(t *testing.T) {
+	require := require.New(t)
+
+	_, err := newHTTPTransport(&ExporterConf{}, &zipkinEncoder{})
+	require.Error(err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "invalid spans", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	transport, err := newHTTPTransport(&ExporterConf{Endpoint: srv.URL, BatchSize: 2}, &failEncoder{})
+	require.NoError(err)
+	n, err := transport.Flush()
+	require.NoError(err)
+	require.Zero(n)
+
+	tracer, closer := newTestTracer(t, DefaultPropagation, nil)
+	defer closer.Close()
+	span := tracer.StartSpan("test").(*jaeger.Span)
+	n, err = transport.Append(span)
+	require.NoError(err)
+	require.Zero(n)
+	// 达到BatchSize时立即发送
+	n, err = transport.Append(span)
+	require.Equal(2, n)
+	require.Contains(err.Error(), "invalid spans")
+
+	transport.encoder = &failEncoder{err: errors.New("encode failed")}
+	_, _ = transport.Append(span)
+	_, err = transport.Flush()
+	require.Contains(err.Error(), "encode failed")
+}
+
+type failEncoder struct {
+	err error
+}
+
+func (e *failEncoder) convert(span *jaeger.Span) interface{} {
+	return span.OperationName()
+}
+
+func (e *failEncoder) encode(spans []interface{}) ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return json.Marshal(spans)
+}
+
+func TestLoadConf(t *testing.T) {
+	require := require.New(t)
+	require.Nil(conf.Parse("../test/configs"))
+
+	cfg, err := loadConf("trace.toml")
+	require.NoError(err)
+	require.Equal("const", cfg.Sampler.Type)
+	require.Equal(DefaultPropagation, cfg.Propagation.Formats)
+	require.Equal("http://localhost:9411/api/v2/spans", cfg.Zipkin.Endpoint)
+	require.Equal(time.Second, time.Duration(cfg.Otlp.FlushInterval))
+	require.Equal("Bearer token", cfg.Otlp.Headers["Authorization"])
+
+	// jaeger.toml未配置Propagation时使用默认值
+	cfg, err = loadConf("jaeger.toml")
+	require.NoError(err)
+	require.Equal(DefaultPropagation, cfg.Propagation.Formats)
+	require.Nil(cfg.Zipkin)
+
+	_, err = loadConf("notexist.toml")
+	require.Error(err)
+}
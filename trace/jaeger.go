@@ -6,7 +6,6 @@ import (
 	"io"
 
 	"github.com/gin-gonic/gin"
-	"github.com/kaimixu/motor/conf"
 	"github.com/opentracing/opentracing-go"
 	"github.com/uber/jaeger-client-go"
 	jaegercfg "github.com/uber/jaeger-client-go/config"
@@ -14,41 +13,78 @@ import (
 
 var _ ITrace = &TJaeger{}
 
+// 基于jaeger-client实现，zipkin、otlp仅替换span的上报方式
 type TJaeger struct {
 	serviceName string
 	closer      io.Closer
 }
 
+// 读取jaeger.toml，通过agent或collector上报
 func newJaeger(sn string) *TJaeger {
-	j := &TJaeger{serviceName: sn}
-	j.init()
+	cfg, err := loadConf("jaeger.toml")
+	if err != nil {
+		panic(err)
+	}
+
+	return newTracer(sn, cfg, nil)
+}
+
+// 读取trace.toml的[Zipkin]，以zipkin v2 JSON格式上报
+func newZipkin(sn string) *TJaeger {
+	cfg, err := loadConf("trace.toml")
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Zipkin == nil {
+		panic("section Zipkin not found in trace.toml")
+	}
+	transport, err := newZipkinTransport(sn, cfg.Zipkin)
+	if err != nil {
+		panic(err)
+	}
 
-	return j
+	return newTracer(sn, cfg, newReporter(transport, cfg.Zipkin, cfg.Reporter.LogSpans))
 }
 
-func (t *TJaeger) init() {
-	var st conf.Storage
-	var sampler jaegercfg.SamplerConfig
-	var reporter jaegercfg.ReporterConfig
-	if err := conf.Get("jaeger.toml").Unmarshal(&st); err != nil {
+// 读取trace.toml的[Otlp]，以OTLP/HTTP JSON格式上报
+func newOtlp(sn string) *TJaeger {
+	cfg, err := loadConf("trace.toml")
+	if err != nil {
 		panic(err)
 	}
-	if err := st.Get("Sampler").UnmarshalTOML(&sampler); err != nil {
+	if cfg.Otlp == nil {
+		panic("section Otlp not found in trace.toml")
+	}
+	transport, err := newOtlpTransport(sn, cfg.Otlp)
+	if err != nil {
 		panic(err)
 	}
-	if err := st.Get("Reporter").UnmarshalTOML(&reporter); err != nil {
+
+	return newTracer(sn, cfg, newReporter(transport, cfg.Otlp, cfg.Reporter.LogSpans))
+}
+
+// reporter为nil时按[Reporter]的配置上报到jaeger
+func newTracer(sn string, cfg *traceConf, reporter jaeger.Reporter) *TJaeger {
+	opts, err := propagationOptions(cfg.Propagation.Formats)
+	if err != nil {
 		panic(err)
 	}
+	opts = append(opts, jaegercfg.Logger(jLogger))
+	if reporter != nil {
+		// zipkin、otlp使用128位的traceID
+		opts = append(opts, jaegercfg.Reporter(reporter), jaegercfg.Gen128Bit(true))
+	}
 
-	cfg := jaegercfg.Configuration{
-		Sampler:  &sampler,
-		Reporter: &reporter,
+	jcfg := jaegercfg.Configuration{
+		Sampler:  &cfg.Sampler,
+		Reporter: &cfg.Reporter,
 	}
-	closer, err := cfg.InitGlobalTracer(t.serviceName, jaegercfg.Logger(jLogger))
+	closer, err := jcfg.InitGlobalTracer(sn, opts...)
 	if err != nil {
-		panic(fmt.Sprintf("ERROR: cannot init Jaeger: %v\n", err))
+		panic(fmt.Sprintf("ERROR: cannot init tracer: %v\n", err))
 	}
-	t.closer = closer
+
+	return &TJaeger{serviceName: sn, closer: closer}
 }
 
 func (t *TJaeger) GetTraceCtx(c *gin.Context) (context.Context, bool) {
@@ -72,6 +108,14 @@ func (t *TJaeger) GetTraceID(span opentracing.Span) (id string, ok bool) {
 	return "", false
 }
 
+func (t *TJaeger) GetSpanID(span opentracing.Span) (id string, ok bool) {
+	if sc, ok := span.Context().(jaeger.SpanContext); ok {
+		return sc.SpanID().String(), true
+	}
+
+	return "", false
+}
+
 func (t *TJaeger) Close() error {
 	return t.closer.Close()
 }
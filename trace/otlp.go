@@ -0,0 +1,159 @@
+package trace
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/kaimixu/motor/internal/otlp"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/uber/jaeger-client-go"
+)
+
+const (
+	otlpScopeName = "github.com/kaimixu/motor/trace"
+
+	otlpStatusError = 2
+)
+
+// opentracing的span.kind对应的OTLP SpanKind
+var otlpKinds = map[string]int{
+	"internal":                        1,
+	string(ext.SpanKindRPCServerEnum): 2,
+	string(ext.SpanKindRPCClientEnum): 3,
+	string(ext.SpanKindProducerEnum):  4,
+	string(ext.SpanKindConsumerEnum):  5,
+}
+
+// 以OTLP/HTTP JSON格式上报，即POST /v1/traces
+type otlpEncoder struct {
+	resource otlp.Resource
+}
+
+func newOtlpTransport(serviceName string, cfg *ExporterConf) (jaeger.Transport, error) {
+	return newHTTPTransport(cfg, &otlpEncoder{
+		resource: otlp.NewResource(map[string]string{"service.name": serviceName}),
+	})
+}
+
+func (e *otlpEncoder) convert(span *jaeger.Span) interface{} {
+	sc := span.SpanContext()
+	start := span.StartTime()
+	out := &otlpSpan{
+		TraceID:           traceIDHex(sc.TraceID()),
+		SpanID:            spanIDHex(sc.SpanID()),
+		Name:              span.OperationName(),
+		Kind:              1,
+		StartTimeUnixNano: strconv.FormatInt(start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(start.Add(span.Duration()).UnixNano(), 10),
+	}
+	if sc.ParentID() != 0 {
+		out.ParentSpanID = spanIDHex(sc.ParentID())
+	}
+
+	for k, v := range span.Tags() {
+		switch k {
+		case string(ext.SpanKind):
+			if kind, ok := otlpKinds[tagString(v)]; ok {
+				out.Kind = kind
+				continue
+			}
+		case string(ext.Error):
+			if b, ok := v.(bool); ok && b {
+				out.Status.Code = otlpStatusError
+			}
+		}
+		out.Attributes = append(out.Attributes, anyAttr(k, v))
+	}
+
+	for _, lr := range span.Logs() {
+		ev := otlpEvent{
+			TimeUnixNano: strconv.FormatInt(lr.Timestamp.UnixNano(), 10),
+			Name:         "log",
+		}
+		for _, f := range lr.Fields {
+			if f.Key() == "event" {
+				ev.Name = tagString(f.Value())
+				continue
+			}
+			ev.Attributes = append(ev.Attributes, fieldAttr(f))
+		}
+		out.Events = append(out.Events, ev)
+	}
+	return out
+}
+
+func (e *otlpEncoder) encode(spans []interface{}) ([]byte, error) {
+	return json.Marshal(&otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: e.resource,
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlp.Scope{Name: otlpScopeName},
+				Spans: spans,
+			}},
+		}},
+	})
+}
+
+// 按类型转换tag值，OTLP JSON中的int64使用字符串表示
+func anyAttr(key string, v interface{}) otlp.Attr {
+	var val otlp.Value
+	switch t := v.(type) {
+	case bool:
+		val.BoolValue = &t
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		s := tagString(t)
+		val.IntValue = &s
+	case float32:
+		f := float64(t)
+		val.DoubleValue = &f
+	case float64:
+		val.DoubleValue = &t
+	default:
+		s := tagString(v)
+		val.StringValue = &s
+	}
+	return otlp.Attr{Key: key, Value: val}
+}
+
+func fieldAttr(f log.Field) otlp.Attr {
+	return anyAttr(f.Key(), f.Value())
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlp.Resource    `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlp.Scope    `json:"scope"`
+	Spans []interface{} `json:"spans"`
+}
+
+// OTLP JSON中的traceId、spanId使用hex编码
+type otlpSpan struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []otlp.Attr `json:"attributes,omitempty"`
+	Events            []otlpEvent `json:"events,omitempty"`
+	Status            otlpStatus  `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string      `json:"timeUnixNano"`
+	Name         string      `json:"name"`
+	Attributes   []otlp.Attr `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code,omitempty"`
+}
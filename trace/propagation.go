@@ -0,0 +1,161 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+	"github.com/uber/jaeger-client-go/zipkin"
+)
+
+const (
+	// W3C Trace Context
+	TraceparentHeader = "traceparent"
+
+	traceparentVersion = "00"
+	traceFlagSampled   = 0x01
+)
+
+// 按配置的格式生成HTTPHeaders及TextMap的injector、extractor
+func propagationOptions(formats []string) ([]jaegercfg.Option, error) {
+	headers, textMap, err := newPropagators(formats)
+	if err != nil {
+		return nil, err
+	}
+
+	return []jaegercfg.Option{
+		jaegercfg.Injector(opentracing.HTTPHeaders, headers),
+		jaegercfg.Extractor(opentracing.HTTPHeaders, headers),
+		jaegercfg.Injector(opentracing.TextMap, textMap),
+		jaegercfg.Extractor(opentracing.TextMap, textMap),
+	}, nil
+}
+
+func newPropagators(formats []string) (headers, textMap *composite, err error) {
+	headerKeys := (&jaeger.HeadersConfig{}).ApplyDefaults()
+	headers, textMap = &composite{}, &composite{}
+	for _, format := range formats {
+		switch strings.ToLower(format) {
+		case PropagationJaeger:
+			headers.add(jaeger.NewHTTPHeaderPropagator(headerKeys, *jaeger.NewNullMetrics()))
+			textMap.add(jaeger.NewTextMapPropagator(headerKeys, *jaeger.NewNullMetrics()))
+		case PropagationW3C:
+			headers.add(w3cPropagator{})
+			textMap.add(w3cPropagator{})
+		case PropagationB3:
+			b3 := zipkin.NewZipkinB3HTTPHeaderPropagator()
+			headers.add(b3)
+			textMap.add(b3)
+		default:
+			return nil, nil, fmt.Errorf("invalid propagation format, format:%s", format)
+		}
+	}
+	return headers, textMap, nil
+}
+
+type propagator interface {
+	jaeger.Injector
+	jaeger.Extractor
+}
+
+// 注入时写入全部格式，提取时返回第一个成功的结果
+type composite struct {
+	propagators []propagator
+}
+
+func (c *composite) add(p propagator) {
+	c.propagators = append(c.propagators, p)
+}
+
+func (c *composite) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	for _, p := range c.propagators {
+		if err := p.Inject(sc, carrier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *composite) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	err := opentracing.ErrSpanContextNotFound
+	for _, p := range c.propagators {
+		sc, e := p.Extract(carrier)
+		if e == nil {
+			return sc, nil
+		}
+		// 优先返回格式错误等非"未找到"的错误
+		if e != nil && e != opentracing.ErrSpanContextNotFound {
+			err = e
+		}
+	}
+	return jaeger.SpanContext{}, err
+}
+
+// W3C traceparent：version-traceid-parentid-flags，不支持tracestate
+type w3cPropagator struct{}
+
+func (w3cPropagator) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	writer, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	var flags byte
+	if sc.IsSampled() {
+		flags |= traceFlagSampled
+	}
+	tid := sc.TraceID()
+	writer.Set(TraceparentHeader, fmt.Sprintf("%s-%016x%016x-%016x-%02x",
+		traceparentVersion, tid.High, tid.Low, uint64(sc.SpanID()), flags))
+	return nil
+}
+
+func (w3cPropagator) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+
+	var value string
+	err := reader.ForeachKey(func(key, val string) error {
+		if strings.EqualFold(key, TraceparentHeader) {
+			value = val
+		}
+		return nil
+	})
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	if value == "" {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+
+	return parseTraceparent(value)
+}
+
+func parseTraceparent(value string) (jaeger.SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	// 高版本可能在末尾追加字段
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 ||
+		(parts[0] == traceparentVersion && len(parts) != 4) {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+
+	high, err1 := strconv.ParseUint(parts[1][:16], 16, 64)
+	low, err2 := strconv.ParseUint(parts[1][16:], 16, 64)
+	spanID, err3 := strconv.ParseUint(parts[2], 16, 64)
+	flags, err4 := strconv.ParseUint(parts[3], 16, 8)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	traceID := jaeger.TraceID{High: high, Low: low}
+	if !traceID.IsValid() || spanID == 0 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+
+	return jaeger.NewSpanContext(traceID, jaeger.SpanID(spanID), 0, flags&traceFlagSampled != 0, nil), nil
+}
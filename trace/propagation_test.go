@@ -0,0 +1,109 @@
+package trace
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
+)
+
+func newTestTracer(t *testing.T, formats []string, reporter jaeger.Reporter) (opentracing.Tracer, io.Closer) {
+	headers, textMap, err := newPropagators(formats)
+	require.NoError(t, err)
+	if reporter == nil {
+		reporter = jaeger.NewNullReporter()
+	}
+
+	return jaeger.NewTracer("test", jaeger.NewConstSampler(true), reporter,
+		jaeger.TracerOptions.Gen128Bit(true),
+		jaeger.TracerOptions.Injector(opentracing.HTTPHeaders, headers),
+		jaeger.TracerOptions.Extractor(opentracing.HTTPHeaders, headers),
+		jaeger.TracerOptions.Injector(opentracing.TextMap, textMap),
+		jaeger.TracerOptions.Extractor(opentracing.TextMap, textMap))
+}
+
+func TestW3CPropagation(t *testing.T) {
+	require := require.New(t)
+	tracer, closer := newTestTracer(t, []string{PropagationJaeger, PropagationW3C}, nil)
+	defer closer.Close()
+
+	// 仅携带traceparent时按W3C提取
+	header := http.Header{}
+	header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	sc, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+	require.NoError(err)
+	jsc := sc.(jaeger.SpanContext)
+	require.Equal("4bf92f3577b34da6a3ce929d0e0e4736", jsc.TraceID().String())
+	require.Equal("f067aa0ba902b7", jsc.SpanID().String())
+	require.True(jsc.IsSampled())
+
+	// 注入时同时写入uber-trace-id及traceparent
+	span := tracer.StartSpan("child", opentracing.ChildOf(sc))
+	out := http.Header{}
+	require.NoError(tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(out)))
+	child := span.Context().(jaeger.SpanContext)
+	require.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-"+spanIDHex(child.SpanID())+"-01", out.Get("traceparent"))
+	require.NotEmpty(out.Get("uber-trace-id"))
+
+	// 按配置顺序优先使用uber-trace-id
+	header.Set("uber-trace-id", "1:2:0:0")
+	sc, err = tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+	require.NoError(err)
+	require.Equal("1", sc.(jaeger.SpanContext).TraceID().String())
+	require.False(sc.(jaeger.SpanContext).IsSampled())
+
+	_, err = tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(http.Header{}))
+	require.Equal(opentracing.ErrSpanContextNotFound, err)
+	bad := http.Header{}
+	bad.Set("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	_, err = tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(bad))
+	require.Equal(opentracing.ErrSpanContextCorrupted, err)
+}
+
+func TestB3Propagation(t *testing.T) {
+	require := require.New(t)
+	tracer, closer := newTestTracer(t, []string{PropagationW3C, PropagationB3}, nil)
+	defer closer.Close()
+
+	header := http.Header{}
+	header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+	header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+	header.Set("X-B3-Sampled", "1")
+	sc, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+	require.NoError(err)
+	require.Equal("463ac35c9f6413ad48485a3953bb6124", sc.(jaeger.SpanContext).TraceID().String())
+
+	carrier := opentracing.TextMapCarrier{}
+	require.NoError(tracer.Inject(sc, opentracing.TextMap, carrier))
+	require.Equal("463ac35c9f6413ad48485a3953bb6124", carrier["x-b3-traceid"])
+	require.Equal("00-463ac35c9f6413ad48485a3953bb6124-a2fb4a1d1a96d312-01", carrier[TraceparentHeader])
+
+	_, _, err = newPropagators([]string{"unknown"})
+	require.Error(err)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	require := require.New(t)
+
+	sc, err := parseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	require.NoError(err)
+	require.False(sc.IsSampled())
+	// 高版本可在末尾追加字段
+	_, err = parseTraceparent("01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra")
+	require.NoError(err)
+
+	for _, v := range []string{
+		"",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0af7651916cd43dd8448eb211c80319x-b7ad6b7169203331-01",
+	} {
+		_, err := parseTraceparent(v)
+		require.Error(err, v)
+	}
+}
@@ -0,0 +1,89 @@
+package trace
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+)
+
+// 以zipkin v2 JSON格式上报，即POST /api/v2/spans
+type zipkinEncoder struct {
+	serviceName string
+}
+
+func newZipkinTransport(serviceName string, cfg *ExporterConf) (jaeger.Transport, error) {
+	return newHTTPTransport(cfg, &zipkinEncoder{serviceName: serviceName})
+}
+
+var zipkinKinds = map[string]bool{"CLIENT": true, "SERVER": true, "PRODUCER": true, "CONSUMER": true}
+
+type zipkinSpan struct {
+	TraceID       string             `json:"traceId"`
+	ID            string             `json:"id"`
+	ParentID      string             `json:"parentId,omitempty"`
+	Name          string             `json:"name"`
+	Kind          string             `json:"kind,omitempty"`
+	Timestamp     int64              `json:"timestamp"`
+	Duration      int64              `json:"duration"`
+	Debug         bool               `json:"debug,omitempty"`
+	LocalEndpoint zipkinEndpoint     `json:"localEndpoint"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+	Annotations   []zipkinAnnotation `json:"annotations,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+func (e *zipkinEncoder) convert(span *jaeger.Span) interface{} {
+	sc := span.SpanContext()
+	zs := &zipkinSpan{
+		TraceID:       traceIDHex(sc.TraceID()),
+		ID:            spanIDHex(sc.SpanID()),
+		Name:          span.OperationName(),
+		Timestamp:     span.StartTime().UnixNano() / 1e3,
+		Duration:      span.Duration().Nanoseconds() / 1e3,
+		Debug:         sc.IsDebug(),
+		LocalEndpoint: zipkinEndpoint{ServiceName: e.serviceName},
+	}
+	if sc.ParentID() != 0 {
+		zs.ParentID = spanIDHex(sc.ParentID())
+	}
+
+	for k, v := range span.Tags() {
+		if k == string(ext.SpanKind) {
+			// zipkin仅支持这几种kind，其余的作为tag上报
+			if kind := strings.ToUpper(tagString(v)); zipkinKinds[kind] {
+				zs.Kind = kind
+				continue
+			}
+		}
+		if zs.Tags == nil {
+			zs.Tags = make(map[string]string)
+		}
+		zs.Tags[k] = tagString(v)
+	}
+
+	for _, lr := range span.Logs() {
+		fields := make([]string, 0, len(lr.Fields))
+		for _, f := range lr.Fields {
+			fields = append(fields, f.Key()+"="+tagString(f.Value()))
+		}
+		zs.Annotations = append(zs.Annotations, zipkinAnnotation{
+			Timestamp: lr.Timestamp.UnixNano() / 1e3,
+			Value:     strings.Join(fields, " "),
+		})
+	}
+	return zs
+}
+
+func (e *zipkinEncoder) encode(spans []interface{}) ([]byte, error) {
+	return json.Marshal(spans)
+}